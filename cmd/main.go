@@ -1,21 +1,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 
 	"ti-dding/internal/config"
 	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/mockserver"
 	"ti-dding/internal/models"
 	"ti-dding/internal/services"
 	"ti-dding/internal/storage"
 )
 
 var (
-	configFile string
-	cfg        *config.Config
+	configFile   string
+	cfg          *config.Config
+	dryRun       bool
+	dryRunLog    *services.DryRunLog
+	fileEncoding string // create、update 读取和 export 写入CSV文件的编码
 )
 
 // rootCmd 根命令
@@ -33,44 +44,52 @@ var rootCmd = &cobra.Command{
 - 本地数据存储和导出
 
 使用示例：
+  ti-dding validate --file groups.csv  # 创建前检查导入文件
   ti-dding create --file groups.csv    # 从CSV文件创建群组
   ti-dding list                       # 查看群组列表
   ti-dding add-member --user-id user123 --all-groups  # 添加成员到所有群组
   ti-dding export --output groups.csv # 导出群组数据`,
+	PersistentPreRunE: loadConfig,
 }
 
 // createCmd 创建群组命令
 var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "创建群组",
-	Long:  "从CSV文件批量创建钉钉群组",
+	Long: `从CSV文件或Excel文件（.xlsx）批量创建钉钉群组
+
+Excel文件读取"群组"工作表，可以用"成员"工作表列出群成员（每行一个群名称和成员用户ID）。
+
+每一行的进度记录在数据目录的 journal 子目录中。运行中断（网络故障、Ctrl-C 等）后
+使用 --resume 继续：已创建的群组不会重复创建，已在钉钉创建但未保存到本地的群组会
+补写本地记录。中断时创建结果未知的行需要人工核实，确认未创建后使用 --retry-unknown 重建。`,
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		reportFile, _ := cmd.Flags().GetString("report")
 		csvFile, _ := cmd.Flags().GetString("file")
+		resume, _ := cmd.Flags().GetBool("resume")
+		retryUnknown, _ := cmd.Flags().GetBool("retry-unknown")
 		if csvFile == "" {
 			return fmt.Errorf("必须指定CSV文件路径 (--file)")
 		}
 
 		// 初始化服务
-		client := dingtalk.NewClient(cfg)
-		storage := storage.NewFileStorage(cfg.GetDataDir())
-		groupConfig := &config.GroupConfig{
-			DefaultOwner: cfg.Group.DefaultOwner,
-			DefaultSettings: config.GroupDefaultSettings{
-				AllowMemberInvite:   cfg.Group.DefaultSettings.AllowMemberInvite,
-				AllowMemberView:     cfg.Group.DefaultSettings.AllowMemberView,
-				AllowMemberEditName: cfg.Group.DefaultSettings.AllowMemberEditName,
-			},
-		}
-		service := services.NewGroupService(client, storage, groupConfig)
+		service := newGroupService()
+		service.SetConcurrency(concurrency)
+		journal := storage.NewJournal(filepath.Join(cfg.GetDataDir(), "journal"), "create", csvFile)
 
 		// 执行创建操作
-		resp, err := service.CreateGroupsFromCSV(csvFile)
+		resp, err := service.CreateGroupsFromCSV(cmd.Context(), csvFile, journal, resume, retryUnknown)
 		if err != nil {
 			return fmt.Errorf("创建群组失败: %w", err)
 		}
 
 		fmt.Println(resp.Message)
-		return nil
+		if err := writeBatchReport(reportFile, resp.Results); err != nil {
+			return err
+		}
+		return interruptedError(cmd.Context())
 	},
 }
 
@@ -81,17 +100,7 @@ var listCmd = &cobra.Command{
 	Long:  "显示所有群组的列表",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// 初始化服务
-		client := dingtalk.NewClient(cfg)
-		storage := storage.NewFileStorage(cfg.GetDataDir())
-		groupConfig := &config.GroupConfig{
-			DefaultOwner: cfg.Group.DefaultOwner,
-			DefaultSettings: config.GroupDefaultSettings{
-				AllowMemberInvite:   cfg.Group.DefaultSettings.AllowMemberInvite,
-				AllowMemberView:     cfg.Group.DefaultSettings.AllowMemberView,
-				AllowMemberEditName: cfg.Group.DefaultSettings.AllowMemberEditName,
-			},
-		}
-		service := services.NewGroupService(client, storage, groupConfig)
+		service := newGroupService()
 
 		// 获取群组列表
 		resp, err := service.ListGroups()
@@ -110,6 +119,9 @@ var listCmd = &cobra.Command{
 			fmt.Printf("   描述: %s\n", group.Description)
 			fmt.Printf("   群主: %s\n", group.OwnerID)
 			fmt.Printf("   成员数: %d\n", group.MemberCount)
+			if len(group.LinkedDepartments) > 0 {
+				fmt.Printf("   关联部门: %s\n", linkText(group))
+			}
 			fmt.Printf("   创建时间: %s\n", group.CreatedAt.Format("2006-01-02 15:04:05"))
 			fmt.Printf("   状态: %s\n\n", group.Status)
 		}
@@ -124,6 +136,8 @@ var addMemberCmd = &cobra.Command{
 	Short: "添加群组成员",
 	Long:  "添加用户到指定的群组或所有群组",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		reportFile, _ := cmd.Flags().GetString("report")
 		userID, _ := cmd.Flags().GetString("user-id")
 		groupID, _ := cmd.Flags().GetString("group-id")
 		allGroups, _ := cmd.Flags().GetBool("all-groups")
@@ -137,17 +151,8 @@ var addMemberCmd = &cobra.Command{
 		}
 
 		// 初始化服务
-		client := dingtalk.NewClient(cfg)
-		storage := storage.NewFileStorage(cfg.GetDataDir())
-		groupConfig := &config.GroupConfig{
-			DefaultOwner: cfg.Group.DefaultOwner,
-			DefaultSettings: config.GroupDefaultSettings{
-				AllowMemberInvite:   cfg.Group.DefaultSettings.AllowMemberInvite,
-				AllowMemberView:     cfg.Group.DefaultSettings.AllowMemberView,
-				AllowMemberEditName: cfg.Group.DefaultSettings.AllowMemberEditName,
-			},
-		}
-		service := services.NewGroupService(client, storage, groupConfig)
+		service := newGroupService()
+		service.SetConcurrency(concurrency)
 
 		// 执行添加成员操作
 		req := &models.GroupMemberRequest{
@@ -156,13 +161,16 @@ var addMemberCmd = &cobra.Command{
 			AllGroups: allGroups,
 		}
 
-		resp, err := service.AddMembers(req)
+		resp, err := service.AddMembers(cmd.Context(), req)
 		if err != nil {
 			return fmt.Errorf("添加成员失败: %w", err)
 		}
 
 		fmt.Println(resp.Message)
-		return nil
+		if err := writeBatchReport(reportFile, resp.Results); err != nil {
+			return err
+		}
+		return interruptedError(cmd.Context())
 	},
 }
 
@@ -172,6 +180,8 @@ var removeMemberCmd = &cobra.Command{
 	Short: "移除群组成员",
 	Long:  "从指定的群组或所有群组中移除用户",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		reportFile, _ := cmd.Flags().GetString("report")
 		userID, _ := cmd.Flags().GetString("user-id")
 		groupID, _ := cmd.Flags().GetString("group-id")
 		allGroups, _ := cmd.Flags().GetBool("all-groups")
@@ -185,17 +195,8 @@ var removeMemberCmd = &cobra.Command{
 		}
 
 		// 初始化服务
-		client := dingtalk.NewClient(cfg)
-		storage := storage.NewFileStorage(cfg.GetDataDir())
-		groupConfig := &config.GroupConfig{
-			DefaultOwner: cfg.Group.DefaultOwner,
-			DefaultSettings: config.GroupDefaultSettings{
-				AllowMemberInvite:   cfg.Group.DefaultSettings.AllowMemberInvite,
-				AllowMemberView:     cfg.Group.DefaultSettings.AllowMemberView,
-				AllowMemberEditName: cfg.Group.DefaultSettings.AllowMemberEditName,
-			},
-		}
-		service := services.NewGroupService(client, storage, groupConfig)
+		service := newGroupService()
+		service.SetConcurrency(concurrency)
 
 		// 执行移除成员操作
 		req := &models.GroupMemberRequest{
@@ -204,13 +205,16 @@ var removeMemberCmd = &cobra.Command{
 			AllGroups: allGroups,
 		}
 
-		resp, err := service.RemoveMembers(req)
+		resp, err := service.RemoveMembers(cmd.Context(), req)
 		if err != nil {
 			return fmt.Errorf("移除成员失败: %w", err)
 		}
 
 		fmt.Println(resp.Message)
-		return nil
+		if err := writeBatchReport(reportFile, resp.Results); err != nil {
+			return err
+		}
+		return interruptedError(cmd.Context())
 	},
 }
 
@@ -218,7 +222,7 @@ var removeMemberCmd = &cobra.Command{
 var exportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "导出群组数据",
-	Long:  "将群组数据导出为CSV文件",
+	Long:  "将群组数据导出为CSV文件，输出文件扩展名为 .xlsx 时导出为包含群组和成员两个工作表的Excel文件",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		outputFile, _ := cmd.Flags().GetString("output")
 		if outputFile == "" {
@@ -226,17 +230,7 @@ var exportCmd = &cobra.Command{
 		}
 
 		// 初始化服务
-		client := dingtalk.NewClient(cfg)
-		storage := storage.NewFileStorage(cfg.GetDataDir())
-		groupConfig := &config.GroupConfig{
-			DefaultOwner: cfg.Group.DefaultOwner,
-			DefaultSettings: config.GroupDefaultSettings{
-				AllowMemberInvite:   cfg.Group.DefaultSettings.AllowMemberInvite,
-				AllowMemberView:     cfg.Group.DefaultSettings.AllowMemberView,
-				AllowMemberEditName: cfg.Group.DefaultSettings.AllowMemberEditName,
-			},
-		}
-		service := services.NewGroupService(client, storage, groupConfig)
+		service := newGroupService()
 
 		// 执行导出操作
 		if err := service.ExportGroups(outputFile); err != nil {
@@ -252,64 +246,1116 @@ var exportCmd = &cobra.Command{
 var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "检查群组是否存在",
-	Long:  "检查指定名称的群组是否已存在",
+	Long: `检查指定名称的群组是否已存在。
+
+同时查找本地存储和共享登记表 (group.registry_file) 中的同名群组，
+并通过钉钉接口核实这些群组是否仍然存在、是否已被改名。`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		groupName, _ := cmd.Flags().GetString("name")
 		if groupName == "" {
 			return fmt.Errorf("必须指定群组名称 (--name)")
 		}
+		localOnly, _ := cmd.Flags().GetBool("local-only")
 
 		// 初始化服务
-		client := dingtalk.NewClient(cfg)
-		storage := storage.NewFileStorage(cfg.GetDataDir())
-		groupConfig := &config.GroupConfig{
-			DefaultOwner: cfg.Group.DefaultOwner,
-			DefaultSettings: config.GroupDefaultSettings{
-				AllowMemberInvite:   cfg.Group.DefaultSettings.AllowMemberInvite,
-				AllowMemberView:     cfg.Group.DefaultSettings.AllowMemberView,
-				AllowMemberEditName: cfg.Group.DefaultSettings.AllowMemberEditName,
-			},
-		}
-		service := services.NewGroupService(client, storage, groupConfig)
+		service := newGroupService()
 
 		// 执行检查操作
-		exists := service.CheckGroupExists(groupName)
-		if exists {
+		resp, err := service.CheckGroupExists(cmd.Context(), groupName, !localOnly)
+		if err != nil {
+			return fmt.Errorf("检查群组失败: %w", err)
+		}
+
+		if resp.Exists {
 			fmt.Printf("群组 '%s' 已存在\n", groupName)
 		} else {
 			fmt.Printf("群组 '%s' 不存在\n", groupName)
 		}
+		for _, match := range resp.Matches {
+			line := fmt.Sprintf("  - %s  来源: %s  状态: %s", match.ChatID, services.SourceText(match.Sources), services.RemoteStatusText(match))
+			if match.RemoteStatus == models.RemoteAlive && match.RemoteName != groupName {
+				line += fmt.Sprintf("  (已改名为 '%s')", match.RemoteName)
+			}
+			fmt.Println(line)
+		}
+
+		return interruptedError(cmd.Context())
+	},
+}
+
+// validateCmd 检查导入文件命令
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "检查群组导入文件",
+	Long: `在创建群组前检查CSV或Excel导入文件，不创建任何群组。
+
+检查必填字段、群名称长度、群组类型、文件内和本地存储中的重名、成员数量上限，并在企业
+通讯录中核实群主和成员（内部群不能包含通讯录之外的用户）。按行号列出错误和警告，
+有错误时以非零状态退出。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		csvFile, _ := cmd.Flags().GetString("file")
+		localOnly, _ := cmd.Flags().GetBool("local-only")
+
+		// 初始化服务
+		service := newGroupService()
+
+		resp, err := service.ValidateGroupsCSV(cmd.Context(), csvFile, !localOnly)
+		if err != nil {
+			return fmt.Errorf("检查文件失败: %w", err)
+		}
+
+		for _, issue := range resp.Issues {
+			level := "警告"
+			if issue.Level == models.IssueError {
+				level = "错误"
+			}
+			fmt.Printf("第%d行 %s: %s\n", issue.Line, level, issue.Message)
+		}
+		if len(resp.Issues) > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("共检查 %d 行：%d 个错误，%d 个警告\n", resp.Rows, resp.Errors, resp.Warnings)
+
+		if resp.Errors > 0 {
+			return fmt.Errorf("文件中有 %d 个错误，请修正后再创建群组", resp.Errors)
+		}
+		return interruptedError(cmd.Context())
+	},
+}
+
+// showCmd 查看群组详情命令
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "查看群组详情",
+	Long:  "从钉钉获取群组的实时信息（名称、群主、成员、设置、会话类型），并标出与本地记录不一致的字段",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groupID, _ := cmd.Flags().GetString("group-id")
+		if groupID == "" {
+			return fmt.Errorf("必须指定群组ID (--group-id)")
+		}
+
+		// 初始化服务
+		service := newGroupService()
+
+		resp, err := service.GetGroupDetail(cmd.Context(), groupID)
+		if err != nil {
+			return err
+		}
+
+		printGroupDetail(resp)
+		return nil
+	},
+}
+
+// printGroupDetail 打印群组详情，与本地记录不一致的字段以 * 标出
+func printGroupDetail(resp *models.GroupDetailResponse) {
+	info := resp.Remote
+	diffs := make(map[string]models.GroupFieldDiff, len(resp.Diffs))
+	for _, diff := range resp.Diffs {
+		diffs[diff.Field] = diff
+	}
+	field := func(key, label, value string) {
+		if diff, ok := diffs[key]; ok {
+			fmt.Printf("* %s: %s  (本地记录: %s)\n", label, value, diff.Local)
+		} else {
+			fmt.Printf("  %s: %s\n", label, value)
+		}
+	}
+	fmt.Printf("群组 %s 的实时信息:\n\n", info.ChatID)
+	field("name", "群名称", info.Name)
+	field("owner", "群主", info.OwnerID)
+	if diff, ok := diffs["group_type"]; ok {
+		fmt.Printf("* 群组类型: %s  (本地记录: %s)\n", groupTypeText(info.GroupType()), groupTypeText(diff.Local))
+	} else {
+		fmt.Printf("  群组类型: %s\n", groupTypeText(info.GroupType()))
+	}
+
+	if diff, ok := diffs["members"]; ok {
+		added, removed := services.MemberChanges(splitList(diff.Local), info.Members)
+		fmt.Printf("* 成员 (%d): %s\n", len(info.Members), strings.Join(info.Members, ", "))
+		if len(added) > 0 {
+			fmt.Printf("    本地记录中缺少: %s\n", strings.Join(added, ", "))
+		}
+		if len(removed) > 0 {
+			fmt.Printf("    已不在群中: %s\n", strings.Join(removed, ", "))
+		}
+	} else {
+		fmt.Printf("  成员 (%d): %s\n", len(info.Members), strings.Join(info.Members, ", "))
+	}
+	if diff, ok := diffs["status"]; ok {
+		fmt.Printf("* 状态: 钉钉中存在  (本地记录: %s)\n", diff.Local)
+	}
+
+	if diff, ok := diffs["settings"]; ok {
+		fmt.Printf("* 群设置:  (本地记录: %s)\n", diff.Local)
+	} else {
+		fmt.Println("  群设置:")
+	}
+	fmt.Printf("    新成员可查看历史消息: %s\n", services.YesNoText(info.ShowHistoryType))
+	fmt.Printf("    入群需要验证: %s\n", services.YesNoText(info.ValidationType))
+	fmt.Printf("    可被搜索: %s\n", services.YesNoText(info.Searchable))
+	fmt.Printf("    @所有人权限: %s\n", services.AuthorityText(info.MentionAllAuthority))
+	fmt.Printf("    群管理权限: %s\n", services.AuthorityText(info.ManagementType))
+	fmt.Printf("    全员禁言: %s\n", services.YesNoText(info.ChatBannedType))
+
+	fmt.Println()
+	switch {
+	case resp.Local == nil:
+		fmt.Println("本地存储中没有该群组的记录，可使用 adopt 命令纳管")
+	case resp.Local.Status == "deleted":
+		fmt.Println("本地记录已标记为删除，但群组在钉钉中仍然存在，可使用 adopt 命令重新纳管")
+	case len(resp.Diffs) == 0:
+		fmt.Println("本地记录与钉钉一致")
+	default:
+		fmt.Printf("有 %d 项与本地记录不一致（以 * 标出）\n", len(resp.Diffs))
+	}
+}
+
+// updateCmd 修改群组命令
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "修改群组",
+	Long: `修改群组的名称、描述、群主和群设置，先在钉钉中修改，成功后更新本地记录。
+
+可通过 --group-id 或 --name 指定单个群组，或通过 --file 从CSV文件批量修改。
+新名称与其他群组重名时拒绝修改。
+
+开关类设置取值 是/否 (yes/no)，权限类设置取值 所有人/仅群主 (all/owner)。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		csvFile, _ := cmd.Flags().GetString("file")
+		groupID, _ := cmd.Flags().GetString("group-id")
+		groupName, _ := cmd.Flags().GetString("name")
+
+		// 初始化服务
+		service := newGroupService()
+
+		if csvFile != "" {
+			if groupID != "" || groupName != "" {
+				return fmt.Errorf("--file 不能与 --group-id 或 --name 同时使用")
+			}
+
+			resp, err := service.UpdateGroupsFromCSV(cmd.Context(), csvFile)
+			if err != nil {
+				return fmt.Errorf("批量修改群组失败: %w", err)
+			}
+
+			fmt.Println(resp.Message)
+			return interruptedError(cmd.Context())
+		}
+
+		ref := groupID
+		if ref == "" {
+			ref = groupName
+		}
+		if ref == "" {
+			return fmt.Errorf("必须指定群组ID (--group-id)、群组名称 (--name) 或CSV文件 (--file)")
+		}
+
+		group, err := service.ResolveGroup(ref)
+		if err != nil {
+			return err
+		}
+
+		req := &models.GroupUpdateRequest{GroupID: group.ID}
+		req.Name, _ = cmd.Flags().GetString("new-name")
+		req.Description, _ = cmd.Flags().GetString("description")
+		req.OwnerID, _ = cmd.Flags().GetString("owner")
+
+		settings := []struct {
+			flag  string
+			dst   **int
+			parse func(string) (*int, error)
+		}{
+			{"show-history", &req.Settings.ShowHistoryType, services.ParseSwitch},
+			{"validation", &req.Settings.ValidationType, services.ParseSwitch},
+			{"searchable", &req.Settings.Searchable, services.ParseSwitch},
+			{"mention-all", &req.Settings.MentionAllAuthority, services.ParseAuthority},
+			{"management", &req.Settings.ManagementType, services.ParseAuthority},
+			{"chat-banned", &req.Settings.ChatBannedType, services.ParseSwitch},
+		}
+		for _, setting := range settings {
+			value, _ := cmd.Flags().GetString(setting.flag)
+			if *setting.dst, err = setting.parse(value); err != nil {
+				return fmt.Errorf("--%s: %w", setting.flag, err)
+			}
+		}
+
+		updated, err := service.UpdateGroup(cmd.Context(), req)
+		if err != nil {
+			return fmt.Errorf("修改群组失败: %w", err)
+		}
+
+		fmt.Printf("群组已修改: %s (%s)，群主: %s\n", updated.Name, updated.ID, updated.OwnerID)
+		return nil
+	},
+}
+
+// transferOwnerCmd 批量转移群主命令
+var transferOwnerCmd = &cobra.Command{
+	Use:   "transfer-owner",
+	Short: "批量转移群主",
+	Long: `将指定用户担任群主的全部群组（按本地记录查找）转移给新群主。
+
+新群主不在群中时先将其添加为群成员。使用 --verify 先从钉钉核实每个群组的当前群主，
+跳过群主已变更或已解散的群组。每个群组的结果写入 --report 指定的CSV文件。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		verify, _ := cmd.Flags().GetBool("verify")
+		reportFile, _ := cmd.Flags().GetString("report")
+
+		// 初始化服务
+		service := newGroupService()
+
+		resp, err := service.TransferOwnership(cmd.Context(), from, to, verify)
+		if err != nil {
+			return fmt.Errorf("转移群主失败: %w", err)
+		}
+
+		if len(resp.Results) == 0 && resp.Skipped == 0 {
+			fmt.Printf("本地记录中没有 %s 担任群主的群组\n", from)
+			return nil
+		}
+
+		counts := make(map[string]int)
+		for _, result := range resp.Results {
+			counts[result.Status]++
+			line := fmt.Sprintf("[%s] %s (%s)", transferStatusText(result.Status), result.Name, result.GroupID)
+			if result.Message != "" {
+				line += ": " + result.Message
+			}
+			fmt.Println(line)
+		}
+
+		fmt.Printf("\n已转移 %d 个，跳过 %d 个，失败 %d 个\n",
+			counts[models.TransferDone], counts[models.TransferSkipped], counts[models.TransferFailed])
+		if resp.Skipped > 0 {
+			fmt.Printf("操作已中断：剩余 %d 个群组未处理\n", resp.Skipped)
+		}
+
+		if err := storage.ExportOwnerTransferReport(reportFile, resp); err != nil {
+			return err
+		}
+		fmt.Printf("结果报告已写入: %s\n", reportFile)
+
+		if err := interruptedError(cmd.Context()); err != nil {
+			return err
+		}
+		if counts[models.TransferFailed] > 0 {
+			return fmt.Errorf("%d 个群组转移失败", counts[models.TransferFailed])
+		}
+		return nil
+	},
+}
+
+// transferStatusText 返回群主转移结果的中文描述
+func transferStatusText(status string) string {
+	switch status {
+	case models.TransferDone:
+		return "已转移"
+	case models.TransferSkipped:
+		return "跳过"
+	default:
+		return "失败"
+	}
+}
+
+// offboardCmd 员工离职处理命令
+var offboardCmd = &cobra.Command{
+	Use:   "offboard",
+	Short: "员工离职处理",
+	Long: `将离职员工移出其所在的全部群组。
+
+默认先从钉钉核实每个群组的实时成员，只处理确实包含该员工的群组。员工担任群主的群组
+先转移给继任群主再移出，继任群主依次取 --successor、配置文件中的
+group.offboard_successor、员工所在部门（或上级部门）的主管。
+
+最后输出变更汇总和需要人工处理的群组，存在需要人工处理的群组时以非零状态退出。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userID, _ := cmd.Flags().GetString("user-id")
+		successor, _ := cmd.Flags().GetString("successor")
+		localOnly, _ := cmd.Flags().GetBool("local-only")
+		reportFile, _ := cmd.Flags().GetString("report")
+
+		// 初始化服务
+		service := newGroupService()
+
+		resp, err := service.OffboardUser(cmd.Context(), userID, successor, localOnly)
+		if err != nil {
+			return fmt.Errorf("离职处理失败: %w", err)
+		}
+
+		fmt.Printf("员工离职处理: %s\n", resp.UserID)
+		if resp.Successor != "" {
+			fmt.Printf("继任群主: %s (%s)\n", resp.Successor, resp.SuccessorSource)
+		}
+		fmt.Printf("检查了 %d 个群组，该员工在其中 %d 个群组中\n\n", resp.Checked, len(resp.Groups))
+
+		var followUps []models.OffboardGroupResult
+		for _, result := range resp.Groups {
+			status := "完成"
+			if result.FollowUp != "" {
+				status = "需人工处理"
+				followUps = append(followUps, result)
+			}
+			line := fmt.Sprintf("[%s] %s (%s)", status, result.Name, result.GroupID)
+			if len(result.Actions) > 0 {
+				line += ": " + strings.Join(result.Actions, "; ")
+			}
+			fmt.Println(line)
+		}
+
+		if len(followUps) > 0 {
+			fmt.Printf("\n需要人工处理 (%d):\n", len(followUps))
+			for _, result := range followUps {
+				fmt.Printf("  - %s (%s): %s\n", result.Name, result.GroupID, result.FollowUp)
+			}
+		}
+		if resp.Skipped > 0 {
+			fmt.Printf("\n操作已中断：剩余 %d 个群组未处理\n", resp.Skipped)
+		}
+
+		if reportFile != "" {
+			if err := storage.ExportOffboardReport(reportFile, resp); err != nil {
+				return err
+			}
+			fmt.Printf("\n处理报告已写入: %s\n", reportFile)
+		}
+
+		if err := interruptedError(cmd.Context()); err != nil {
+			return err
+		}
+		if len(followUps) > 0 {
+			return fmt.Errorf("%d 个群组需要人工处理", len(followUps))
+		}
+		return nil
+	},
+}
+
+// onboardCmd 新员工入职命令
+var onboardCmd = &cobra.Command{
+	Use:   "onboard",
+	Short: "新员工入职自动入群",
+	Long: `从通讯录查询新员工的部门和职位，按配置文件中的 group.onboard_rules 规则
+将其加入对应的群组，并列出每个群组是由哪条规则命中的。
+
+使用全局的 --dry-run 只列出将要加入的群组，不做任何修改。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userID, _ := cmd.Flags().GetString("user-id")
+
+		// 初始化服务
+		service := newGroupService()
+
+		resp, err := service.OnboardUser(cmd.Context(), userID)
+		if err != nil {
+			return fmt.Errorf("入职处理失败: %w", err)
+		}
+
+		user := resp.User
+		fmt.Printf("员工: %s (%s)，职位: %s，部门: %v\n", user.Name, user.UserID, user.Position, user.DepartmentIDs)
+		fmt.Println()
+
+		if len(resp.Groups) == 0 {
+			fmt.Println("没有命中任何入群规则")
+		}
+
+		failed := 0
+		for _, result := range resp.Groups {
+			line := fmt.Sprintf("[%s] %s (%s) <- 规则: %s", onboardStatusText(result.Status), result.Name, result.GroupID, strings.Join(result.Rules, ", "))
+			if result.Message != "" {
+				line += "  " + result.Message
+			}
+			fmt.Println(line)
+			if result.Status == models.OnboardFailed {
+				failed++
+			}
+		}
+		for _, e := range resp.Errors {
+			fmt.Printf("! 规则配置错误: %s\n", e)
+		}
+		if resp.Skipped > 0 {
+			fmt.Printf("\n操作已中断：剩余 %d 个群组未处理\n", resp.Skipped)
+		}
+
+		if err := interruptedError(cmd.Context()); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d 个群组加入失败", failed)
+		}
+		return nil
+	},
+}
+
+// onboardStatusText 返回入职入群结果的中文描述
+func onboardStatusText(status string) string {
+	switch status {
+	case models.OnboardAdded:
+		return "已加入"
+	case models.OnboardPlanned:
+		return "将加入"
+	case models.OnboardMember:
+		return "已是成员"
+	default:
+		return "失败"
+	}
+}
+
+// linkDepartmentCmd 关联部门命令
+var linkDepartmentCmd = &cobra.Command{
+	Use:   "link-department",
+	Short: "将群组关联到部门",
+	Long: `将群组关联到一个或多个钉钉部门，之后 reconcile 命令会按部门的当前员工同步群成员。
+
+使用 --clear 取消关联。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groupID, _ := cmd.Flags().GetString("group-id")
+		deptIDs, _ := cmd.Flags().GetInt64Slice("departments")
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		clear, _ := cmd.Flags().GetBool("clear")
+
+		if clear {
+			deptIDs = nil
+		} else if len(deptIDs) == 0 {
+			return fmt.Errorf("必须指定部门ID (--departments) 或使用 --clear 取消关联")
+		}
+
+		// 初始化服务
+		service := newGroupService()
+
+		group, err := service.LinkDepartments(cmd.Context(), groupID, deptIDs, recursive)
+		if err != nil {
+			return fmt.Errorf("关联部门失败: %w", err)
+		}
+
+		if len(group.LinkedDepartments) == 0 {
+			fmt.Printf("群组 %s (%s) 已取消关联部门\n", group.Name, group.ID)
+		} else {
+			fmt.Printf("群组 %s (%s) 已关联部门: %s\n", group.Name, group.ID, linkText(*group))
+			fmt.Println("运行 reconcile 命令同步群成员")
+		}
+		return nil
+	},
+}
+
+// reconcileCmd 同步关联部门群组成员命令
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "按关联部门同步群成员",
+	Long: `按关联部门的当前员工同步群成员：部门新员工加入群组，已离开部门的员工移出群组。
+手动加入的成员和群主不会被移出。适合通过 cron 定时运行，有群组同步失败时以非零状态退出。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groupID, _ := cmd.Flags().GetString("group-id")
+
+		// 初始化服务
+		service := newGroupService()
+
+		resp, err := service.ReconcileGroups(cmd.Context(), groupID)
+		if err != nil {
+			return fmt.Errorf("同步群成员失败: %w", err)
+		}
+
+		if len(resp.Results) == 0 && resp.Skipped == 0 {
+			fmt.Println("没有关联部门的群组")
+			return nil
+		}
+
+		failed := 0
+		for _, result := range resp.Results {
+			switch {
+			case result.Error != "":
+				failed++
+				fmt.Printf("! %s (%s): %s\n", result.Name, result.GroupID, result.Error)
+			case len(result.Added) == 0 && len(result.Removed) == 0:
+				fmt.Printf("= %s (%s): 无变化\n", result.Name, result.GroupID)
+			default:
+				fmt.Printf("~ %s (%s):\n", result.Name, result.GroupID)
+			}
+			if len(result.Added) > 0 {
+				fmt.Printf("    加入: %s\n", strings.Join(result.Added, ", "))
+			}
+			if len(result.Removed) > 0 {
+				fmt.Printf("    移出: %s\n", strings.Join(result.Removed, ", "))
+			}
+		}
+		if resp.Skipped > 0 {
+			fmt.Printf("\n操作已中断：剩余 %d 个群组未处理\n", resp.Skipped)
+		}
+
+		if err := interruptedError(cmd.Context()); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d 个群组同步失败", failed)
+		}
+		return nil
+	},
+}
+
+// linkText 返回群组关联部门的描述
+func linkText(group models.Group) string {
+	ids := make([]string, len(group.LinkedDepartments))
+	for i, id := range group.LinkedDepartments {
+		ids[i] = fmt.Sprintf("%d", id)
+	}
+	text := strings.Join(ids, ",")
+	if group.LinkRecursive {
+		text += " (含下级部门)"
+	}
+	return text
+}
+
+// planCmd 生成清单执行计划命令
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "对比群组清单生成执行计划",
+	Long: `读取群组清单 (YAML/JSON)，与本地存储对比，列出需要创建的群组、需要修改的
+名称/群主/群设置/标签以及需要添加和移除的成员。
+
+使用 --live 与钉钉实时信息对比；使用 --out 保存计划，审阅后通过 apply --plan 执行。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifestFile, _ := cmd.Flags().GetString("file")
+		live, _ := cmd.Flags().GetBool("live")
+		outFile, _ := cmd.Flags().GetString("out")
+
+		manifest, err := storage.LoadManifest(manifestFile)
+		if err != nil {
+			return err
+		}
+
+		// 初始化服务
+		service := newGroupService()
+
+		plan, err := service.PlanManifest(cmd.Context(), manifest, manifestFile, live)
+		if err != nil {
+			return fmt.Errorf("生成执行计划失败: %w", err)
+		}
+
+		printPlan(plan)
+
+		if outFile != "" && len(plan.Actions) > 0 {
+			if err := storage.SavePlan(outFile, plan); err != nil {
+				return err
+			}
+			fmt.Printf("\n执行计划已保存到: %s，审阅后运行 apply --plan %s 执行\n", outFile, outFile)
+		}
+		return nil
+	},
+}
+
+// applyCmd 执行清单计划命令
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "执行群组清单计划",
+	Long: `执行 plan --out 保存的计划 (--plan)，或直接按清单生成计划并执行 (--file)。
+
+已有群组的本地记录在生成计划后发生变化时，对应的动作不执行，需要重新生成计划。
+按 --live 生成的计划执行时同样以钉钉中的当前状态判断需要修改的内容，并刷新本地记录。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		planFile, _ := cmd.Flags().GetString("plan")
+		manifestFile, _ := cmd.Flags().GetString("file")
+		live, _ := cmd.Flags().GetBool("live")
+
+		if (planFile == "") == (manifestFile == "") {
+			return fmt.Errorf("必须指定 --plan 或 --file 其中之一")
+		}
+
+		// 初始化服务
+		service := newGroupService()
+
+		var plan *models.Plan
+		var err error
+		if planFile != "" {
+			if plan, err = storage.LoadPlan(planFile); err != nil {
+				return err
+			}
+		} else {
+			manifest, err := storage.LoadManifest(manifestFile)
+			if err != nil {
+				return err
+			}
+			if plan, err = service.PlanManifest(cmd.Context(), manifest, manifestFile, live); err != nil {
+				return fmt.Errorf("生成执行计划失败: %w", err)
+			}
+		}
+
+		printPlan(plan)
+		if len(plan.Actions) == 0 {
+			return nil
+		}
+
+		resp, err := service.ApplyPlan(cmd.Context(), plan)
+		if err != nil {
+			return fmt.Errorf("执行计划失败: %w", err)
+		}
+
+		fmt.Println("\n执行结果:")
+		failed := 0
+		for _, result := range resp.Results {
+			if result.Error != "" {
+				failed++
+				fmt.Printf("! %s (%s): %s\n", result.Name, result.GroupID, result.Error)
+			} else {
+				fmt.Printf("✓ %s (%s)\n", result.Name, result.GroupID)
+			}
+		}
+		fmt.Printf("\n成功 %d 个，失败 %d 个\n", len(resp.Results)-failed, failed)
+		if resp.Skipped > 0 {
+			fmt.Printf("操作已中断：剩余 %d 个动作未执行\n", resp.Skipped)
+		}
 
+		if err := interruptedError(cmd.Context()); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d 个动作执行失败", failed)
+		}
 		return nil
 	},
 }
 
+// printPlan 打印执行计划
+func printPlan(plan *models.Plan) {
+	var creates, updates int
+	for _, action := range plan.Actions {
+		if action.Action == models.PlanCreate {
+			creates++
+			fmt.Printf("+ 创建 %s\n", action.Name)
+		} else {
+			updates++
+			fmt.Printf("~ 修改 %s (%s)\n", action.Name, action.GroupID)
+		}
+		for _, change := range action.Changes {
+			fmt.Printf("    %s\n", planChangeText(change))
+		}
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Printf("! %s\n", warning)
+	}
+
+	if len(plan.Actions) > 0 || len(plan.Warnings) > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("计划: 创建 %d 个，修改 %d 个，无变化 %d 个\n", creates, updates, plan.Unchanged)
+}
+
+// planChangeText 格式化计划中的一项变更
+func planChangeText(change models.PlanChange) string {
+	labels := map[string]string{
+		"name":        "群名称",
+		"description": "群描述",
+		"owner":       "群主",
+		"group_type":  "群组类型",
+		"settings":    "群设置",
+		"tags":        "标签",
+	}
+
+	switch {
+	case change.Field == "members" && change.From != "":
+		added, removed := services.MemberChanges(splitList(change.From), splitList(change.To))
+		text := "成员:"
+		if len(added) > 0 {
+			text += " 添加 " + strings.Join(added, ", ")
+		}
+		if len(removed) > 0 {
+			text += " 移除 " + strings.Join(removed, ", ")
+		}
+		return text
+	case change.Field == "members":
+		return "成员: " + strings.ReplaceAll(change.To, ",", ", ")
+	case change.Field == "group_type":
+		return "群组类型: " + groupTypeText(change.To)
+	case change.From == "":
+		return fmt.Sprintf("%s: %s", labels[change.Field], change.To)
+	default:
+		return fmt.Sprintf("%s: %s -> %s", labels[change.Field], change.From, change.To)
+	}
+}
+
+// syncCmd 同步群组命令
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "从钉钉同步群组信息",
+	Long: `逐个从钉钉拉取本地存储中群组的实时信息，更新名称、群主、成员和群组类型，
+并将钉钉中已解散的群组标记为已删除。
+
+使用 --check 只报告本地记录与钉钉的差异而不修改，存在差异时以非零状态退出。`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checkOnly, _ := cmd.Flags().GetBool("check")
+
+		// 初始化服务
+		service := newGroupService()
+
+		resp, err := service.SyncGroups(cmd.Context(), checkOnly)
+		if err != nil {
+			return fmt.Errorf("同步群组失败: %w", err)
+		}
+
+		for _, change := range resp.Changes {
+			if change.Action == models.SyncDeleted {
+				fmt.Printf("- %s (%s): 钉钉中已解散\n", change.Name, change.GroupID)
+				continue
+			}
+			fmt.Printf("~ %s (%s):\n", change.Name, change.GroupID)
+			for _, diff := range change.Diffs {
+				fmt.Printf("    %s\n", diffText(diff))
+			}
+		}
+		for _, e := range resp.Errors {
+			fmt.Printf("! %s\n", e)
+		}
+
+		var updated, deleted int
+		for _, change := range resp.Changes {
+			if change.Action == models.SyncDeleted {
+				deleted++
+			} else {
+				updated++
+			}
+		}
+
+		if checkOnly {
+			fmt.Printf("\n检查了 %d 个群组：%d 个与钉钉不一致，%d 个已在钉钉中解散（仅检查，未修改本地记录）\n", resp.Checked, updated, deleted)
+		} else {
+			fmt.Printf("\n检查了 %d 个群组：更新 %d 个，标记删除 %d 个\n", resp.Checked, updated, deleted)
+		}
+		if len(resp.Errors) > 0 {
+			fmt.Printf("%d 个群组核实失败\n", len(resp.Errors))
+		}
+		if resp.Skipped > 0 {
+			fmt.Printf("操作已中断：剩余 %d 个群组未处理\n", resp.Skipped)
+		}
+
+		if err := interruptedError(cmd.Context()); err != nil {
+			return err
+		}
+		if checkOnly && len(resp.Changes) > 0 {
+			return fmt.Errorf("本地记录与钉钉不一致: %d 个群组", len(resp.Changes))
+		}
+		if len(resp.Errors) > 0 {
+			return fmt.Errorf("%d 个群组核实失败", len(resp.Errors))
+		}
+		return nil
+	},
+}
+
+// diffText 格式化一个不一致的字段
+func diffText(diff models.GroupFieldDiff) string {
+	switch diff.Field {
+	case "name":
+		return fmt.Sprintf("群名称: '%s' -> '%s'", diff.Local, diff.Remote)
+	case "owner":
+		return fmt.Sprintf("群主: %s -> %s", diff.Local, diff.Remote)
+	case "group_type":
+		return fmt.Sprintf("群组类型: %s -> %s", groupTypeText(diff.Local), groupTypeText(diff.Remote))
+	case "settings":
+		return fmt.Sprintf("群设置: %s -> %s", diff.Local, diff.Remote)
+	case "members":
+		added, removed := services.MemberChanges(splitList(diff.Local), splitList(diff.Remote))
+		text := "成员:"
+		if len(added) > 0 {
+			text += " 新增 " + strings.Join(added, ", ")
+		}
+		if len(removed) > 0 {
+			text += " 移除 " + strings.Join(removed, ", ")
+		}
+		return text
+	default:
+		return fmt.Sprintf("%s: %s -> %s", diff.Field, diff.Local, diff.Remote)
+	}
+}
+
+// splitList 拆分逗号分隔的列表，空字符串返回空列表
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// groupTypeText 返回群组类型的中文名称
+func groupTypeText(groupType string) string {
+	switch groupType {
+	case "external":
+		return "外部群"
+	case "internal":
+		return "内部群"
+	default:
+		return groupType
+	}
+}
+
+// adoptCmd 纳管已有群组命令
+var adoptCmd = &cobra.Command{
+	Use:   "adopt",
+	Short: "纳管已有群组",
+	Long:  "将在钉钉中已存在的群组（如同事或钉钉客户端创建的群组）加入本地存储和共享登记表，参与重复检查和成员管理",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groupID, _ := cmd.Flags().GetString("group-id")
+		if groupID == "" {
+			return fmt.Errorf("必须指定群组ID (--group-id)")
+		}
+		groupType, _ := cmd.Flags().GetString("group-type")
+		if groupType != "" && groupType != "internal" && groupType != "external" {
+			return fmt.Errorf("群组类型必须为 internal 或 external")
+		}
+
+		// 初始化服务
+		service := newGroupService()
+
+		group, err := service.AdoptGroup(cmd.Context(), groupID, groupType)
+		if err != nil {
+			return fmt.Errorf("纳管群组失败: %w", err)
+		}
+
+		fmt.Printf("已纳管群组: %s (%s)，群主: %s，成员数: %d\n", group.Name, group.ID, group.OwnerID, group.MemberCount)
+		return nil
+	},
+}
+
+// mockServerCmd 模拟钉钉服务器命令
+var mockServerCmd = &cobra.Command{
+	Use:   "mock-server",
+	Short: "启动模拟钉钉服务器",
+	Long: `启动一个模拟钉钉开放平台的本地HTTP服务器，用于开发、演示和集成测试。
+
+支持 /gettoken、/chat/create、/chat/get、/chat/update、/chat/addmember、/chat/removemember、
+/department/list、/department/get、/user/simplelist、/user/listbypage、/user/get、
+/user/get_by_mobile 接口，状态保存在内存中，可通过 --fixture 指定初始数据和故障注入规则
+（错误码、延迟、超时）。将配置文件中的 dingtalk.base_url 指向本服务即可使用。
+
+本命令不读取配置文件，不需要配置钉钉的 AppKey/AppSecret。`,
+	// 不加载配置，未配置钉钉凭据时也能启动
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		fixtureFile, _ := cmd.Flags().GetString("fixture")
+
+		var fixture *mockserver.Fixture
+		if fixtureFile != "" {
+			var err error
+			fixture, err = mockserver.LoadFixture(fixtureFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		server := &http.Server{
+			Addr:    addr,
+			Handler: mockserver.New(fixture, log.New(os.Stdout, "[mock] ", log.LstdFlags)).Handler(),
+		}
+
+		go func() {
+			<-cmd.Context().Done()
+			server.Close()
+		}()
+
+		fmt.Printf("模拟钉钉服务器已启动: http://%s\n", addr)
+		fmt.Printf("将配置中的 dingtalk.base_url 设置为 http://%s 即可使用，按 Ctrl-C 停止\n", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("模拟服务器运行失败: %w", err)
+		}
+
+		return nil
+	},
+}
+
+// newGroupService 根据全局配置创建群组服务
+func newGroupService() *services.GroupService {
+	client := dingtalk.NewClient(cfg)
+	store := storage.NewFileStorage(cfg.GetDataDir())
+	if err := store.SetEncoding(fileEncoding); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	service := services.NewGroupService(client, store, &cfg.Group)
+	service.SetDirectoryCache(storage.NewDirectoryCache(filepath.Join(cfg.GetDataDir(), "directory.json")))
+
+	if dryRun {
+		if dryRunLog == nil {
+			dryRunLog = services.NewDryRunLog()
+		}
+		if err := service.EnableDryRun(dryRunLog); err != nil {
+			fmt.Fprintf(os.Stderr, "开启演练模式失败: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	return service
+}
+
+// printDryRun 演练模式下列出本次命令将要执行但未执行的操作
+func printDryRun() {
+	if dryRunLog == nil {
+		return
+	}
+
+	operations := dryRunLog.Operations()
+	fmt.Println()
+	if len(operations) == 0 {
+		fmt.Println("[演练] 没有需要执行的钉钉调用或本地修改")
+		return
+	}
+
+	fmt.Printf("[演练] 以下 %d 项操作均未执行:\n", len(operations))
+	for i, op := range operations {
+		fmt.Printf("%3d. [%s] %s\n", i+1, dryRunTargetText(op.Target), op.Detail)
+	}
+}
+
+// dryRunTargetText 演练操作对象的显示文本
+func dryRunTargetText(target string) string {
+	switch target {
+	case models.DryRunTargetDingTalk:
+		return "钉钉"
+	case models.DryRunTargetRegistry:
+		return "共享登记表"
+	default:
+		return "本地存储"
+	}
+}
+
+// writeBatchReport 指定了报告文件时写入批量操作每一项的结果
+func writeBatchReport(reportFile string, results []models.BatchItemResult) error {
+	if reportFile == "" || len(results) == 0 {
+		return nil
+	}
+	if err := storage.ExportBatchReport(reportFile, results); err != nil {
+		return err
+	}
+	fmt.Printf("结果报告已写入: %s\n", reportFile)
+	return nil
+}
+
+// interruptedError 命令被信号中断时返回错误，使进程以非零状态退出
+func interruptedError(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("操作已被中断")
+	}
+	return nil
+}
+
 func init() {
 	// 根命令标志
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "配置文件路径")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "演练模式：照常校验并列出将要调用的钉钉接口和本地存储变更，不做任何修改")
 
 	// 创建群组命令标志
-	createCmd.Flags().StringP("file", "f", "", "CSV文件路径 (必需)")
+	createCmd.Flags().StringP("file", "f", "", "CSV或Excel(.xlsx)文件路径 (必需)")
+	createCmd.Flags().Bool("resume", false, "按操作日志继续上次中断的批量创建")
+	createCmd.Flags().Bool("retry-unknown", false, "与 --resume 一起使用，重新创建上次中断时结果未知的群组")
+	createCmd.Flags().Int("concurrency", 1, "同时创建的群组数量")
+	createCmd.Flags().String("report", "", "每行结果报告文件路径 (.csv 或 .json)")
+	createCmd.Flags().StringVar(&fileEncoding, "encoding", "auto", "CSV文件编码 (auto、utf-8、gbk、gb18030、utf-16 等)")
 	createCmd.MarkFlagRequired("file")
 
 	// 添加成员命令标志
-	addMemberCmd.Flags().StringP("user-id", "u", "", "用户ID (必需)")
+	addMemberCmd.Flags().StringP("user-id", "u", "", "用户ID，或 mobile:手机号、email:邮箱、name:姓名 (必需)")
 	addMemberCmd.Flags().StringP("group-id", "g", "", "群组ID")
 	addMemberCmd.Flags().BoolP("all-groups", "a", false, "添加到所有群组")
+	addMemberCmd.Flags().Int("concurrency", 1, "使用 --all-groups 时同时处理的群组数量")
+	addMemberCmd.Flags().String("report", "", "每个群组的结果报告文件路径 (.csv 或 .json)")
 	addMemberCmd.MarkFlagRequired("user-id")
 
 	// 移除成员命令标志
-	removeMemberCmd.Flags().StringP("user-id", "u", "", "用户ID (必需)")
+	removeMemberCmd.Flags().StringP("user-id", "u", "", "用户ID，或 mobile:手机号、email:邮箱、name:姓名 (必需)")
 	removeMemberCmd.Flags().StringP("group-id", "g", "", "群组ID")
 	removeMemberCmd.Flags().BoolP("all-groups", "a", false, "从所有群组移除")
+	removeMemberCmd.Flags().Int("concurrency", 1, "使用 --all-groups 时同时处理的群组数量")
+	removeMemberCmd.Flags().String("report", "", "每个群组的结果报告文件路径 (.csv 或 .json)")
 	removeMemberCmd.MarkFlagRequired("user-id")
 
 	// 导出命令标志
-	exportCmd.Flags().StringP("output", "o", "groups_export.csv", "输出CSV文件路径")
+	exportCmd.Flags().StringP("output", "o", "groups_export.csv", "输出文件路径 (.csv 或 .xlsx)")
+	exportCmd.Flags().StringVar(&fileEncoding, "encoding", "auto", "输出CSV文件的编码 (auto 即 utf-8，另有 utf-8-bom、gbk、gb18030、utf-16 等)，用 Excel 打开时建议 utf-8-bom")
 
 	// 检查命令标志
 	checkCmd.Flags().StringP("name", "n", "", "群组名称 (必需)")
 	checkCmd.MarkFlagRequired("name")
+	checkCmd.Flags().Bool("local-only", false, "只检查本地存储和共享登记表，不调用钉钉接口核实")
+
+	// 检查导入文件命令标志
+	validateCmd.Flags().StringP("file", "f", "", "CSV或Excel(.xlsx)文件路径 (必需)")
+	validateCmd.Flags().Bool("local-only", false, "不在企业通讯录中核实群主和成员")
+	validateCmd.Flags().StringVar(&fileEncoding, "encoding", "auto", "CSV文件编码 (auto、utf-8、gbk、gb18030、utf-16 等)")
+	validateCmd.MarkFlagRequired("file")
+
+	// 修改命令标志
+	updateCmd.Flags().StringP("group-id", "g", "", "群组ID")
+	updateCmd.Flags().StringP("name", "n", "", "当前群组名称")
+	updateCmd.Flags().StringP("file", "f", "", "批量修改的CSV或Excel(.xlsx)文件路径")
+	updateCmd.Flags().StringVar(&fileEncoding, "encoding", "auto", "CSV文件编码 (auto、utf-8、gbk、gb18030、utf-16 等)")
+	updateCmd.Flags().String("new-name", "", "新群名称")
+	updateCmd.Flags().StringP("description", "d", "", "新群描述（只保存在本地）")
+	updateCmd.Flags().String("owner", "", "新群主用户ID")
+	updateCmd.Flags().String("show-history", "", "新成员可查看历史消息: 是/否")
+	updateCmd.Flags().String("validation", "", "入群需要验证: 是/否")
+	updateCmd.Flags().String("searchable", "", "群可被搜索: 是/否")
+	updateCmd.Flags().String("mention-all", "", "@所有人权限: 所有人/仅群主")
+	updateCmd.Flags().String("management", "", "群管理权限: 所有人/仅群主")
+	updateCmd.Flags().String("chat-banned", "", "全员禁言: 是/否")
+
+	// 转移群主命令标志
+	transferOwnerCmd.Flags().String("from", "", "原群主用户ID (必需)")
+	transferOwnerCmd.Flags().String("to", "", "新群主用户ID (必需)")
+	transferOwnerCmd.Flags().Bool("verify", false, "转移前从钉钉核实当前群主")
+	transferOwnerCmd.Flags().String("report", "transfer_owner_report.csv", "结果报告CSV文件路径")
+	transferOwnerCmd.MarkFlagRequired("from")
+	transferOwnerCmd.MarkFlagRequired("to")
+
+	// 离职处理命令标志
+	offboardCmd.Flags().StringP("user-id", "u", "", "离职员工用户ID (必需)")
+	offboardCmd.Flags().String("successor", "", "继任群主用户ID（默认使用配置或部门主管）")
+	offboardCmd.Flags().Bool("local-only", false, "只按本地记录判断群成员，不从钉钉核实")
+	offboardCmd.Flags().String("report", "", "处理报告CSV文件路径")
+	offboardCmd.MarkFlagRequired("user-id")
+
+	// 入职命令标志
+	onboardCmd.Flags().StringP("user-id", "u", "", "新员工用户ID (必需)")
+	onboardCmd.MarkFlagRequired("user-id")
+
+	// 关联部门命令标志
+	linkDepartmentCmd.Flags().StringP("group-id", "g", "", "群组ID (必需)")
+	linkDepartmentCmd.Flags().Int64Slice("departments", nil, "部门ID列表，如 2,4")
+	linkDepartmentCmd.Flags().Bool("recursive", false, "包含下级部门")
+	linkDepartmentCmd.Flags().Bool("clear", false, "取消关联")
+	linkDepartmentCmd.MarkFlagRequired("group-id")
+
+	// 同步关联部门群组命令标志
+	reconcileCmd.Flags().StringP("group-id", "g", "", "只同步指定群组")
+
+	// 清单计划命令标志
+	planCmd.Flags().StringP("file", "f", "", "群组清单文件路径 (YAML/JSON，必需)")
+	planCmd.Flags().Bool("live", false, "与钉钉实时信息对比")
+	planCmd.Flags().StringP("out", "o", "", "保存执行计划的文件路径")
+	planCmd.MarkFlagRequired("file")
+
+	// 执行计划命令标志
+	applyCmd.Flags().String("plan", "", "plan --out 保存的执行计划文件")
+	applyCmd.Flags().StringP("file", "f", "", "群组清单文件路径，生成计划后直接执行")
+	applyCmd.Flags().Bool("live", false, "与 --file 一起使用时，与钉钉实时信息对比生成计划")
+
+	// 同步命令标志
+	syncCmd.Flags().Bool("check", false, "只检查差异，不修改本地记录；存在差异时以非零状态退出")
+
+	// 详情命令标志
+	showCmd.Flags().StringP("group-id", "g", "", "群组ID (必需)")
+	showCmd.MarkFlagRequired("group-id")
+
+	// 纳管命令标志
+	adoptCmd.Flags().StringP("group-id", "g", "", "群组ID (必需)")
+	adoptCmd.Flags().String("group-type", "", "群组类型: internal, external (默认按钉钉中的会话类型)")
+	adoptCmd.MarkFlagRequired("group-id")
+
+	// 模拟服务器命令标志
+	mockServerCmd.Flags().String("addr", "127.0.0.1:18089", "监听地址")
+	mockServerCmd.Flags().String("fixture", "", "初始数据文件路径 (JSON)")
 
 	// 添加子命令
 	rootCmd.AddCommand(createCmd)
@@ -318,20 +1364,54 @@ func init() {
 	rootCmd.AddCommand(removeMemberCmd)
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(transferOwnerCmd)
+	rootCmd.AddCommand(offboardCmd)
+	rootCmd.AddCommand(onboardCmd)
+	rootCmd.AddCommand(linkDepartmentCmd)
+	rootCmd.AddCommand(reconcileCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+	rootCmd.AddCommand(adoptCmd)
+	rootCmd.AddCommand(mockServerCmd)
 }
 
-func main() {
-	// 加载配置
+// loadConfig 在执行命令前加载配置，此时已解析 --config 参数
+func loadConfig(cmd *cobra.Command, args []string) error {
 	var err error
 	cfg, err = config.LoadConfig(configFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
-		os.Exit(1)
+		cmd.SilenceUsage = true
+		return fmt.Errorf("加载配置失败: %w", err)
 	}
+	return nil
+}
+
+func main() {
+	// 收到 Ctrl-C 或 SIGTERM 时取消上下文：批量操作停止发起新的调用，
+	// 已完成部分写入本地存储后输出汇总；再次按 Ctrl-C 将直接退出
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		fmt.Fprintln(os.Stderr, "\n收到中断信号，正在停止，请等待当前操作完成...")
+		cancel()
+	}()
 
 	// 执行命令
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.ExecuteContext(ctx)
+	printDryRun()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "执行命令失败: %v\n", err)
+		if hint := dingtalk.ErrorHint(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "提示: %s\n", hint)
+		}
 		os.Exit(1)
 	}
 }
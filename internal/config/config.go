@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -17,11 +18,30 @@ type Config struct {
 
 // DingTalkConfig 钉钉应用配置
 type DingTalkConfig struct {
-	AppKey      string `mapstructure:"app_key"`
-	AppSecret   string `mapstructure:"app_secret"`
-	AccessToken string `mapstructure:"access_token"`
-	CorpID      string `mapstructure:"corp_id"`
-	BaseURL     string `mapstructure:"base_url"`
+	AppKey      string          `mapstructure:"app_key"`
+	AppSecret   string          `mapstructure:"app_secret"`
+	AccessToken string          `mapstructure:"access_token"`
+	CorpID      string          `mapstructure:"corp_id"`
+	BaseURL     string          `mapstructure:"base_url"`
+	TokenCache  bool            `mapstructure:"token_cache"` // 是否将访问令牌缓存到数据目录
+	Timeout     time.Duration   `mapstructure:"timeout"`     // 单次请求超时时间
+	Retry       RetryConfig     `mapstructure:"retry"`
+	RateLimit   RateLimitConfig `mapstructure:"rate_limit"`
+}
+
+// RateLimitConfig 客户端QPS限流配置
+type RateLimitConfig struct {
+	QPS       float64            `mapstructure:"qps"`       // 全部接口合计QPS，0表示不限制
+	Burst     int                `mapstructure:"burst"`     // 允许的突发请求数
+	Endpoints map[string]float64 `mapstructure:"endpoints"` // 按接口路径单独限制的QPS，如 chat/addmember: 10
+}
+
+// RetryConfig 接口调用重试配置
+type RetryConfig struct {
+	MaxAttempts     int           `mapstructure:"max_attempts"`     // 最大尝试次数（含首次）
+	InitialInterval time.Duration `mapstructure:"initial_interval"` // 首次重试等待时间
+	MaxInterval     time.Duration `mapstructure:"max_interval"`     // 单次等待时间上限
+	MaxElapsed      time.Duration `mapstructure:"max_elapsed"`      // 重试总耗时上限，0表示不限制
 }
 
 // AppConfig 应用配置
@@ -33,8 +53,21 @@ type AppConfig struct {
 
 // GroupConfig 群组配置
 type GroupConfig struct {
-	DefaultOwner    string               `mapstructure:"default_owner"`
-	DefaultSettings GroupDefaultSettings `mapstructure:"default_settings"`
+	DefaultOwner      string               `mapstructure:"default_owner"`
+	DefaultSettings   GroupDefaultSettings `mapstructure:"default_settings"`
+	RegistryFile      string               `mapstructure:"registry_file"`       // 团队共享的群组登记表路径，为空表示不使用
+	OffboardSuccessor string               `mapstructure:"offboard_successor"`  // 员工离职时默认的继任群主，为空时使用部门主管
+	OnboardRules      []OnboardRule        `mapstructure:"onboard_rules"`       // 新员工入职时的自动入群规则
+	DirectoryCacheTTL time.Duration        `mapstructure:"directory_cache_ttl"` // 本地通讯录缓存有效期，按手机号查询用户的接口不可用时使用
+}
+
+// OnboardRule 入职自动入群规则，所有已填写的条件都满足时规则生效，未填写条件的规则匹配所有员工
+type OnboardRule struct {
+	Name                  string   `mapstructure:"name"`                    // 规则名称
+	Departments           []int64  `mapstructure:"departments"`             // 员工属于其中任一部门
+	IncludeSubDepartments bool     `mapstructure:"include_sub_departments"` // 部门条件是否包含下级部门
+	Positions             []string `mapstructure:"positions"`               // 员工职位包含其中任一关键字
+	Groups                []string `mapstructure:"groups"`                  // 要加入的群组（群组ID或群名称）
 }
 
 // GroupDefaultSettings 群组默认设置
@@ -93,12 +126,21 @@ func LoadConfig(configPath string) (*Config, error) {
 // setDefaults 设置默认配置值
 func setDefaults() {
 	viper.SetDefault("dingtalk.base_url", "https://oapi.dingtalk.com")
+	viper.SetDefault("dingtalk.token_cache", false)
+	viper.SetDefault("dingtalk.timeout", "30s")
+	viper.SetDefault("dingtalk.retry.max_attempts", 3)
+	viper.SetDefault("dingtalk.retry.initial_interval", "500ms")
+	viper.SetDefault("dingtalk.retry.max_interval", "10s")
+	viper.SetDefault("dingtalk.retry.max_elapsed", "1m")
+	viper.SetDefault("dingtalk.rate_limit.qps", 20)
+	viper.SetDefault("dingtalk.rate_limit.burst", 5)
 	viper.SetDefault("app.data_dir", "./data")
 	viper.SetDefault("app.log_level", "info")
 	viper.SetDefault("app.debug", false)
 	viper.SetDefault("group.default_settings.allow_member_invite", true)
 	viper.SetDefault("group.default_settings.allow_member_view", true)
 	viper.SetDefault("group.default_settings.allow_member_edit_name", false)
+	viper.SetDefault("group.directory_cache_ttl", "24h")
 }
 
 // validateConfig 验证配置
@@ -2,88 +2,194 @@ package dingtalk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"ti-dding/internal/config"
 	"ti-dding/internal/models"
 )
 
+// GroupAPI 群组服务依赖的钉钉群会话接口
+//
+// *Client 是其真实实现，dingtalktest.Fake 提供用于测试的内存实现。
+type GroupAPI interface {
+	CreateGroup(ctx context.Context, req *models.GroupCreateRequest) (*models.GroupCreateResponse, error)
+	GetGroup(ctx context.Context, chatID string) (*models.ChatInfo, error)
+	UpdateGroup(ctx context.Context, req *models.GroupUpdateRequest) error
+	AddGroupMembers(ctx context.Context, groupID string, userIDs []string) error
+	RemoveGroupMembers(ctx context.Context, groupID string, userIDs []string) error
+}
+
+var _ GroupAPI = (*Client)(nil)
+
 // Client 钉钉API客户端
 type Client struct {
-	config      *config.Config
-	httpClient  *http.Client
-	baseURL     string
-	accessToken string
+	config     *config.Config
+	httpClient *http.Client
+	baseURL    string
+	timeout    time.Duration
+	retry      retryPolicy
+	limiter    *RateLimiter
+	dryRun     *dryRunState
+
+	tokenMu        sync.Mutex
+	accessToken    string
+	tokenExpiresAt time.Time
+	revokedToken   string // 最近作废的令牌，缓存文件中仍是该令牌时不再读取
+}
+
+// apiStatus 钉钉接口通用的错误码字段
+type apiStatus struct {
+	Errcode   int    `json:"errcode"`
+	Errmsg    string `json:"errmsg"`
+	RequestID string `json:"request_id"`
+
+	httpStatus int
 }
 
 // NewClient 创建新的钉钉客户端
 func NewClient(cfg *config.Config) *Client {
 	return &Client{
-		config: cfg,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		config:      cfg,
+		timeout:     cfg.DingTalk.Timeout,
+		retry:       newRetryPolicy(cfg.DingTalk.Retry),
+		limiter:     NewRateLimiter(cfg.DingTalk.RateLimit),
+		httpClient:  &http.Client{},
 		baseURL:     cfg.DingTalk.BaseURL,
 		accessToken: cfg.GetAccessToken(),
 	}
 }
 
-// GetAccessToken 获取访问令牌
-func (c *Client) GetAccessToken() (string, error) {
-	if c.accessToken != "" {
-		return c.accessToken, nil
+// status 返回响应中的错误码字段，供 doRequest 判断令牌是否失效
+func (s *apiStatus) status() *apiStatus {
+	return s
+}
+
+// apiResult 嵌入了 apiStatus 的响应结构
+type apiResult interface {
+	status() *apiStatus
+}
+
+// nonIdempotentPaths 重复调用会产生副作用的接口
+var nonIdempotentPaths = map[string]bool{
+	"/chat/create": true,
+}
+
+// doRequest 携带访问令牌调用钉钉接口
+//
+// 接口返回令牌无效或过期的错误码时，会作废当前令牌、重新获取并重放一次请求；
+// 可重试的网络错误和错误码按重试策略退避重试。接口返回非零错误码时返回
+// *APIError，成功时响应解析到 result。演练模式下修改类请求只记录不发送。
+func (c *Client) doRequest(ctx context.Context, method, path string, query url.Values, payload interface{}, result apiResult, action string) error {
+	if c.dryRun != nil && method != http.MethodGet {
+		c.dryRun.recordCall(method, path, payload)
+		return nil
+	}
+
+	idempotent := !nonIdempotentPaths[path]
+	replayed := false
+
+	return c.withRetry(ctx, action, path, idempotent, result, func() error {
+		for {
+			token, err := c.GetAccessToken(ctx)
+			if err != nil {
+				return &permanentError{err: err}
+			}
+
+			params := url.Values{}
+			for k, v := range query {
+				params[k] = v
+			}
+			params.Set("access_token", token)
+
+			*result.status() = apiStatus{}
+			if err := c.send(ctx, method, path, params, payload, result, action); err != nil {
+				return err
+			}
+
+			if !replayed && isTokenInvalidCode(result.status().Errcode) && c.canRefreshToken() {
+				replayed = true
+				c.invalidateToken(token)
+				continue
+			}
+
+			return nil
+		}
+	})
+}
+
+// canRefreshToken 是否可以通过AppKey和AppSecret重新获取令牌
+func (c *Client) canRefreshToken() bool {
+	return c.config.DingTalk.AppKey != "" && c.config.DingTalk.AppSecret != ""
+}
+
+// send 发送一次HTTP请求并将响应解析到 result
+//
+// 每次请求的超时时间由 dingtalk.timeout 配置，ctx 取消时请求立即中止。
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, payload interface{}, result apiResult, action string) error {
+	reqURL := c.baseURL + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
 	}
 
-	// 通过AppKey和AppSecret获取访问令牌
-	if c.config.DingTalk.AppKey == "" || c.config.DingTalk.AppSecret == "" {
-		return "", fmt.Errorf("AppKey和AppSecret不能为空")
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("序列化请求数据失败: %w", err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	if err := c.limiter.Wait(ctx, path); err != nil {
+		return err
 	}
 
-	params := url.Values{}
-	params.Set("appkey", c.config.DingTalk.AppKey)
-	params.Set("appsecret", c.config.DingTalk.AppSecret)
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
 
-	resp, err := c.httpClient.Get(fmt.Sprintf("%s/gettoken?%s", c.baseURL, params.Encode()))
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
-		return "", fmt.Errorf("获取访问令牌失败: %w", err)
+		return fmt.Errorf("%s请求失败: %w", action, err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return fmt.Errorf("%s请求失败: %w", action, err)
 	}
+	defer resp.Body.Close()
 
-	var result struct {
-		Errcode int    `json:"errcode"`
-		Errmsg  string `json:"errmsg"`
-		Token   string `json:"access_token"`
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", fmt.Errorf("解析响应失败: %w", err)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return &APIError{Op: action, Endpoint: path, HTTPStatus: resp.StatusCode}
 	}
 
-	if result.Errcode != 0 {
-		return "", fmt.Errorf("获取访问令牌失败: %s", result.Errmsg)
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
 	}
+	result.status().httpStatus = resp.StatusCode
 
-	c.accessToken = result.Token
-	return result.Token, nil
+	return nil
 }
 
-// CreateGroup 创建群组
-func (c *Client) CreateGroup(req *models.GroupCreateRequest) (*models.GroupCreateResponse, error) {
-	token, err := c.GetAccessToken()
-	if err != nil {
-		return nil, err
-	}
-
+// CreateGroup 创建群组，接口返回错误码时返回 *APIError
+func (c *Client) CreateGroup(ctx context.Context, req *models.GroupCreateRequest) (*models.GroupCreateResponse, error) {
 	// 构建钉钉API请求参数
 	apiReq := map[string]interface{}{
 		"name":        req.Name,
@@ -111,39 +217,17 @@ func (c *Client) CreateGroup(req *models.GroupCreateRequest) (*models.GroupCreat
 		}
 	}
 
-	jsonData, err := json.Marshal(apiReq)
-	if err != nil {
-		return nil, fmt.Errorf("序列化请求数据失败: %w", err)
-	}
-
 	// 发送请求
-	url := fmt.Sprintf("%s/chat/create?access_token=%s", c.baseURL, token)
-	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("创建群组请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
 	var result struct {
-		Errcode int    `json:"errcode"`
-		Errmsg  string `json:"errmsg"`
-		ChatID  string `json:"chatid"`
+		apiStatus
+		ChatID string `json:"chatid"`
 	}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+	if err := c.doRequest(ctx, "POST", "/chat/create", nil, apiReq, &result, "创建群组"); err != nil {
+		return nil, err
 	}
-
-	if result.Errcode != 0 {
-		return &models.GroupCreateResponse{
-			Success: false,
-			Message: fmt.Sprintf("创建群组失败: %s", result.Errmsg),
-		}, nil
+	if c.dryRun != nil {
+		result.ChatID = c.dryRun.createChat(req)
 	}
 
 	return &models.GroupCreateResponse{
@@ -153,9 +237,35 @@ func (c *Client) CreateGroup(req *models.GroupCreateRequest) (*models.GroupCreat
 	}, nil
 }
 
+// GetGroup 获取群会话的实时信息
+//
+// 群组已解散或ID无效时返回错误码为 ErrcodeChatNotFound 的 *APIError。
+func (c *Client) GetGroup(ctx context.Context, chatID string) (*models.ChatInfo, error) {
+	if info, ok := c.dryRun.chat(chatID); ok {
+		return info, nil
+	}
+
+	query := url.Values{}
+	query.Set("chatid", chatID)
+
+	var result struct {
+		apiStatus
+		ChatInfo models.ChatInfo `json:"chat_info"`
+	}
+
+	if err := c.doRequest(ctx, "GET", "/chat/get", query, nil, &result, "获取群组信息"); err != nil {
+		return nil, err
+	}
+
+	if result.ChatInfo.ChatID == "" {
+		result.ChatInfo.ChatID = chatID
+	}
+	return &result.ChatInfo, nil
+}
+
 // GetGroupList 获取群组列表
-func (c *Client) GetGroupList() ([]models.Group, error) {
-	_, err := c.GetAccessToken()
+func (c *Client) GetGroupList(ctx context.Context) ([]models.Group, error) {
+	_, err := c.GetAccessToken(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -166,101 +276,95 @@ func (c *Client) GetGroupList() ([]models.Group, error) {
 	return []models.Group{}, nil
 }
 
-// AddGroupMembers 添加群组成员
-func (c *Client) AddGroupMembers(groupID string, userIDs []string) error {
-	token, err := c.GetAccessToken()
-	if err != nil {
-		return err
-	}
-
+// UpdateGroup 修改群名称、群主和群设置，只提交非空字段
+//
+// 新群主必须已是群成员。描述只保存在本地，不提交给钉钉。
+func (c *Client) UpdateGroup(ctx context.Context, req *models.GroupUpdateRequest) error {
 	apiReq := map[string]interface{}{
-		"chatid":     groupID,
-		"useridlist": userIDs,
+		"chatid": req.GroupID,
 	}
-
-	jsonData, err := json.Marshal(apiReq)
-	if err != nil {
-		return fmt.Errorf("序列化请求数据失败: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/chat/addmember?access_token=%s", c.baseURL, token)
-	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("添加成员请求失败: %w", err)
+	if req.Name != "" {
+		apiReq["name"] = req.Name
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("读取响应失败: %w", err)
+	if req.OwnerID != "" {
+		apiReq["owner"] = req.OwnerID
 	}
 
-	var result struct {
-		Errcode int    `json:"errcode"`
-		Errmsg  string `json:"errmsg"`
+	settings := map[string]*int{
+		"showHistoryType":     req.Settings.ShowHistoryType,
+		"validationType":      req.Settings.ValidationType,
+		"searchable":          req.Settings.Searchable,
+		"mentionAllAuthority": req.Settings.MentionAllAuthority,
+		"managementType":      req.Settings.ManagementType,
+		"chatBannedType":      req.Settings.ChatBannedType,
 	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return fmt.Errorf("解析响应失败: %w", err)
+	for key, value := range settings {
+		if value != nil {
+			apiReq[key] = *value
+		}
 	}
 
-	if result.Errcode != 0 {
-		return fmt.Errorf("添加成员失败: %s", result.Errmsg)
+	var result apiStatus
+	if err := c.doRequest(ctx, "POST", "/chat/update", nil, apiReq, &result, "修改群组"); err != nil {
+		return err
 	}
+	c.dryRun.updateChat(req.GroupID, func(info *models.ChatInfo) {
+		if req.Name != "" {
+			info.Name = req.Name
+		}
+		if req.OwnerID != "" {
+			info.OwnerID = req.OwnerID
+		}
+	})
 
 	return nil
 }
 
-// RemoveGroupMembers 移除群组成员
-func (c *Client) RemoveGroupMembers(groupID string, userIDs []string) error {
-	token, err := c.GetAccessToken()
-	if err != nil {
-		return err
-	}
-
+// AddGroupMembers 添加群组成员
+func (c *Client) AddGroupMembers(ctx context.Context, groupID string, userIDs []string) error {
 	apiReq := map[string]interface{}{
 		"chatid":     groupID,
 		"useridlist": userIDs,
 	}
 
-	jsonData, err := json.Marshal(apiReq)
-	if err != nil {
-		return fmt.Errorf("序列化请求数据失败: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/chat/removemember?access_token=%s", c.baseURL, token)
-	resp, err := c.httpClient.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("移除成员请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("读取响应失败: %w", err)
+	var result apiStatus
+	if err := c.doRequest(ctx, "POST", "/chat/addmember", nil, apiReq, &result, "添加成员"); err != nil {
+		return err
 	}
+	c.dryRun.updateChat(groupID, func(info *models.ChatInfo) {
+		info.Members = append(info.Members, userIDs...)
+	})
 
-	var result struct {
-		Errcode int    `json:"errcode"`
-		Errmsg  string `json:"errmsg"`
-	}
+	return nil
+}
 
-	if err := json.Unmarshal(body, &result); err != nil {
-		return fmt.Errorf("解析响应失败: %w", err)
+// RemoveGroupMembers 移除群组成员
+func (c *Client) RemoveGroupMembers(ctx context.Context, groupID string, userIDs []string) error {
+	apiReq := map[string]interface{}{
+		"chatid":     groupID,
+		"useridlist": userIDs,
 	}
 
-	if result.Errcode != 0 {
-		return fmt.Errorf("移除成员失败: %s", result.Errmsg)
+	var result apiStatus
+	if err := c.doRequest(ctx, "POST", "/chat/removemember", nil, apiReq, &result, "移除成员"); err != nil {
+		return err
 	}
+	c.dryRun.updateChat(groupID, func(info *models.ChatInfo) {
+		var kept []string
+		for _, member := range info.Members {
+			removed := false
+			for _, userID := range userIDs {
+				if member == userID {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				kept = append(kept, member)
+			}
+		}
+		info.Members = kept
+	})
 
 	return nil
 }
-
-// CheckGroupExists 检查群组是否存在
-func (c *Client) CheckGroupExists(groupName string) (bool, error) {
-	// 钉钉API没有直接检查群名是否存在的接口
-	// 这里可以通过获取群组列表来检查
-	// 实际实现可能需要结合其他方式
-	_ = groupName // 暂时未使用，避免linter警告
-	return false, nil
-}
@@ -0,0 +1,148 @@
+package dingtalk
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"ti-dding/internal/models"
+)
+
+// simpleListPageSize user/simplelist 和 user/listbypage 每页最大条数
+const simpleListPageSize = 100
+
+// DirectoryAPI 通讯录接口
+type DirectoryAPI interface {
+	ListDepartments(ctx context.Context) ([]models.Department, error)
+	GetDepartment(ctx context.Context, departmentID int64) (*models.Department, error)
+	ListDepartmentUsers(ctx context.Context, departmentID int64) ([]models.User, error)
+	ListDepartmentUserDetails(ctx context.Context, departmentID int64) ([]models.User, error)
+	GetUser(ctx context.Context, userID string) (*models.User, error)
+	GetUserIDByMobile(ctx context.Context, mobile string) (string, error)
+}
+
+var _ DirectoryAPI = (*Client)(nil)
+
+// ListDepartments 获取企业部门列表
+func (c *Client) ListDepartments(ctx context.Context) ([]models.Department, error) {
+	var result struct {
+		apiStatus
+		Departments []models.Department `json:"department"`
+	}
+
+	if err := c.doRequest(ctx, "GET", "/department/list", nil, nil, &result, "获取部门列表"); err != nil {
+		return nil, err
+	}
+
+	return result.Departments, nil
+}
+
+// GetDepartment 获取部门详情，包含部门主管
+func (c *Client) GetDepartment(ctx context.Context, departmentID int64) (*models.Department, error) {
+	query := url.Values{}
+	query.Set("id", strconv.FormatInt(departmentID, 10))
+
+	var result struct {
+		apiStatus
+		models.Department
+	}
+
+	if err := c.doRequest(ctx, "GET", "/department/get", query, nil, &result, "获取部门详情"); err != nil {
+		return nil, err
+	}
+
+	return &result.Department, nil
+}
+
+// ListDepartmentUsers 获取部门成员列表（仅包含用户ID和姓名）
+func (c *Client) ListDepartmentUsers(ctx context.Context, departmentID int64) ([]models.User, error) {
+	var users []models.User
+
+	for offset := 0; ; offset += simpleListPageSize {
+		query := url.Values{}
+		query.Set("department_id", strconv.FormatInt(departmentID, 10))
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("size", strconv.Itoa(simpleListPageSize))
+
+		var result struct {
+			apiStatus
+			HasMore bool          `json:"hasMore"`
+			Users   []models.User `json:"userlist"`
+		}
+
+		if err := c.doRequest(ctx, "GET", "/user/simplelist", query, nil, &result, "获取部门成员"); err != nil {
+			return nil, err
+		}
+
+		users = append(users, result.Users...)
+		if !result.HasMore || len(result.Users) == 0 {
+			break
+		}
+	}
+
+	return users, nil
+}
+
+// ListDepartmentUserDetails 获取部门成员的详细信息（含手机号、邮箱、职位）
+func (c *Client) ListDepartmentUserDetails(ctx context.Context, departmentID int64) ([]models.User, error) {
+	var users []models.User
+
+	for offset := 0; ; offset += simpleListPageSize {
+		query := url.Values{}
+		query.Set("department_id", strconv.FormatInt(departmentID, 10))
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("size", strconv.Itoa(simpleListPageSize))
+
+		var result struct {
+			apiStatus
+			HasMore bool          `json:"hasMore"`
+			Users   []models.User `json:"userlist"`
+		}
+
+		if err := c.doRequest(ctx, "GET", "/user/listbypage", query, nil, &result, "获取部门成员详情"); err != nil {
+			return nil, err
+		}
+
+		users = append(users, result.Users...)
+		if !result.HasMore || len(result.Users) == 0 {
+			break
+		}
+	}
+
+	return users, nil
+}
+
+// GetUserIDByMobile 根据手机号获取用户ID，手机号不属于企业成员时返回错误码为
+// ErrcodeUserNotFound 的 *APIError
+func (c *Client) GetUserIDByMobile(ctx context.Context, mobile string) (string, error) {
+	query := url.Values{}
+	query.Set("mobile", mobile)
+
+	var result struct {
+		apiStatus
+		UserID string `json:"userid"`
+	}
+
+	if err := c.doRequest(ctx, "GET", "/user/get_by_mobile", query, nil, &result, "根据手机号获取用户"); err != nil {
+		return "", err
+	}
+
+	return result.UserID, nil
+}
+
+// GetUser 获取用户详细信息
+func (c *Client) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	query := url.Values{}
+	query.Set("userid", userID)
+
+	var result struct {
+		apiStatus
+		models.User
+	}
+
+	if err := c.doRequest(ctx, "GET", "/user/get", query, nil, &result, "获取用户详情"); err != nil {
+		return nil, err
+	}
+
+	return &result.User, nil
+}
@@ -0,0 +1,401 @@
+// Package dingtalktest 提供钉钉接口的内存实现，用于在没有真实钉钉企业的情况下
+// 测试群组服务的批量创建和成员管理逻辑。
+package dingtalktest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// 方法名，用于 FailNext 和 Call.Method
+const (
+	MethodCreateGroup        = "CreateGroup"
+	MethodGetGroup           = "GetGroup"
+	MethodUpdateGroup        = "UpdateGroup"
+	MethodAddGroupMembers    = "AddGroupMembers"
+	MethodRemoveGroupMembers = "RemoveGroupMembers"
+)
+
+// endpoints 方法对应的接口路径
+var endpoints = map[string]string{
+	MethodCreateGroup:        "/chat/create",
+	MethodGetGroup:           "/chat/get",
+	MethodUpdateGroup:        "/chat/update",
+	MethodAddGroupMembers:    "/chat/addmember",
+	MethodRemoveGroupMembers: "/chat/removemember",
+}
+
+// ops 方法对应的操作描述
+var ops = map[string]string{
+	MethodCreateGroup:        "创建群组",
+	MethodGetGroup:           "获取群组信息",
+	MethodUpdateGroup:        "修改群组",
+	MethodAddGroupMembers:    "添加成员",
+	MethodRemoveGroupMembers: "移除成员",
+}
+
+// Chat 群会话状态
+type Chat struct {
+	ChatID      string
+	Name        string
+	Description string
+	OwnerID     string
+	Members     []string
+	External    bool
+	Settings    models.ChatSettings
+}
+
+// Call 一次接口调用记录
+type Call struct {
+	Method  string
+	ChatID  string
+	UserIDs []string
+	Request *models.GroupCreateRequest
+	Update  *models.GroupUpdateRequest
+}
+
+// Fake 有状态的钉钉群会话接口内存实现，可并发使用
+type Fake struct {
+	mu        sync.Mutex
+	chats     map[string]*Chat
+	users     map[string]bool
+	nextID    int
+	latency   time.Duration
+	failures  map[string][]error
+	failureFn func(Call) error
+	calls     []Call
+}
+
+var _ dingtalk.GroupAPI = (*Fake)(nil)
+
+// NewFake 创建空的内存实现
+func NewFake() *Fake {
+	return &Fake{
+		chats:    make(map[string]*Chat),
+		users:    make(map[string]bool),
+		failures: make(map[string][]error),
+	}
+}
+
+// SetLatency 设置每次调用的延迟，延迟期间 ctx 取消时调用返回 ctx 的错误
+func (f *Fake) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// SetDirectory 设置企业通讯录中的用户ID，设置后引用其他用户的调用将返回
+// 找不到该用户的错误；未设置时接受任意用户ID
+func (f *Fake) SetDirectory(userIDs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.users = make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		f.users[id] = true
+	}
+}
+
+// FailNext 让指定方法接下来的调用依次返回 errs 中的错误
+func (f *Fake) FailNext(method string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[method] = append(f.failures[method], errs...)
+}
+
+// FailWhen 设置按调用内容决定是否失败的函数，返回非nil错误时调用失败且不修改状态
+func (f *Fake) FailWhen(fn func(Call) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failureFn = fn
+}
+
+// AddChat 预置一个群会话，ChatID 为空时自动生成，返回群会话ID
+func (f *Fake) AddChat(chat Chat) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if chat.ChatID == "" {
+		chat.ChatID = f.newChatID()
+	}
+	chat.Members = withMember(chat.Members, chat.OwnerID)
+	f.chats[chat.ChatID] = &chat
+	return chat.ChatID
+}
+
+// RemoveChat 删除群会话，模拟在钉钉中解散群组
+func (f *Fake) RemoveChat(chatID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.chats, chatID)
+}
+
+// Chat 返回群会话的副本
+func (f *Fake) Chat(chatID string) (Chat, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chat, ok := f.chats[chatID]
+	if !ok {
+		return Chat{}, false
+	}
+	return copyChat(chat), true
+}
+
+// Chats 返回全部群会话的副本，按群会话ID排序
+func (f *Fake) Chats() []Chat {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chats := make([]Chat, 0, len(f.chats))
+	for _, chat := range f.chats {
+		chats = append(chats, copyChat(chat))
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].ChatID < chats[j].ChatID })
+	return chats
+}
+
+// Calls 返回全部调用记录
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CreateGroup 创建群会话
+func (f *Fake) CreateGroup(ctx context.Context, req *models.GroupCreateRequest) (*models.GroupCreateResponse, error) {
+	call := Call{Method: MethodCreateGroup, UserIDs: append([]string(nil), req.MemberIDs...), Request: req}
+	if err := f.begin(ctx, call); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if req.Name == "" || req.OwnerID == "" {
+		return nil, NewAPIError(MethodCreateGroup, dingtalk.ErrcodeInvalidParam, "群名称和群主不能为空")
+	}
+	if err := f.checkUsers(MethodCreateGroup, append([]string{req.OwnerID}, req.MemberIDs...)); err != nil {
+		return nil, err
+	}
+
+	chat := &Chat{
+		ChatID:      f.newChatID(),
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerID:     req.OwnerID,
+		Members:     withMember(append([]string(nil), req.MemberIDs...), req.OwnerID),
+		External:    req.IsExternal || req.GroupType == "external",
+	}
+	f.chats[chat.ChatID] = chat
+
+	return &models.GroupCreateResponse{
+		GroupID: chat.ChatID,
+		Success: true,
+		Message: "群组创建成功",
+	}, nil
+}
+
+// GetGroup 获取群会话信息
+func (f *Fake) GetGroup(ctx context.Context, chatID string) (*models.ChatInfo, error) {
+	if err := f.begin(ctx, Call{Method: MethodGetGroup, ChatID: chatID}); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chat, ok := f.chats[chatID]
+	if !ok {
+		return nil, NewAPIError(MethodGetGroup, dingtalk.ErrcodeChatNotFound, "无效的会话ID")
+	}
+
+	info := &models.ChatInfo{
+		ChatID:          chat.ChatID,
+		Name:            chat.Name,
+		OwnerID:         chat.OwnerID,
+		Members:         append([]string(nil), chat.Members...),
+		ConversationTag: 1,
+		ChatSettings:    chat.Settings,
+	}
+	if chat.External {
+		info.ConversationTag = 2
+	}
+	return info, nil
+}
+
+// UpdateGroup 修改群名称、群主和群设置，新群主必须已是群成员
+func (f *Fake) UpdateGroup(ctx context.Context, req *models.GroupUpdateRequest) error {
+	if err := f.begin(ctx, Call{Method: MethodUpdateGroup, ChatID: req.GroupID, Update: req}); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chat, ok := f.chats[req.GroupID]
+	if !ok {
+		return NewAPIError(MethodUpdateGroup, dingtalk.ErrcodeChatNotFound, "无效的会话ID")
+	}
+	if req.OwnerID != "" {
+		if err := f.checkUsers(MethodUpdateGroup, []string{req.OwnerID}); err != nil {
+			return err
+		}
+		if !contains(chat.Members, req.OwnerID) {
+			return NewAPIError(MethodUpdateGroup, dingtalk.ErrcodeInvalidParam, fmt.Sprintf("新群主不是群成员: %s", req.OwnerID))
+		}
+		chat.OwnerID = req.OwnerID
+	}
+	if req.Name != "" {
+		chat.Name = req.Name
+	}
+	req.Settings.Apply(&chat.Settings)
+	return nil
+}
+
+// AddGroupMembers 添加群成员，已在群中的成员忽略
+func (f *Fake) AddGroupMembers(ctx context.Context, groupID string, userIDs []string) error {
+	call := Call{Method: MethodAddGroupMembers, ChatID: groupID, UserIDs: append([]string(nil), userIDs...)}
+	if err := f.begin(ctx, call); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chat, ok := f.chats[groupID]
+	if !ok {
+		return NewAPIError(MethodAddGroupMembers, dingtalk.ErrcodeChatNotFound, "无效的会话ID")
+	}
+	if err := f.checkUsers(MethodAddGroupMembers, userIDs); err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		chat.Members = withMember(chat.Members, userID)
+	}
+	return nil
+}
+
+// RemoveGroupMembers 移除群成员，不在群中的成员忽略，群主不会被移除
+func (f *Fake) RemoveGroupMembers(ctx context.Context, groupID string, userIDs []string) error {
+	call := Call{Method: MethodRemoveGroupMembers, ChatID: groupID, UserIDs: append([]string(nil), userIDs...)}
+	if err := f.begin(ctx, call); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chat, ok := f.chats[groupID]
+	if !ok {
+		return NewAPIError(MethodRemoveGroupMembers, dingtalk.ErrcodeChatNotFound, "无效的会话ID")
+	}
+
+	removed := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if userID != chat.OwnerID {
+			removed[userID] = true
+		}
+	}
+
+	members := chat.Members[:0]
+	for _, member := range chat.Members {
+		if !removed[member] {
+			members = append(members, member)
+		}
+	}
+	chat.Members = members
+	return nil
+}
+
+// NewAPIError 构造与真实客户端一致的钉钉错误，method 为 Method* 常量之一
+func NewAPIError(method string, errcode int, errmsg string) *dingtalk.APIError {
+	return &dingtalk.APIError{
+		Op:         ops[method],
+		Endpoint:   endpoints[method],
+		Errcode:    errcode,
+		Errmsg:     errmsg,
+		HTTPStatus: 200,
+		Attempts:   1,
+	}
+}
+
+// begin 记录调用、模拟延迟并返回预设的失败
+func (f *Fake) begin(ctx context.Context, call Call) error {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	latency := f.latency
+	var err error
+	if queued := f.failures[call.Method]; len(queued) > 0 {
+		err = queued[0]
+		f.failures[call.Method] = queued[1:]
+	} else if f.failureFn != nil {
+		err = f.failureFn(call)
+	}
+	f.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// checkUsers 校验用户是否在通讯录中，调用方需持有 mu
+func (f *Fake) checkUsers(method string, userIDs []string) error {
+	if len(f.users) == 0 {
+		return nil
+	}
+	for _, userID := range userIDs {
+		if !f.users[userID] {
+			return NewAPIError(method, dingtalk.ErrcodeUserNotFound, fmt.Sprintf("找不到该用户: %s", userID))
+		}
+	}
+	return nil
+}
+
+// newChatID 生成群会话ID，调用方需持有 mu
+func (f *Fake) newChatID() string {
+	f.nextID++
+	return fmt.Sprintf("chat%08d", f.nextID)
+}
+
+// withMember 将用户加入成员列表（已存在时不重复添加）
+func withMember(members []string, userID string) []string {
+	if userID == "" || contains(members, userID) {
+		return members
+	}
+	return append(members, userID)
+}
+
+// contains 检查成员列表中是否包含该用户
+func contains(members []string, userID string) bool {
+	for _, member := range members {
+		if member == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// copyChat 复制群会话，避免调用方修改内部状态
+func copyChat(chat *Chat) Chat {
+	c := *chat
+	c.Members = append([]string(nil), chat.Members...)
+	return c
+}
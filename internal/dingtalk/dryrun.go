@@ -0,0 +1,107 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"ti-dding/internal/models"
+)
+
+// dryRunIDPrefix 演练模式下创建群组返回的临时群组ID前缀
+const dryRunIDPrefix = "dryrun-"
+
+// DryRunner 支持演练模式的钉钉客户端
+type DryRunner interface {
+	SetDryRun(record func(call string))
+}
+
+var _ DryRunner = (*Client)(nil)
+
+// dryRunState 演练模式状态，记录演练中"创建"的群组，使后续查询能得到一致的结果
+type dryRunState struct {
+	mu     sync.Mutex
+	record func(call string)
+	chats  map[string]*models.ChatInfo
+	seq    int
+}
+
+// SetDryRun 开启演练模式
+//
+// 开启后查询接口照常调用；修改类接口不发送请求，只将请求方法、路径和请求体交给
+// record 记录。创建群组返回以 "dryrun-" 开头的临时群组ID，查询该ID时返回按
+// 演练中的请求构造的群信息。
+func (c *Client) SetDryRun(record func(call string)) {
+	c.dryRun = &dryRunState{
+		record: record,
+		chats:  make(map[string]*models.ChatInfo),
+	}
+}
+
+// IsDryRunID 是否为演练模式下生成的临时群组ID
+func IsDryRunID(chatID string) bool {
+	return strings.HasPrefix(chatID, dryRunIDPrefix)
+}
+
+// recordCall 记录一次未发送的请求
+func (d *dryRunState) recordCall(method, path string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		body = []byte(fmt.Sprintf("%v", payload))
+	}
+	d.record(fmt.Sprintf("%s %s %s", method, path, body))
+}
+
+// createChat 为演练中创建的群组分配临时ID
+func (d *dryRunState) createChat(req *models.GroupCreateRequest) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seq++
+	chatID := fmt.Sprintf("%schat%d", dryRunIDPrefix, d.seq)
+	info := &models.ChatInfo{
+		ChatID:          chatID,
+		Name:            req.Name,
+		OwnerID:         req.OwnerID,
+		Members:         append([]string(nil), req.MemberIDs...),
+		ConversationTag: 1,
+	}
+	if req.IsExternal || req.GroupType == "external" {
+		info.ConversationTag = 2
+	}
+	d.chats[chatID] = info
+	return chatID
+}
+
+// chat 返回演练中创建的群组信息，d 为 nil 或不是临时群组ID时 ok 为 false
+func (d *dryRunState) chat(chatID string) (*models.ChatInfo, bool) {
+	if d == nil {
+		return nil, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	info, ok := d.chats[chatID]
+	if !ok {
+		return nil, false
+	}
+	copied := *info
+	copied.Members = append([]string(nil), info.Members...)
+	return &copied, true
+}
+
+// updateChat 在演练中创建的群组上应用修改，其他群组不做处理
+func (d *dryRunState) updateChat(chatID string, update func(info *models.ChatInfo)) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if info, ok := d.chats[chatID]; ok {
+		update(info)
+	}
+}
@@ -0,0 +1,74 @@
+package dingtalk
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/models"
+)
+
+func TestDryRunSendsOnlyQueries(t *testing.T) {
+	var paths []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"errcode":0,"chat_info":{"chatid":"chat1","name":"研发群","owner":"u1","useridlist":["u1"]}}`))
+	})
+	var calls []string
+	client.SetDryRun(func(call string) { calls = append(calls, call) })
+	ctx := context.Background()
+
+	resp, err := client.CreateGroup(ctx, &models.GroupCreateRequest{Name: "演练群", OwnerID: "u1", MemberIDs: []string{"u1", "u2"}})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if !IsDryRunID(resp.GroupID) {
+		t.Fatalf("chat ID = %q, want dry-run ID", resp.GroupID)
+	}
+	if err := client.AddGroupMembers(ctx, resp.GroupID, []string{"u3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveGroupMembers(ctx, resp.GroupID, []string{"u2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.UpdateGroup(ctx, &models.GroupUpdateRequest{GroupID: resp.GroupID, Name: "演练群2", OwnerID: "u3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddGroupMembers(ctx, "chat1", []string{"u3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 演练中创建的群组按记录的修改返回，不发送请求
+	info, err := client.GetGroup(ctx, resp.GroupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "演练群2" || info.OwnerID != "u3" || strings.Join(info.Members, ",") != "u1,u3" {
+		t.Errorf("dry-run chat = %+v", info)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("requests sent: %v", paths)
+	}
+
+	// 其他群组照常查询
+	if info, err := client.GetGroup(ctx, "chat1"); err != nil || info.Name != "研发群" {
+		t.Fatalf("GetGroup(chat1) = %+v, %v", info, err)
+	}
+	if strings.Join(paths, ",") != "GET /chat/get" {
+		t.Errorf("requests = %v, want only the query", paths)
+	}
+
+	want := []string{"POST /chat/create ", "POST /chat/addmember ", "POST /chat/removemember ", "POST /chat/update ", "POST /chat/addmember "}
+	if len(calls) != len(want) {
+		t.Fatalf("recorded calls = %q", calls)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(calls[i], prefix) {
+			t.Errorf("call %d = %q, want prefix %q", i, calls[i], prefix)
+		}
+	}
+	if !strings.Contains(calls[0], `"name":"演练群"`) {
+		t.Errorf("create call = %q, want request body", calls[0])
+	}
+}
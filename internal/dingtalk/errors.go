@@ -0,0 +1,217 @@
+package dingtalk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 常用错误码
+const (
+	ErrcodeSystemBusy         = -1    // 系统繁忙
+	ErrcodeInvalidParam       = 40035 // 不合法的参数
+	ErrcodeInvalidUserID      = 33012 // 无效的用户ID
+	ErrcodeChatNotFound       = 34001 // 无效的会话ID（群不存在或已解散）
+	ErrcodeDepartmentNotFound = 60003 // 部门不存在
+	ErrcodeNoPermission       = 60011 // 权限不足
+	ErrcodeUserNotFound       = 60121 // 找不到该用户
+	ErrcodeServerBusy         = 90002 // 服务器繁忙或请求被暂时禁用
+	ErrcodeRateLimit          = 90006 // 服务器超过请求频率限制
+	ErrcodeQPSLimit           = 90018 // 超过接口QPS限制
+)
+
+// APIError 钉钉接口返回的错误
+//
+// 接口返回非零错误码或非预期的HTTP状态码时，客户端方法返回的错误可通过
+// errors.As 取得 *APIError，从而区分用户不存在、权限不足、限流等情况。
+type APIError struct {
+	Op         string // 操作描述，如"添加成员"
+	Endpoint   string // 接口路径，如 /chat/addmember
+	Errcode    int    // 钉钉错误码，HTTP层失败时为0
+	Errmsg     string // 钉钉错误信息
+	HTTPStatus int    // HTTP状态码
+	RequestID  string // 钉钉返回的请求ID，便于向钉钉反馈问题
+	Attempts   int    // 已尝试次数（含重试）
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	if e.Errcode != 0 {
+		fmt.Fprintf(&b, "%s失败: %s (errcode=%d, ", e.Op, e.Errmsg, e.Errcode)
+	} else {
+		fmt.Fprintf(&b, "%s请求失败: HTTP状态码 %d (", e.Op, e.HTTPStatus)
+	}
+	fmt.Fprintf(&b, "endpoint=%s", e.Endpoint)
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, ", request_id=%s", e.RequestID)
+	}
+	if e.Attempts > 1 {
+		fmt.Fprintf(&b, ", 共尝试%d次", e.Attempts)
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// Retryable 是否为可重试的错误（系统繁忙、限流、服务端错误）
+func (e *APIError) Retryable() bool {
+	if e.Errcode != 0 {
+		return isRetryableCode(e.Errcode)
+	}
+	return e.HTTPStatus == http.StatusTooManyRequests || e.HTTPStatus >= 500
+}
+
+// Hint 返回错误码对应的处理建议，未收录的错误码返回空字符串
+func (e *APIError) Hint() string {
+	info, ok := LookupError(e.Errcode)
+	if !ok {
+		if e.Errcode == 0 && e.Retryable() {
+			return "钉钉服务暂时不可用，请稍后重试 / DingTalk is temporarily unavailable, retry later"
+		}
+		return ""
+	}
+	return info.HintZH + " / " + info.HintEN
+}
+
+// ErrorInfo 已知错误码说明
+type ErrorInfo struct {
+	Code      int
+	Message   string // 错误含义
+	HintZH    string // 中文处理建议
+	HintEN    string // 英文处理建议
+	Retryable bool   // 是否可以重试
+}
+
+// knownErrors 常见错误码目录
+var knownErrors = map[int]ErrorInfo{
+	-1: {
+		Message:   "系统繁忙",
+		HintZH:    "钉钉服务繁忙，稍后重试即可",
+		HintEN:    "DingTalk is busy, retry later",
+		Retryable: true,
+	},
+	34001: {
+		Message: "无效的会话ID",
+		HintZH:  "群组可能已被解散，请在钉钉中确认该群是否仍然存在",
+		HintEN:  "the chat may have been disbanded; check that it still exists in DingTalk",
+	},
+	40001: {
+		Message: "AppSecret错误或access_token无效",
+		HintZH:  "检查配置中的 app_key/app_secret 是否正确",
+		HintEN:  "check app_key/app_secret in the config file",
+	},
+	40014: {
+		Message: "不合法的access_token",
+		HintZH:  "access_token 已失效，检查配置中的 access_token 或改用 app_key/app_secret",
+		HintEN:  "the access_token is invalid; fix access_token or configure app_key/app_secret",
+	},
+	40035: {
+		Message: "不合法的参数",
+		HintZH:  "检查请求参数，如群名称、用户ID列表是否为空或格式错误",
+		HintEN:  "check request parameters such as group name and user id list",
+	},
+	41001: {
+		Message: "缺少access_token参数",
+		HintZH:  "配置 access_token 或 app_key/app_secret",
+		HintEN:  "configure access_token or app_key/app_secret",
+	},
+	42001: {
+		Message: "access_token超时",
+		HintZH:  "access_token 已过期，配置 app_key/app_secret 以便自动刷新",
+		HintEN:  "the access_token expired; configure app_key/app_secret for automatic refresh",
+	},
+	33012: {
+		Message: "无效的用户ID",
+		HintZH:  "确认用户ID正确且该用户仍在企业通讯录中",
+		HintEN:  "make sure the userid is correct and the user is still in the organization",
+	},
+	50002: {
+		Message: "员工不在授权范围内",
+		HintZH:  "在钉钉开放平台为应用开通该员工所在部门的通讯录权限",
+		HintEN:  "grant the app contact permission for the user's department in the developer console",
+	},
+	50004: {
+		Message: "部门不在授权范围内",
+		HintZH:  "在钉钉开放平台为应用开通该部门的通讯录权限",
+		HintEN:  "grant the app contact permission for the department in the developer console",
+	},
+	60003: {
+		Message: "部门不存在",
+		HintZH:  "确认部门ID正确，部门可能已被删除",
+		HintEN:  "check the department id; the department may have been removed",
+	},
+	60011: {
+		Message: "权限不足",
+		HintZH:  "在钉钉开放平台为应用开通群会话管理权限，或确认操作对象在管理范围内",
+		HintEN:  "grant the app chat management permission or check the admin scope",
+	},
+	60020: {
+		Message: "访问IP不在白名单之中",
+		HintZH:  "在钉钉开放平台应用设置中将本机出口IP加入白名单",
+		HintEN:  "add this machine's egress IP to the app's IP whitelist",
+	},
+	60121: {
+		Message: "找不到该用户",
+		HintZH:  "确认用户ID正确且该用户未离职",
+		HintEN:  "make sure the userid exists and the user has not left the organization",
+	},
+	90002: {
+		Message:   "服务器繁忙或请求被暂时禁用",
+		HintZH:    "调用过于频繁，降低 rate_limit.qps 后重试",
+		HintEN:    "too many requests; lower rate_limit.qps and retry",
+		Retryable: true,
+	},
+	90006: {
+		Message:   "服务器超过请求频率限制",
+		HintZH:    "调用过于频繁，降低 rate_limit.qps 后重试",
+		HintEN:    "request rate limit exceeded; lower rate_limit.qps and retry",
+		Retryable: true,
+	},
+	90018: {
+		Message:   "超过接口QPS限制",
+		HintZH:    "调用过于频繁，降低 rate_limit.qps 或对应接口的限流配置后重试",
+		HintEN:    "API QPS limit exceeded; lower rate_limit settings and retry",
+		Retryable: true,
+	},
+}
+
+// LookupError 查询错误码说明
+func LookupError(code int) (ErrorInfo, bool) {
+	info, ok := knownErrors[code]
+	if ok {
+		info.Code = code
+	}
+	return info, ok
+}
+
+// ErrorHint 返回错误链中钉钉错误的处理建议，没有时返回空字符串
+func ErrorHint(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Hint()
+	}
+	return ""
+}
+
+// HasErrcode 判断错误链中是否包含指定错误码的钉钉错误
+func HasErrcode(err error, codes ...int) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.Errcode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Errcode 返回错误链中钉钉接口的错误码，不是接口错误或HTTP层失败时为0
+func Errcode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Errcode
+	}
+	return 0
+}
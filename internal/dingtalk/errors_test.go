@@ -0,0 +1,124 @@
+package dingtalk
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  *APIError
+		want string
+	}{
+		{
+			&APIError{Op: "添加成员", Endpoint: "/chat/addmember", Errcode: 60121, Errmsg: "找不到该用户", RequestID: "req1"},
+			"添加成员失败: 找不到该用户 (errcode=60121, endpoint=/chat/addmember, request_id=req1)",
+		},
+		{
+			&APIError{Op: "创建群组", Endpoint: "/chat/create", HTTPStatus: http.StatusBadGateway, Attempts: 3},
+			"创建群组请求失败: HTTP状态码 502 (endpoint=/chat/create, 共尝试3次)",
+		},
+		{
+			&APIError{Op: "获取群组信息", Endpoint: "/chat/get", Errcode: 34001, Errmsg: "无效的会话ID", Attempts: 1},
+			"获取群组信息失败: 无效的会话ID (errcode=34001, endpoint=/chat/get)",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestAPIErrorRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *APIError
+		want bool
+	}{
+		{"系统繁忙", &APIError{Errcode: ErrcodeSystemBusy}, true},
+		{"QPS限流", &APIError{Errcode: ErrcodeQPSLimit}, true},
+		{"频率限制", &APIError{Errcode: ErrcodeRateLimit}, true},
+		{"用户不存在", &APIError{Errcode: ErrcodeUserNotFound}, false},
+		{"未收录的错误码", &APIError{Errcode: 12345}, false},
+		{"HTTP 429", &APIError{HTTPStatus: http.StatusTooManyRequests}, true},
+		{"HTTP 503", &APIError{HTTPStatus: http.StatusServiceUnavailable}, true},
+		{"HTTP 404", &APIError{HTTPStatus: http.StatusNotFound}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Retryable(); got != tt.want {
+			t.Errorf("%s: Retryable() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCatalogue(t *testing.T) {
+	for code, info := range knownErrors {
+		if info.Message == "" || info.HintZH == "" || info.HintEN == "" {
+			t.Errorf("errcode %d: incomplete entry %+v", code, info)
+		}
+	}
+
+	info, ok := LookupError(ErrcodeNoPermission)
+	if !ok || info.Code != ErrcodeNoPermission || info.Message != "权限不足" || info.Retryable {
+		t.Errorf("LookupError(%d) = %+v, %v", ErrcodeNoPermission, info, ok)
+	}
+	if _, ok := LookupError(12345); ok {
+		t.Error("LookupError(12345) found an entry")
+	}
+}
+
+func TestErrorHint(t *testing.T) {
+	wrapped := fmt.Errorf("群组 研发群: %w", &APIError{Op: "添加成员", Errcode: 60011, Errmsg: "权限不足"})
+	hint := ErrorHint(wrapped)
+	if !strings.Contains(hint, "群会话管理权限") || !strings.Contains(hint, "chat management permission") {
+		t.Errorf("hint = %q, want bilingual permission hint", hint)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"未收录的错误码", &APIError{Errcode: 12345}, ""},
+		{"HTTP 5xx", &APIError{HTTPStatus: http.StatusBadGateway}, "钉钉服务暂时不可用"},
+		{"HTTP 4xx", &APIError{HTTPStatus: http.StatusNotFound}, ""},
+		{"非接口错误", errors.New("网络错误"), ""},
+		{"nil", nil, ""},
+	}
+	for _, tt := range tests {
+		got := ErrorHint(tt.err)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: ErrorHint() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHasErrcode(t *testing.T) {
+	err := fmt.Errorf("创建群组 研发群: %w", &APIError{Errcode: ErrcodeUserNotFound})
+
+	if !HasErrcode(err, ErrcodeInvalidUserID, ErrcodeUserNotFound) {
+		t.Error("HasErrcode did not find errcode in wrapped error")
+	}
+	if HasErrcode(err, ErrcodeNoPermission) {
+		t.Error("HasErrcode matched a different errcode")
+	}
+	if HasErrcode(errors.New("网络错误"), ErrcodeUserNotFound) {
+		t.Error("HasErrcode matched a non-API error")
+	}
+}
+
+func TestErrcode(t *testing.T) {
+	if got := Errcode(fmt.Errorf("群组 研发群: %w", &APIError{Errcode: ErrcodeChatNotFound})); got != ErrcodeChatNotFound {
+		t.Errorf("Errcode = %d, want %d", got, ErrcodeChatNotFound)
+	}
+	if got := Errcode(&APIError{HTTPStatus: http.StatusBadGateway}); got != 0 {
+		t.Errorf("HTTP error Errcode = %d, want 0", got)
+	}
+	if got := Errcode(errors.New("网络错误")); got != 0 {
+		t.Errorf("non-API Errcode = %d, want 0", got)
+	}
+}
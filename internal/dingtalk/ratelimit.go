@@ -0,0 +1,121 @@
+package dingtalk
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"ti-dding/internal/config"
+)
+
+// RateLimiter 客户端QPS限流器
+//
+// 由一个作用于全部接口的令牌桶和若干按接口路径配置的令牌桶组成，
+// 每次请求需要同时从全局桶和对应接口的桶中取得令牌。可并发使用。
+type RateLimiter struct {
+	global    *tokenBucket
+	endpoints map[string]*tokenBucket
+}
+
+// NewRateLimiter 根据配置创建限流器，QPS为0的桶不限流
+func NewRateLimiter(cfg config.RateLimitConfig) *RateLimiter {
+	l := &RateLimiter{
+		global:    newTokenBucket(cfg.QPS, cfg.Burst),
+		endpoints: make(map[string]*tokenBucket),
+	}
+	for path, qps := range cfg.Endpoints {
+		if b := newTokenBucket(qps, cfg.Burst); b != nil {
+			l.endpoints[normalizeEndpoint(path)] = b
+		}
+	}
+	return l
+}
+
+// Wait 阻塞直到允许调用指定接口，ctx 取消时返回其错误
+func (l *RateLimiter) Wait(ctx context.Context, path string) error {
+	if l == nil {
+		return ctx.Err()
+	}
+
+	var wait time.Duration
+	if l.global != nil {
+		wait = l.global.reserve()
+	}
+	if b := l.endpoints[normalizeEndpoint(path)]; b != nil {
+		if d := b.reserve(); d > wait {
+			wait = d
+		}
+	}
+
+	return sleepContext(ctx, wait)
+}
+
+// sleepContext 等待指定时间，ctx 取消时提前返回其错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// normalizeEndpoint 统一接口路径写法，"/chat/create" 与 "chat/create" 视为相同
+func normalizeEndpoint(path string) string {
+	return strings.ToLower(strings.Trim(path, "/"))
+}
+
+// tokenBucket 令牌桶
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64 // 桶容量
+	tokens float64 // 当前令牌数，为负表示已预约的等待请求
+	last   time.Time
+}
+
+// newTokenBucket 创建令牌桶，qps<=0 时返回nil表示不限流
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	if qps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve 取出一个令牌，返回需要等待的时间
+//
+// 令牌不足时先行扣减（令牌数变为负数），后续请求依次排在其后，
+// 保证并发调用时整体速率不超过 rate。
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
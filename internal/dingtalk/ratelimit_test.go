@@ -0,0 +1,85 @@
+package dingtalk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ti-dding/internal/config"
+)
+
+func TestTokenBucketBurstThenRate(t *testing.T) {
+	b := newTokenBucket(10, 3)
+	for i := 0; i < 3; i++ {
+		if d := b.reserve(); d != 0 {
+			t.Fatalf("reserve %d within burst waited %s", i+1, d)
+		}
+	}
+
+	// 桶已空，后续请求按 1/rate 依次排队
+	for i := 1; i <= 3; i++ {
+		want := time.Duration(i) * 100 * time.Millisecond
+		if d := b.reserve(); d < want-10*time.Millisecond || d > want {
+			t.Fatalf("reserve %d waited %s, want about %s", i+3, d, want)
+		}
+	}
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	b := newTokenBucket(10, 1)
+	b.reserve()
+	b.last = b.last.Add(-time.Second) // 模拟经过1秒
+
+	if d := b.reserve(); d != 0 {
+		t.Fatalf("reserve after refill waited %s", d)
+	}
+	// 补充的令牌不超过桶容量
+	if d := b.reserve(); d == 0 {
+		t.Fatal("tokens exceeded burst after refill")
+	}
+}
+
+func TestNewTokenBucketUnlimited(t *testing.T) {
+	if b := newTokenBucket(0, 5); b != nil {
+		t.Fatal("qps 0 should not limit")
+	}
+}
+
+func TestRateLimiterEndpointBucket(t *testing.T) {
+	l := NewRateLimiter(config.RateLimitConfig{
+		Burst:     1,
+		Endpoints: map[string]float64{"chat/addmember": 1},
+	})
+	ctx := context.Background()
+
+	if err := l.Wait(ctx, "/chat/addmember"); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+	// 其他接口不受 chat/addmember 的限制
+	if err := l.Wait(ctx, "/chat/get"); err != nil {
+		t.Fatalf("Wait for other endpoint: %v", err)
+	}
+
+	// 同一接口的第二次调用需要等待约1秒，ctx 先超时
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "chat/addmember/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want deadline exceeded", err)
+	}
+}
+
+func TestRateLimiterNil(t *testing.T) {
+	var l *RateLimiter
+	if err := l.Wait(context.Background(), "/chat/get"); err != nil {
+		t.Fatalf("nil limiter: %v", err)
+	}
+}
+
+func TestNormalizeEndpoint(t *testing.T) {
+	for _, path := range []string{"/chat/create", "chat/create", "/Chat/Create/"} {
+		if got := normalizeEndpoint(path); got != "chat/create" {
+			t.Errorf("normalizeEndpoint(%q) = %q", path, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package dingtalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"ti-dding/internal/config"
+)
+
+// 默认重试策略
+const (
+	defaultMaxAttempts     = 3
+	defaultInitialInterval = 500 * time.Millisecond
+	defaultMaxInterval     = 10 * time.Second
+)
+
+// retryPolicy 重试策略
+type retryPolicy struct {
+	maxAttempts     int
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxElapsed      time.Duration
+}
+
+// newRetryPolicy 根据配置生成重试策略，未配置的字段使用默认值
+func newRetryPolicy(cfg config.RetryConfig) retryPolicy {
+	p := retryPolicy{
+		maxAttempts:     cfg.MaxAttempts,
+		initialInterval: cfg.InitialInterval,
+		maxInterval:     cfg.MaxInterval,
+		maxElapsed:      cfg.MaxElapsed,
+	}
+	if p.maxAttempts <= 0 {
+		p.maxAttempts = defaultMaxAttempts
+	}
+	if p.initialInterval <= 0 {
+		p.initialInterval = defaultInitialInterval
+	}
+	if p.maxInterval < p.initialInterval {
+		p.maxInterval = defaultMaxInterval
+		if p.maxInterval < p.initialInterval {
+			p.maxInterval = p.initialInterval
+		}
+	}
+	return p
+}
+
+// backoff 计算第 attempt 次失败后的等待时间（指数退避，附带抖动）
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.initialInterval
+	for i := 1; i < attempt && d < p.maxInterval; i++ {
+		d *= 2
+	}
+	if d > p.maxInterval {
+		d = p.maxInterval
+	}
+	// 在 [d/2, d) 之间随机取值，避免多个进程同时重试
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// isRetryableCode 判断钉钉错误码是否可以重试
+func isRetryableCode(errcode int) bool {
+	info, ok := LookupError(errcode)
+	return ok && info.Retryable
+}
+
+// permanentError 标记不应再重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// rejectedCodes 表示请求在执行前就被钉钉拒绝的错误码（限流），重试不会重复执行操作
+var rejectedCodes = map[int]bool{
+	ErrcodeServerBusy: true,
+	ErrcodeRateLimit:  true,
+	ErrcodeQPSLimit:   true,
+}
+
+// rejected 判断请求是否在执行前就被拒绝：返回限流错误码或 HTTP 429
+func (e *APIError) rejected() bool {
+	if e.Errcode != 0 {
+		return rejectedCodes[e.Errcode]
+	}
+	return e.HTTPStatus == http.StatusTooManyRequests
+}
+
+// isRetryableError 判断请求错误是否可以重试
+//
+// 非幂等请求（如创建群组）只在连接未建立或被限流拒绝（限流错误码、HTTP 429）时
+// 重试；系统繁忙、HTTP 5xx 等情况下请求可能已经执行，重试会重复创建。
+func isRetryableError(err error, idempotent bool) bool {
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var apiErr *APIError
+	if !idempotent {
+		return errors.As(err, &apiErr) && apiErr.rejected()
+	}
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// withRetry 按重试策略执行 fn
+//
+// fn 返回错误时按 isRetryableError 判断是否重试；fn 成功但 result 中的错误码
+// 可重试时同样重试。最终结果为非零错误码时返回 *APIError，其中记录了尝试次数。
+func (c *Client) withRetry(ctx context.Context, action, path string, idempotent bool, result apiResult, fn func() error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			if st := result.status(); st.Errcode != 0 {
+				err = &APIError{
+					Op:         action,
+					Endpoint:   path,
+					Errcode:    st.Errcode,
+					Errmsg:     st.Errmsg,
+					HTTPStatus: st.httpStatus,
+					RequestID:  st.RequestID,
+				}
+			} else {
+				return nil
+			}
+		}
+
+		// 调用方取消或超时，不再重试
+		if ctx.Err() != nil {
+			return err
+		}
+
+		wait := c.retry.backoff(attempt)
+		exhausted := attempt >= c.retry.maxAttempts ||
+			(c.retry.maxElapsed > 0 && time.Since(start)+wait > c.retry.maxElapsed)
+
+		if !isRetryableError(err, idempotent) || exhausted {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				apiErr.Attempts = attempt
+				return err
+			}
+			if attempt > 1 {
+				return fmt.Errorf("%w (共尝试%d次)", err, attempt)
+			}
+			return err
+		}
+
+		if c.config.IsDebug() {
+			fmt.Printf("[debug] %s第%d次尝试失败: %v，%s后重试\n", action, attempt, err, wait)
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package dingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/models"
+)
+
+// newTestClient 创建指向 handler 的客户端，重试间隔缩短到毫秒级
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{}
+	cfg.DingTalk.BaseURL = server.URL
+	cfg.DingTalk.AccessToken = "test-token"
+	cfg.DingTalk.Retry = config.RetryConfig{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     2 * time.Millisecond,
+	}
+	return NewClient(cfg)
+}
+
+func TestBackoffStaysWithinBounds(t *testing.T) {
+	p := newRetryPolicy(config.RetryConfig{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second})
+	for attempt := 1; attempt <= 10; attempt++ {
+		want := 100 * time.Millisecond << (attempt - 1)
+		if want > time.Second {
+			want = time.Second
+		}
+		for i := 0; i < 20; i++ {
+			d := p.backoff(attempt)
+			if d < want/2 || d > want {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestNewRetryPolicyDefaults(t *testing.T) {
+	p := newRetryPolicy(config.RetryConfig{})
+	if p.maxAttempts != defaultMaxAttempts || p.initialInterval != defaultInitialInterval || p.maxInterval != defaultMaxInterval {
+		t.Fatalf("unexpected defaults: %+v", p)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Err: errors.New("connection reset")}
+
+	tests := []struct {
+		name       string
+		err        error
+		idempotent bool
+		want       bool
+	}{
+		{"连接失败", dialErr, true, true},
+		{"非幂等连接失败", dialErr, false, true},
+		{"读取响应失败", readErr, true, true},
+		{"非幂等读取响应失败", readErr, false, false},
+		{"系统繁忙", &APIError{Errcode: ErrcodeSystemBusy}, true, true},
+		{"非幂等系统繁忙", &APIError{Errcode: ErrcodeSystemBusy}, false, false},
+		{"限流", &APIError{Errcode: ErrcodeQPSLimit}, true, true},
+		{"非幂等限流", &APIError{Errcode: ErrcodeQPSLimit}, false, true},
+		{"HTTP 503", &APIError{HTTPStatus: http.StatusServiceUnavailable}, true, true},
+		{"HTTP 429", &APIError{HTTPStatus: http.StatusTooManyRequests}, true, true},
+		{"非幂等 HTTP 503", &APIError{HTTPStatus: http.StatusServiceUnavailable}, false, false},
+		{"非幂等 HTTP 429", &APIError{HTTPStatus: http.StatusTooManyRequests}, false, true},
+		{"非幂等服务器繁忙", &APIError{Errcode: ErrcodeServerBusy}, false, true},
+		{"参数错误", &APIError{Errcode: ErrcodeInvalidParam}, true, false},
+		{"不可重试", &permanentError{err: dialErr}, true, false},
+		{"包装的错误", fmt.Errorf("创建群组请求失败: %w", dialErr), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableError(tt.err, tt.idempotent); got != tt.want {
+				t.Errorf("isRetryableError(%v, %v) = %v, want %v", tt.err, tt.idempotent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryIdempotentServerError(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"errcode": 0})
+	})
+
+	if err := getChat(context.Background(), client); err != nil {
+		t.Fatalf("getChat: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("called %d times, want 3", calls)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errcode": ErrcodeSystemBusy, "errmsg": "系统繁忙"})
+	})
+
+	err := getChat(context.Background(), client)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Errcode != ErrcodeSystemBusy {
+		t.Fatalf("err = %v, want APIError with errcode %d", err, ErrcodeSystemBusy)
+	}
+	if apiErr.Attempts != 3 || calls != 3 {
+		t.Fatalf("attempts = %d, calls = %d, want 3", apiErr.Attempts, calls)
+	}
+}
+
+func TestCreateGroupNotRetriedOnServerError(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadGateway} {
+		var calls int32
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(status)
+		})
+
+		_, err := client.CreateGroup(context.Background(), &models.GroupCreateRequest{Name: "测试群", OwnerID: "u1"})
+		if err == nil {
+			t.Fatalf("HTTP %d: expected error", status)
+		}
+		if calls != 1 {
+			t.Fatalf("HTTP %d: /chat/create called %d times, want 1", status, calls)
+		}
+	}
+}
+
+func TestCreateGroupNotRetriedWhenBusy(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errcode": ErrcodeSystemBusy, "errmsg": "系统繁忙"})
+	})
+
+	if _, err := client.CreateGroup(context.Background(), &models.GroupCreateRequest{Name: "测试群", OwnerID: "u1"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("/chat/create called %d times, want 1", calls)
+	}
+}
+
+func TestRetryNotOnBusinessError(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errcode": 40035, "errmsg": "缺少参数"})
+	})
+
+	if err := getChat(context.Background(), client); !HasErrcode(err, ErrcodeInvalidParam) {
+		t.Fatalf("err = %v, want errcode 40035", err)
+	}
+	if calls != 1 {
+		t.Fatalf("called %d times, want 1", calls)
+	}
+}
+
+func TestCreateGroupRetriedWhenRateLimited(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			json.NewEncoder(w).Encode(map[string]interface{}{"errcode": ErrcodeQPSLimit, "errmsg": "超过QPS限制"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"errcode": 0, "chatid": "chat1"})
+	})
+
+	resp, err := client.CreateGroup(context.Background(), &models.GroupCreateRequest{Name: "测试群", OwnerID: "u1"})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if resp.GroupID != "chat1" || calls != 2 {
+		t.Fatalf("got %+v after %d calls", resp, calls)
+	}
+}
+
+func TestCreateGroupRetriedOnHTTP429(t *testing.T) {
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"errcode": 0, "chatid": "chat1"})
+	})
+
+	resp, err := client.CreateGroup(context.Background(), &models.GroupCreateRequest{Name: "测试群", OwnerID: "u1"})
+	if err != nil {
+		t.Fatalf("CreateGroup: %v", err)
+	}
+	if resp.GroupID != "chat1" || calls != 2 {
+		t.Fatalf("got %+v after %d calls", resp, calls)
+	}
+}
+
+func TestRetryStopsWhenContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		cancel()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	if err := getChat(ctx, client); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Fatalf("called %d times after cancel, want 1", calls)
+	}
+}
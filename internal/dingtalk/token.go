@@ -0,0 +1,192 @@
+package dingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tokenRefreshMargin 令牌到期前提前刷新的时间
+const tokenRefreshMargin = 5 * time.Minute
+
+// tokenCacheFile 令牌缓存文件名（位于数据目录下）
+const tokenCacheFile = "access_token.json"
+
+// cachedToken 持久化的访问令牌
+type cachedToken struct {
+	AppKey      string    `json:"app_key"`
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// isTokenInvalidCode 判断错误码是否表示访问令牌无效或已过期
+func isTokenInvalidCode(errcode int) bool {
+	switch errcode {
+	case 40001, // 获取access_token时AppSecret错误，或者access_token无效
+		40014, // 不合法的access_token
+		41001, // 缺少access_token参数
+		42001: // access_token超时
+		return true
+	}
+	return false
+}
+
+// GetAccessToken 获取访问令牌
+//
+// 令牌在到期前 tokenRefreshMargin 内会被主动刷新；配置中直接提供的
+// access_token 没有到期时间，只有在接口返回令牌失效时才会重新获取。
+func (c *Client) GetAccessToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.accessToken != "" && !c.tokenNeedsRefresh() {
+		return c.accessToken, nil
+	}
+
+	if c.loadCachedToken() {
+		return c.accessToken, nil
+	}
+
+	// 通过AppKey和AppSecret获取访问令牌
+	if c.config.DingTalk.AppKey == "" || c.config.DingTalk.AppSecret == "" {
+		if c.accessToken != "" {
+			// 无法刷新，继续使用已有令牌
+			return c.accessToken, nil
+		}
+		return "", fmt.Errorf("AppKey和AppSecret不能为空")
+	}
+
+	return c.fetchAccessToken(ctx)
+}
+
+// tokenNeedsRefresh 判断当前令牌是否即将过期，调用方需持有 tokenMu
+func (c *Client) tokenNeedsRefresh() bool {
+	if c.tokenExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().Add(tokenRefreshMargin).After(c.tokenExpiresAt)
+}
+
+// fetchAccessToken 调用 /gettoken 获取新令牌，调用方需持有 tokenMu
+func (c *Client) fetchAccessToken(ctx context.Context) (string, error) {
+	params := url.Values{}
+	params.Set("appkey", c.config.DingTalk.AppKey)
+	params.Set("appsecret", c.config.DingTalk.AppSecret)
+
+	var result struct {
+		apiStatus
+		Token     string `json:"access_token"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+
+	err := c.withRetry(ctx, "获取访问令牌", "/gettoken", true, &result, func() error {
+		result.apiStatus = apiStatus{}
+		return c.send(ctx, "GET", "/gettoken", params, nil, &result, "获取访问令牌")
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.accessToken = result.Token
+	c.tokenExpiresAt = time.Time{}
+	if result.ExpiresIn > 0 {
+		c.tokenExpiresAt = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	c.saveCachedToken()
+
+	return c.accessToken, nil
+}
+
+// invalidateToken 作废指定令牌，下次调用时重新获取
+//
+// 只有当前令牌仍是 token 时才会作废，避免并发请求重复刷新。
+func (c *Client) invalidateToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.accessToken != token {
+		return
+	}
+	c.accessToken = ""
+	c.tokenExpiresAt = time.Time{}
+	c.revokedToken = token
+	c.removeCachedToken(token)
+}
+
+// tokenCachePath 令牌缓存文件路径，未启用缓存时返回空字符串
+func (c *Client) tokenCachePath() string {
+	if !c.config.DingTalk.TokenCache || c.config.App.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(c.config.App.DataDir, tokenCacheFile)
+}
+
+// loadCachedToken 从数据目录读取未过期的令牌，调用方需持有 tokenMu
+func (c *Client) loadCachedToken() bool {
+	path := c.tokenCachePath()
+	if path == "" {
+		return false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var cached cachedToken
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return false
+	}
+
+	if cached.AccessToken == "" || cached.AccessToken == c.accessToken || cached.AccessToken == c.revokedToken ||
+		cached.AppKey != c.config.DingTalk.AppKey ||
+		time.Now().Add(tokenRefreshMargin).After(cached.ExpiresAt) {
+		return false
+	}
+
+	c.accessToken = cached.AccessToken
+	c.tokenExpiresAt = cached.ExpiresAt
+	return true
+}
+
+// saveCachedToken 将令牌写入数据目录，演练模式下不写入
+func (c *Client) saveCachedToken() {
+	path := c.tokenCachePath()
+	if path == "" || c.tokenExpiresAt.IsZero() || c.dryRun != nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(cachedToken{
+		AppKey:      c.config.DingTalk.AppKey,
+		AccessToken: c.accessToken,
+		ExpiresAt:   c.tokenExpiresAt,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+
+	// 缓存写入失败只是下次运行需要重新获取令牌，不影响本次调用
+	_ = os.WriteFile(path, data, 0600)
+}
+
+// removeCachedToken 删除缓存文件中已失效的令牌，演练模式下不删除
+func (c *Client) removeCachedToken(token string) {
+	path := c.tokenCachePath()
+	if path == "" || c.dryRun != nil {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var cached cachedToken
+	if err := json.Unmarshal(data, &cached); err != nil || cached.AccessToken == token {
+		_ = os.Remove(path)
+	}
+}
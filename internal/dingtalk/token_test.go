@@ -0,0 +1,280 @@
+package dingtalk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"ti-dding/internal/config"
+)
+
+// tokenServer 模拟 /gettoken 和 /chat/get，每次获取令牌都返回新的令牌
+type tokenServer struct {
+	mu        sync.Mutex
+	expiresIn int             // /gettoken 返回的有效期（秒）
+	issued    int             // 已发放的令牌数
+	revoked   map[string]bool // 已失效的令牌
+	chatCalls int
+	invalid   int // /chat/get 返回的令牌失效错误码
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/gettoken":
+		s.issued++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errcode":      0,
+			"access_token": fmt.Sprintf("token%d", s.issued),
+			"expires_in":   s.expiresIn,
+		})
+	case "/chat/get":
+		s.chatCalls++
+		if token := r.URL.Query().Get("access_token"); s.revoked[token] || token == "" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"errcode": s.invalid, "errmsg": "不合法的access_token"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errcode":   0,
+			"chat_info": map[string]interface{}{"chatid": "chat1", "name": "测试群"},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTokenClient 创建使用 AppKey/AppSecret 获取令牌的客户端，dataDir 不为空时启用令牌缓存
+func newTokenClient(t *testing.T, server *tokenServer, dataDir string) *Client {
+	t.Helper()
+	if server.revoked == nil {
+		server.revoked = map[string]bool{}
+	}
+	if server.invalid == 0 {
+		server.invalid = 40014
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	cfg := &config.Config{}
+	cfg.DingTalk.BaseURL = httpServer.URL
+	cfg.DingTalk.AppKey = "app-key"
+	cfg.DingTalk.AppSecret = "app-secret"
+	cfg.DingTalk.TokenCache = dataDir != ""
+	cfg.App.DataDir = dataDir
+	return NewClient(cfg)
+}
+
+// getChat 调用一次需要令牌的接口
+func getChat(ctx context.Context, client *Client) error {
+	var result apiStatus
+	return client.doRequest(ctx, "GET", "/chat/get", url.Values{"chatid": {"chat1"}}, nil, &result, "获取群组信息")
+}
+
+func TestAccessTokenReusedUntilExpiry(t *testing.T) {
+	server := &tokenServer{expiresIn: 7200}
+	client := newTokenClient(t, server, "")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := getChat(ctx, client); err != nil {
+			t.Fatalf("getChat: %v", err)
+		}
+	}
+	if server.issued != 1 {
+		t.Fatalf("fetched %d tokens, want 1", server.issued)
+	}
+	if remaining := time.Until(client.tokenExpiresAt); remaining < 7190*time.Second || remaining > 7200*time.Second {
+		t.Fatalf("token expires in %s, want about 2h", remaining)
+	}
+}
+
+func TestAccessTokenRefreshedBeforeExpiry(t *testing.T) {
+	server := &tokenServer{expiresIn: 7200}
+	client := newTokenClient(t, server, "")
+	ctx := context.Background()
+
+	token, err := client.GetAccessToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 距到期超过刷新提前量时继续使用
+	client.tokenExpiresAt = time.Now().Add(tokenRefreshMargin + time.Minute)
+	if got, _ := client.GetAccessToken(ctx); got != token {
+		t.Fatalf("token refreshed too early: %s", got)
+	}
+
+	// 进入提前量后主动刷新
+	client.tokenExpiresAt = time.Now().Add(tokenRefreshMargin - time.Minute)
+	got, err := client.GetAccessToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == token || server.issued != 2 {
+		t.Fatalf("token = %s after %d fetches, want a new token", got, server.issued)
+	}
+}
+
+func TestInvalidTokenReplayedOnce(t *testing.T) {
+	for _, errcode := range []int{40001, 40014, 41001, 42001} {
+		server := &tokenServer{expiresIn: 7200, invalid: errcode}
+		client := newTokenClient(t, server, "")
+		ctx := context.Background()
+
+		if _, err := client.GetAccessToken(ctx); err != nil {
+			t.Fatal(err)
+		}
+		// 令牌在钉钉侧提前失效
+		server.revoked["token1"] = true
+
+		if err := getChat(ctx, client); err != nil {
+			t.Fatalf("errcode %d: getChat: %v", errcode, err)
+		}
+		if server.issued != 2 || server.chatCalls != 2 {
+			t.Fatalf("errcode %d: %d tokens, %d chat calls", errcode, server.issued, server.chatCalls)
+		}
+	}
+}
+
+func TestInvalidTokenNotReplayedTwice(t *testing.T) {
+	server := &tokenServer{expiresIn: 7200}
+	client := newTokenClient(t, server, "")
+	for i := 1; i <= 5; i++ {
+		server.revoked[fmt.Sprintf("token%d", i)] = true
+	}
+
+	if err := getChat(context.Background(), client); !HasErrcode(err, 40014) {
+		t.Fatalf("err = %v, want errcode 40014", err)
+	}
+	if server.issued != 2 || server.chatCalls != 2 {
+		t.Fatalf("%d tokens, %d chat calls, want one replay", server.issued, server.chatCalls)
+	}
+}
+
+func TestConfiguredTokenWithoutAppSecretNotReplayed(t *testing.T) {
+	server := &tokenServer{expiresIn: 7200, revoked: map[string]bool{"static": true}}
+	client := newTokenClient(t, server, "")
+	client.config.DingTalk.AppKey, client.config.DingTalk.AppSecret = "", ""
+	client.accessToken = "static"
+
+	if err := getChat(context.Background(), client); !HasErrcode(err, 40014) {
+		t.Fatalf("err = %v, want errcode 40014", err)
+	}
+	if server.issued != 0 || server.chatCalls != 1 {
+		t.Fatalf("%d tokens, %d chat calls", server.issued, server.chatCalls)
+	}
+}
+
+func TestAccessTokenCacheFile(t *testing.T) {
+	dir := t.TempDir()
+	server := &tokenServer{expiresIn: 7200}
+	ctx := context.Background()
+
+	first := newTokenClient(t, server, dir)
+	token, err := first.GetAccessToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, tokenCacheFile))
+	if err != nil {
+		t.Fatalf("token not cached: %v", err)
+	}
+	var cached cachedToken
+	if err := json.Unmarshal(data, &cached); err != nil || cached.AccessToken != token || cached.AppKey != "app-key" {
+		t.Fatalf("cached = %+v, %v", cached, err)
+	}
+
+	// 新进程直接使用缓存的令牌
+	second := newTokenClient(t, server, dir)
+	if got, err := second.GetAccessToken(ctx); err != nil || got != token || server.issued != 1 {
+		t.Fatalf("second client token = %s, %v after %d fetches", got, err, server.issued)
+	}
+
+	// 其他应用的缓存不使用
+	other := newTokenClient(t, server, dir)
+	other.config.DingTalk.AppKey = "other-app"
+	if got, _ := other.GetAccessToken(ctx); got == token {
+		t.Fatal("used a token cached for another app")
+	}
+}
+
+func TestAccessTokenCacheIgnoredNearExpiry(t *testing.T) {
+	dir := t.TempDir()
+	data, _ := json.Marshal(cachedToken{AppKey: "app-key", AccessToken: "old", ExpiresAt: time.Now().Add(time.Minute)})
+	if err := os.WriteFile(filepath.Join(dir, tokenCacheFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &tokenServer{expiresIn: 7200}
+	client := newTokenClient(t, server, dir)
+	if got, err := client.GetAccessToken(context.Background()); err != nil || got != "token1" {
+		t.Fatalf("token = %s, %v, want a fresh token", got, err)
+	}
+}
+
+func TestInvalidTokenRemovedFromCache(t *testing.T) {
+	dir := t.TempDir()
+	server := &tokenServer{expiresIn: 7200}
+	client := newTokenClient(t, server, dir)
+	ctx := context.Background()
+
+	if _, err := client.GetAccessToken(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server.revoked["token1"] = true
+	if err := getChat(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缓存中为重新获取的令牌
+	data, err := os.ReadFile(filepath.Join(dir, tokenCacheFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached cachedToken
+	json.Unmarshal(data, &cached)
+	if cached.AccessToken != "token2" {
+		t.Fatalf("cached token = %s, want token2", cached.AccessToken)
+	}
+}
+
+func TestDryRunLeavesTokenCacheUntouched(t *testing.T) {
+	dir := t.TempDir()
+	server := &tokenServer{expiresIn: 7200}
+	client := newTokenClient(t, server, dir)
+	client.SetDryRun(func(string) {})
+	ctx := context.Background()
+
+	if _, err := client.GetAccessToken(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, tokenCacheFile)); !os.IsNotExist(err) {
+		t.Fatalf("token cache written in dry-run: %v", err)
+	}
+
+	// 已有的缓存在演练中失效时也不删除
+	data, _ := json.Marshal(cachedToken{AppKey: "app-key", AccessToken: "cached", ExpiresAt: time.Now().Add(time.Hour)})
+	if err := os.WriteFile(filepath.Join(dir, tokenCacheFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client = newTokenClient(t, server, dir)
+	client.SetDryRun(func(string) {})
+	server.revoked["cached"] = true
+	if err := getChat(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, tokenCacheFile)); err != nil || string(got) != string(data) {
+		t.Fatalf("token cache changed in dry-run: %s, %v", got, err)
+	}
+}
@@ -0,0 +1,58 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"ti-dding/internal/models"
+)
+
+// Fixture 模拟服务器的初始数据
+type Fixture struct {
+	AppKey      string              `json:"app_key"`    // 为空时接受任意AppKey
+	AppSecret   string              `json:"app_secret"` // 为空时接受任意AppSecret
+	TokenTTL    int                 `json:"token_ttl"`  // 访问令牌有效期（秒），默认7200
+	Departments []models.Department `json:"departments"`
+	Users       []models.User       `json:"users"`
+	Chats       []FixtureChat       `json:"chats"`
+	Faults      []Fault             `json:"faults"`
+}
+
+// FixtureChat 初始群会话
+type FixtureChat struct {
+	ChatID      string              `json:"chatid"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	OwnerID     string              `json:"owner"`
+	Members     []string            `json:"useridlist"`
+	External    bool                `json:"external"`
+	Settings    models.ChatSettings `json:"settings"`
+}
+
+// Fault 故障注入规则
+type Fault struct {
+	Endpoint   string  `json:"endpoint"`    // 接口路径，如 /chat/addmember，为空表示全部接口
+	Errcode    int     `json:"errcode"`     // 返回的错误码，0表示不返回错误码
+	Errmsg     string  `json:"errmsg"`      // 返回的错误信息
+	HTTPStatus int     `json:"http_status"` // 返回的HTTP状态码，0表示200
+	LatencyMS  int     `json:"latency_ms"`  // 响应前的延迟（毫秒）
+	Timeout    bool    `json:"timeout"`     // 不响应，直到客户端断开连接
+	Rate       float64 `json:"rate"`        // 触发概率，0表示总是触发
+	Count      int     `json:"count"`       // 最多触发次数，0表示不限
+}
+
+// LoadFixture 从JSON文件加载初始数据
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取模拟数据文件失败: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("解析模拟数据文件失败: %w", err)
+	}
+
+	return &fixture, nil
+}
@@ -0,0 +1,606 @@
+// Package mockserver 实现一个模拟钉钉开放平台的HTTP服务器，提供本项目用到的
+// oapi 接口，供本地开发、演示和集成测试使用（将 base_url 指向本服务即可）。
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+// defaultTokenTTL 默认访问令牌有效期（秒）
+const defaultTokenTTL = 7200
+
+// Server 模拟钉钉服务器，状态保存在内存中，可并发使用
+type Server struct {
+	fixture *Fixture
+	logger  *log.Logger
+
+	mu          sync.Mutex
+	fake        *dingtalktest.Fake
+	departments []models.Department
+	users       map[string]models.User
+	faults      []*faultState
+	tokens      map[string]time.Time
+	nextToken   int
+	nextRequest int
+}
+
+// faultState 故障注入规则及其剩余触发次数
+type faultState struct {
+	Fault
+	remaining int
+}
+
+// New 根据初始数据创建模拟服务器，fixture 为nil时使用空数据
+func New(fixture *Fixture, logger *log.Logger) *Server {
+	if fixture == nil {
+		fixture = &Fixture{}
+	}
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	s := &Server{fixture: fixture, logger: logger}
+	s.Reset()
+	return s
+}
+
+// Reset 将状态恢复为初始数据
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fake = dingtalktest.NewFake()
+	s.departments = append([]models.Department(nil), s.fixture.Departments...)
+	s.users = make(map[string]models.User, len(s.fixture.Users))
+	s.faults = nil
+	s.tokens = make(map[string]time.Time)
+
+	var userIDs []string
+	for _, user := range s.fixture.Users {
+		s.users[user.UserID] = user
+		userIDs = append(userIDs, user.UserID)
+	}
+	if len(userIDs) > 0 {
+		s.fake.SetDirectory(userIDs...)
+	}
+
+	for _, chat := range s.fixture.Chats {
+		s.fake.AddChat(dingtalktest.Chat{
+			ChatID:      chat.ChatID,
+			Name:        chat.Name,
+			Description: chat.Description,
+			OwnerID:     chat.OwnerID,
+			Members:     chat.Members,
+			External:    chat.External,
+			Settings:    chat.Settings,
+		})
+	}
+
+	for _, fault := range s.fixture.Faults {
+		s.faults = append(s.faults, &faultState{Fault: fault, remaining: fault.Count})
+	}
+}
+
+// Handler 返回模拟服务器的HTTP处理器
+//
+// 除钉钉接口外，还提供以下管理接口：
+//
+//	GET    /_mock/state   查看当前群会话和故障注入规则
+//	POST   /_mock/faults  添加故障注入规则（请求体为 Fault 的JSON）
+//	DELETE /_mock/faults  清除全部故障注入规则
+//	POST   /_mock/reset   恢复为初始数据
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/gettoken", s.api("/gettoken", false, s.handleGetToken))
+	mux.HandleFunc("/chat/create", s.api("/chat/create", true, s.handleChatCreate))
+	mux.HandleFunc("/chat/get", s.api("/chat/get", true, s.handleChatGet))
+	mux.HandleFunc("/chat/update", s.api("/chat/update", true, s.handleChatUpdate))
+	mux.HandleFunc("/chat/addmember", s.api("/chat/addmember", true, s.handleChatAddMember))
+	mux.HandleFunc("/chat/removemember", s.api("/chat/removemember", true, s.handleChatRemoveMember))
+	mux.HandleFunc("/department/list", s.api("/department/list", true, s.handleDepartmentList))
+	mux.HandleFunc("/department/get", s.api("/department/get", true, s.handleDepartmentGet))
+	mux.HandleFunc("/user/simplelist", s.api("/user/simplelist", true, s.handleUserSimpleList))
+	mux.HandleFunc("/user/listbypage", s.api("/user/listbypage", true, s.handleUserListByPage))
+	mux.HandleFunc("/user/get", s.api("/user/get", true, s.handleUserGet))
+	mux.HandleFunc("/user/get_by_mobile", s.api("/user/get_by_mobile", true, s.handleUserGetByMobile))
+
+	mux.HandleFunc("/_mock/state", s.handleState)
+	mux.HandleFunc("/_mock/faults", s.handleFaults)
+	mux.HandleFunc("/_mock/reset", s.handleReset)
+
+	return mux
+}
+
+// apiHandler 处理一个钉钉接口请求，返回响应中除错误码以外的字段
+type apiHandler func(r *http.Request) (interface{}, error)
+
+// api 包装钉钉接口处理器：记录日志、应用故障注入、校验访问令牌并输出统一格式的响应
+func (s *Server) api(path string, needToken bool, h apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := s.newRequestID()
+		s.logger.Printf("%s %s [%s]", r.Method, path, requestID)
+
+		if fault := s.matchFault(path); fault != nil {
+			if fault.LatencyMS > 0 {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(time.Duration(fault.LatencyMS) * time.Millisecond):
+				}
+			}
+			if fault.Timeout {
+				<-r.Context().Done()
+				return
+			}
+			if fault.HTTPStatus != 0 && fault.HTTPStatus != http.StatusOK {
+				s.logger.Printf("  注入故障: HTTP %d", fault.HTTPStatus)
+				w.WriteHeader(fault.HTTPStatus)
+				return
+			}
+			if fault.Errcode != 0 {
+				s.logger.Printf("  注入故障: errcode=%d", fault.Errcode)
+				writeResult(w, requestID, fault.Errcode, fault.Errmsg, nil)
+				return
+			}
+		}
+
+		if needToken {
+			if errcode, errmsg := s.checkToken(r.URL.Query().Get("access_token")); errcode != 0 {
+				writeResult(w, requestID, errcode, errmsg, nil)
+				return
+			}
+		}
+
+		result, err := h(r)
+		if err != nil {
+			var apiErr *dingtalk.APIError
+			if errors.As(err, &apiErr) {
+				writeResult(w, requestID, apiErr.Errcode, apiErr.Errmsg, nil)
+			} else {
+				writeResult(w, requestID, dingtalk.ErrcodeSystemBusy, err.Error(), nil)
+			}
+			return
+		}
+
+		writeResult(w, requestID, 0, "ok", result)
+	}
+}
+
+// writeResult 输出钉钉格式的JSON响应
+func writeResult(w http.ResponseWriter, requestID string, errcode int, errmsg string, result interface{}) {
+	fields := map[string]interface{}{}
+	if result != nil {
+		data, _ := json.Marshal(result)
+		_ = json.Unmarshal(data, &fields)
+	}
+	fields["errcode"] = errcode
+	fields["errmsg"] = errmsg
+	fields["request_id"] = requestID
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(fields)
+}
+
+// matchFault 查找适用于指定接口的故障注入规则，命中时扣减剩余次数
+func (s *Server) matchFault(path string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, fault := range s.faults {
+		if fault.Endpoint != "" && fault.Endpoint != path {
+			continue
+		}
+		if fault.Count > 0 && fault.remaining <= 0 {
+			continue
+		}
+		if fault.Rate > 0 && rand.Float64() >= fault.Rate {
+			continue
+		}
+		if fault.Count > 0 {
+			fault.remaining--
+		}
+		f := fault.Fault
+		return &f
+	}
+	return nil
+}
+
+// checkToken 校验访问令牌，返回错误码和错误信息
+func (s *Server) checkToken(token string) (int, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token == "" {
+		return 41001, "缺少access_token参数"
+	}
+	expiresAt, ok := s.tokens[token]
+	if !ok {
+		return 40014, "不合法的access_token"
+	}
+	if time.Now().After(expiresAt) {
+		return 42001, "access_token超时"
+	}
+	return 0, ""
+}
+
+// newRequestID 生成请求ID
+func (s *Server) newRequestID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextRequest++
+	return fmt.Sprintf("mock-%06d", s.nextRequest)
+}
+
+// handleGetToken 处理 /gettoken
+func (s *Server) handleGetToken(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	if (s.fixture.AppKey != "" && query.Get("appkey") != s.fixture.AppKey) ||
+		(s.fixture.AppSecret != "" && query.Get("appsecret") != s.fixture.AppSecret) {
+		return nil, &dingtalk.APIError{Errcode: 40001, Errmsg: "不合法的appkey或appsecret"}
+	}
+
+	ttl := s.fixture.TokenTTL
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+
+	s.mu.Lock()
+	s.nextToken++
+	token := fmt.Sprintf("mock-token-%d-%d", time.Now().Unix(), s.nextToken)
+	s.tokens[token] = time.Now().Add(time.Duration(ttl) * time.Second)
+	s.mu.Unlock()
+
+	return map[string]interface{}{
+		"access_token": token,
+		"expires_in":   ttl,
+	}, nil
+}
+
+// handleChatCreate 处理 /chat/create
+func (s *Server) handleChatCreate(r *http.Request) (interface{}, error) {
+	var req struct {
+		Name             string   `json:"name"`
+		Description      string   `json:"description"`
+		Owner            string   `json:"owner"`
+		UserIDList       []string `json:"useridlist"`
+		ConversationType int      `json:"conversation_type"`
+	}
+	if err := decodeBody(r, &req); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.currentFake().CreateGroup(r.Context(), &models.GroupCreateRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerID:     req.Owner,
+		MemberIDs:   req.UserIDList,
+		IsExternal:  req.ConversationType == 2,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"chatid": resp.GroupID}, nil
+}
+
+// handleChatGet 处理 /chat/get
+func (s *Server) handleChatGet(r *http.Request) (interface{}, error) {
+	info, err := s.currentFake().GetGroup(r.Context(), r.URL.Query().Get("chatid"))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"chat_info": info}, nil
+}
+
+// handleChatUpdate 处理 /chat/update
+func (s *Server) handleChatUpdate(r *http.Request) (interface{}, error) {
+	var req struct {
+		ChatID string `json:"chatid"`
+		Name   string `json:"name"`
+		Owner  string `json:"owner"`
+		models.ChatSettingsUpdate
+	}
+	if err := decodeBody(r, &req); err != nil {
+		return nil, err
+	}
+
+	return nil, s.currentFake().UpdateGroup(r.Context(), &models.GroupUpdateRequest{
+		GroupID:  req.ChatID,
+		Name:     req.Name,
+		OwnerID:  req.Owner,
+		Settings: req.ChatSettingsUpdate,
+	})
+}
+
+// handleChatAddMember 处理 /chat/addmember
+func (s *Server) handleChatAddMember(r *http.Request) (interface{}, error) {
+	var req struct {
+		ChatID     string   `json:"chatid"`
+		UserIDList []string `json:"useridlist"`
+	}
+	if err := decodeBody(r, &req); err != nil {
+		return nil, err
+	}
+
+	return nil, s.currentFake().AddGroupMembers(r.Context(), req.ChatID, req.UserIDList)
+}
+
+// handleChatRemoveMember 处理 /chat/removemember
+func (s *Server) handleChatRemoveMember(r *http.Request) (interface{}, error) {
+	var req struct {
+		ChatID     string   `json:"chatid"`
+		UserIDList []string `json:"useridlist"`
+	}
+	if err := decodeBody(r, &req); err != nil {
+		return nil, err
+	}
+
+	return nil, s.currentFake().RemoveGroupMembers(r.Context(), req.ChatID, req.UserIDList)
+}
+
+// handleDepartmentList 处理 /department/list
+//
+// 未指定 id 时返回全部部门；指定 id 时返回其子部门，fetch_child=false 时只返回直接子部门。
+func (s *Server) handleDepartmentList(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+
+	s.mu.Lock()
+	departments := append([]models.Department(nil), s.departments...)
+	s.mu.Unlock()
+
+	if idParam := query.Get("id"); idParam != "" {
+		parentID, err := strconv.ParseInt(idParam, 10, 64)
+		if err != nil {
+			return nil, &dingtalk.APIError{Errcode: dingtalk.ErrcodeInvalidParam, Errmsg: "不合法的部门ID"}
+		}
+		departments = subDepartments(departments, parentID, query.Get("fetch_child") != "false")
+	}
+
+	sort.Slice(departments, func(i, j int) bool { return departments[i].ID < departments[j].ID })
+	return map[string]interface{}{"department": departments}, nil
+}
+
+// handleDepartmentGet 处理 /department/get
+func (s *Server) handleDepartmentGet(r *http.Request) (interface{}, error) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return nil, &dingtalk.APIError{Errcode: dingtalk.ErrcodeInvalidParam, Errmsg: "不合法的部门ID"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dept := range s.departments {
+		if dept.ID == id {
+			return dept, nil
+		}
+	}
+	return nil, &dingtalk.APIError{Errcode: dingtalk.ErrcodeDepartmentNotFound, Errmsg: "部门不存在"}
+}
+
+// subDepartments 返回 parentID 的子部门，recursive 为true时包含所有下级部门
+func subDepartments(all []models.Department, parentID int64, recursive bool) []models.Department {
+	var result []models.Department
+	for _, dept := range all {
+		if dept.ParentID != parentID || dept.ID == parentID {
+			continue
+		}
+		result = append(result, dept)
+		if recursive {
+			result = append(result, subDepartments(all, dept.ID, true)...)
+		}
+	}
+	return result
+}
+
+// handleUserSimpleList 处理 /user/simplelist
+func (s *Server) handleUserSimpleList(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	deptID, err := strconv.ParseInt(query.Get("department_id"), 10, 64)
+	if err != nil {
+		return nil, &dingtalk.APIError{Errcode: dingtalk.ErrcodeInvalidParam, Errmsg: "不合法的部门ID"}
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	size, _ := strconv.Atoi(query.Get("size"))
+	if size <= 0 {
+		size = 100
+	}
+
+	type simpleUser struct {
+		UserID string `json:"userid"`
+		Name   string `json:"name"`
+	}
+
+	s.mu.Lock()
+	var users []simpleUser
+	for _, user := range s.users {
+		for _, id := range user.DepartmentIDs {
+			if id == deptID {
+				users = append(users, simpleUser{UserID: user.UserID, Name: user.Name})
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+
+	if offset > len(users) {
+		offset = len(users)
+	}
+	end := offset + size
+	if end > len(users) {
+		end = len(users)
+	}
+
+	return map[string]interface{}{
+		"hasMore":  end < len(users),
+		"userlist": users[offset:end],
+	}, nil
+}
+
+// handleUserListByPage 处理 /user/listbypage
+func (s *Server) handleUserListByPage(r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	deptID, err := strconv.ParseInt(query.Get("department_id"), 10, 64)
+	if err != nil {
+		return nil, &dingtalk.APIError{Errcode: dingtalk.ErrcodeInvalidParam, Errmsg: "不合法的部门ID"}
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	size, _ := strconv.Atoi(query.Get("size"))
+	if size <= 0 {
+		size = 100
+	}
+
+	s.mu.Lock()
+	var users []models.User
+	for _, user := range s.users {
+		for _, id := range user.DepartmentIDs {
+			if id == deptID {
+				users = append(users, user)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+
+	if offset > len(users) {
+		offset = len(users)
+	}
+	end := offset + size
+	if end > len(users) {
+		end = len(users)
+	}
+
+	return map[string]interface{}{
+		"hasMore":  end < len(users),
+		"userlist": users[offset:end],
+	}, nil
+}
+
+// handleUserGetByMobile 处理 /user/get_by_mobile
+func (s *Server) handleUserGetByMobile(r *http.Request) (interface{}, error) {
+	mobile := r.URL.Query().Get("mobile")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if mobile != "" && user.Mobile == mobile {
+			return map[string]string{"userid": user.UserID}, nil
+		}
+	}
+	return nil, &dingtalk.APIError{Errcode: dingtalk.ErrcodeUserNotFound, Errmsg: "找不到该用户"}
+}
+
+// handleUserGet 处理 /user/get
+func (s *Server) handleUserGet(r *http.Request) (interface{}, error) {
+	userID := r.URL.Query().Get("userid")
+
+	s.mu.Lock()
+	user, ok := s.users[userID]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, &dingtalk.APIError{Errcode: dingtalk.ErrcodeUserNotFound, Errmsg: "找不到该用户"}
+	}
+	return user, nil
+}
+
+// handleState 处理 GET /_mock/state
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	var chats []FixtureChat
+	for _, chat := range s.currentFake().Chats() {
+		chats = append(chats, FixtureChat{
+			ChatID:      chat.ChatID,
+			Name:        chat.Name,
+			Description: chat.Description,
+			OwnerID:     chat.OwnerID,
+			Members:     chat.Members,
+			External:    chat.External,
+			Settings:    chat.Settings,
+		})
+	}
+
+	s.mu.Lock()
+	faults := make([]Fault, 0, len(s.faults))
+	for _, fault := range s.faults {
+		faults = append(faults, fault.Fault)
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"chats":  chats,
+		"faults": faults,
+	})
+}
+
+// handleFaults 处理 POST/DELETE /_mock/faults
+func (s *Server) handleFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var fault Fault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil {
+			http.Error(w, fmt.Sprintf("解析故障规则失败: %v", err), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.faults = append(s.faults, &faultState{Fault: fault, remaining: fault.Count})
+		s.mu.Unlock()
+		s.logger.Printf("添加故障规则: %+v", fault)
+	case http.MethodDelete:
+		s.mu.Lock()
+		s.faults = nil
+		s.mu.Unlock()
+		s.logger.Printf("已清除全部故障规则")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReset 处理 POST /_mock/reset
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.Reset()
+	s.logger.Printf("已恢复为初始数据")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currentFake 返回当前的群会话状态
+func (s *Server) currentFake() *dingtalktest.Fake {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fake
+}
+
+// decodeBody 解析JSON请求体
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &dingtalk.APIError{Errcode: dingtalk.ErrcodeInvalidParam, Errmsg: fmt.Sprintf("请求体格式错误: %v", err)}
+	}
+	return nil
+}
@@ -0,0 +1,416 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// testFixture 测试用的初始数据
+var testFixture = &Fixture{
+	AppKey:    "app-key",
+	AppSecret: "app-secret",
+	Departments: []models.Department{
+		{ID: 1, Name: "总公司"},
+		{ID: 2, Name: "研发部", ParentID: 1},
+		{ID: 3, Name: "后端组", ParentID: 2},
+		{ID: 4, Name: "市场部", ParentID: 1},
+	},
+	Users: []models.User{
+		{UserID: "u1", Name: "张三", Mobile: "13800000001", DepartmentIDs: []int64{2}},
+		{UserID: "u2", Name: "李四", Mobile: "13800000002", DepartmentIDs: []int64{2, 3}},
+		{UserID: "u3", Name: "王五", Mobile: "13800000003", DepartmentIDs: []int64{4}},
+	},
+	Chats: []FixtureChat{
+		{ChatID: "chat1", Name: "研发群", OwnerID: "u1", Members: []string{"u1", "u2"}},
+	},
+}
+
+// testServer 启动模拟服务器并获取访问令牌
+func testServer(t *testing.T, fixture *Fixture) (*httptest.Server, string) {
+	t.Helper()
+	server := httptest.NewServer(New(fixture, nil).Handler())
+	t.Cleanup(server.Close)
+
+	result := call(t, server, http.MethodGet, "/gettoken?appkey=app-key&appsecret=app-secret", nil)
+	token, _ := result["access_token"].(string)
+	if token == "" {
+		t.Fatalf("gettoken = %v", result)
+	}
+	return server, token
+}
+
+// call 发送请求并解析JSON响应
+func call(t *testing.T, server *httptest.Server, method, path string, body interface{}) map[string]interface{} {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	result := map[string]interface{}{}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	result["http_status"] = float64(resp.StatusCode)
+	return result
+}
+
+// errcode 返回响应中的错误码
+func errcode(result map[string]interface{}) int {
+	code, _ := result["errcode"].(float64)
+	return int(code)
+}
+
+func TestGetToken(t *testing.T) {
+	server := httptest.NewServer(New(testFixture, nil).Handler())
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		errcode int
+	}{
+		{"正确的凭据", "/gettoken?appkey=app-key&appsecret=app-secret", 0},
+		{"错误的AppKey", "/gettoken?appkey=other&appsecret=app-secret", 40001},
+		{"错误的AppSecret", "/gettoken?appkey=app-key&appsecret=wrong", 40001},
+	}
+	for _, tt := range tests {
+		result := call(t, server, http.MethodGet, tt.path, nil)
+		if errcode(result) != tt.errcode {
+			t.Errorf("%s: errcode = %d, want %d", tt.name, errcode(result), tt.errcode)
+		}
+		if tt.errcode == 0 && (result["access_token"] == "" || result["expires_in"] != float64(defaultTokenTTL)) {
+			t.Errorf("%s: result = %v", tt.name, result)
+		}
+	}
+
+	// 未配置凭据时接受任意AppKey
+	open := httptest.NewServer(New(nil, nil).Handler())
+	defer open.Close()
+	if result := call(t, open, http.MethodGet, "/gettoken?appkey=any&appsecret=any", nil); errcode(result) != 0 {
+		t.Errorf("open fixture: errcode = %d", errcode(result))
+	}
+}
+
+func TestAccessTokenChecked(t *testing.T) {
+	server, token := testServer(t, testFixture)
+
+	tests := []struct {
+		name    string
+		token   string
+		errcode int
+	}{
+		{"有效令牌", token, 0},
+		{"缺少令牌", "", 41001},
+		{"未发放的令牌", "forged", 40014},
+	}
+	for _, tt := range tests {
+		result := call(t, server, http.MethodGet, "/user/get?userid=u1&access_token="+tt.token, nil)
+		if errcode(result) != tt.errcode {
+			t.Errorf("%s: errcode = %d, want %d", tt.name, errcode(result), tt.errcode)
+		}
+	}
+
+	// 过期的令牌
+	expired := *testFixture
+	expired.TokenTTL = 1
+	server, token = testServer(t, &expired)
+	time.Sleep(1100 * time.Millisecond)
+	if result := call(t, server, http.MethodGet, "/user/get?userid=u1&access_token="+token, nil); errcode(result) != 42001 {
+		t.Errorf("expired token: errcode = %d, want 42001", errcode(result))
+	}
+}
+
+func TestChatAPI(t *testing.T) {
+	server, token := testServer(t, testFixture)
+	q := "?access_token=" + token
+
+	created := call(t, server, http.MethodPost, "/chat/create"+q, map[string]interface{}{
+		"name": "新群", "owner": "u1", "useridlist": []string{"u1", "u3"},
+	})
+	chatID, _ := created["chatid"].(string)
+	if errcode(created) != 0 || chatID == "" {
+		t.Fatalf("create = %v", created)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		body    interface{}
+		errcode int
+	}{
+		{"修改群名称", "/chat/update" + q, map[string]interface{}{"chatid": chatID, "name": "改名群"}, 0},
+		{"添加成员", "/chat/addmember" + q, map[string]interface{}{"chatid": chatID, "useridlist": []string{"u2"}}, 0},
+		{"移除成员", "/chat/removemember" + q, map[string]interface{}{"chatid": chatID, "useridlist": []string{"u3"}}, 0},
+		{"群不存在", "/chat/addmember" + q, map[string]interface{}{"chatid": "missing", "useridlist": []string{"u2"}}, dingtalk.ErrcodeChatNotFound},
+		{"请求体格式错误", "/chat/addmember" + q, "not json", dingtalk.ErrcodeInvalidParam},
+	}
+	for _, tt := range tests {
+		result := call(t, server, http.MethodPost, tt.path, tt.body)
+		if errcode(result) != tt.errcode {
+			t.Errorf("%s: errcode = %d (%v), want %d", tt.name, errcode(result), result["errmsg"], tt.errcode)
+		}
+	}
+
+	info := call(t, server, http.MethodGet, "/chat/get"+q+"&chatid="+chatID, nil)["chat_info"].(map[string]interface{})
+	members, _ := json.Marshal(info["useridlist"])
+	if info["name"] != "改名群" || string(members) != `["u1","u2"]` {
+		t.Errorf("chat_info = %v", info)
+	}
+	if result := call(t, server, http.MethodGet, "/chat/get"+q+"&chatid=missing", nil); errcode(result) != dingtalk.ErrcodeChatNotFound {
+		t.Errorf("missing chat: errcode = %d", errcode(result))
+	}
+}
+
+func TestDirectoryAPI(t *testing.T) {
+	server, token := testServer(t, testFixture)
+	q := "?access_token=" + token
+
+	tests := []struct {
+		name    string
+		path    string
+		errcode int
+		key     string
+		want    string
+	}{
+		{"全部部门", "/department/list" + q, 0, "department", "1,2,3,4"},
+		{"递归子部门", "/department/list" + q + "&id=1", 0, "department", "2,3,4"},
+		{"直接子部门", "/department/list" + q + "&id=1&fetch_child=false", 0, "department", "2,4"},
+		{"查询部门", "/department/get" + q + "&id=3", 0, "name", "后端组"},
+		{"部门不存在", "/department/get" + q + "&id=99", dingtalk.ErrcodeDepartmentNotFound, "", ""},
+		{"部门成员", "/user/simplelist" + q + "&department_id=2", 0, "userlist", "u1,u2"},
+		{"部门成员分页", "/user/simplelist" + q + "&department_id=2&offset=1&size=1", 0, "userlist", "u2"},
+		{"部门成员详情", "/user/listbypage" + q + "&department_id=3", 0, "userlist", "u2"},
+		{"部门ID不合法", "/user/listbypage" + q + "&department_id=x", dingtalk.ErrcodeInvalidParam, "", ""},
+		{"查询用户", "/user/get" + q + "&userid=u3", 0, "name", "王五"},
+		{"用户不存在", "/user/get" + q + "&userid=nobody", dingtalk.ErrcodeUserNotFound, "", ""},
+		{"按手机号查询", "/user/get_by_mobile" + q + "&mobile=13800000002", 0, "userid", "u2"},
+		{"手机号不存在", "/user/get_by_mobile" + q + "&mobile=13900000000", dingtalk.ErrcodeUserNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		result := call(t, server, http.MethodGet, tt.path, nil)
+		if errcode(result) != tt.errcode {
+			t.Errorf("%s: errcode = %d, want %d", tt.name, errcode(result), tt.errcode)
+			continue
+		}
+		if tt.key != "" {
+			if got := summarize(result[tt.key]); got != tt.want {
+				t.Errorf("%s: %s = %s, want %s", tt.name, tt.key, got, tt.want)
+			}
+		}
+	}
+}
+
+// summarize 将部门或用户列表概括为ID列表，其他值原样输出
+func summarize(v interface{}) string {
+	list, ok := v.([]interface{})
+	if !ok {
+		s, _ := v.(string)
+		return s
+	}
+	var ids []string
+	for _, item := range list {
+		fields := item.(map[string]interface{})
+		if id, ok := fields["userid"].(string); ok {
+			ids = append(ids, id)
+		} else {
+			data, _ := json.Marshal(fields["id"])
+			ids = append(ids, string(data))
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestFaultInjection(t *testing.T) {
+	tests := []struct {
+		name       string
+		fault      Fault
+		path       string
+		errcode    int
+		httpStatus int
+	}{
+		{"错误码", Fault{Endpoint: "/user/get", Errcode: 90018, Errmsg: "限流"}, "/user/get", 90018, http.StatusOK},
+		{"HTTP状态码", Fault{Endpoint: "/user/get", HTTPStatus: http.StatusBadGateway}, "/user/get", 0, http.StatusBadGateway},
+		{"全部接口", Fault{Errcode: 90002}, "/department/list", 90002, http.StatusOK},
+		{"其他接口不受影响", Fault{Endpoint: "/chat/create", Errcode: 90018}, "/user/get", 0, http.StatusOK},
+	}
+	server, token := testServer(t, testFixture)
+	for _, tt := range tests {
+		// 获取令牌后再注入故障，避免影响 /gettoken
+		call(t, server, http.MethodDelete, "/_mock/faults", nil)
+		call(t, server, http.MethodPost, "/_mock/faults", tt.fault)
+
+		result := call(t, server, http.MethodGet, tt.path+"?userid=u1&access_token="+token, nil)
+		if errcode(result) != tt.errcode || result["http_status"] != float64(tt.httpStatus) {
+			t.Errorf("%s: errcode = %d, HTTP %v, want %d, HTTP %d", tt.name, errcode(result), result["http_status"], tt.errcode, tt.httpStatus)
+		}
+	}
+}
+
+func TestFaultCountAndLatency(t *testing.T) {
+	fixture := *testFixture
+	fixture.Faults = []Fault{{Endpoint: "/user/get", Errcode: 90018, LatencyMS: 50, Count: 2}}
+	server, token := testServer(t, &fixture)
+	path := "/user/get?userid=u1&access_token=" + token
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if result := call(t, server, http.MethodGet, path, nil); errcode(result) != 90018 {
+			t.Fatalf("call %d: errcode = %d, want 90018", i+1, errcode(result))
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("latency not applied: %s", elapsed)
+	}
+	if result := call(t, server, http.MethodGet, path, nil); errcode(result) != 0 {
+		t.Errorf("fault fired more than Count times: errcode = %d", errcode(result))
+	}
+}
+
+func TestFaultTimeout(t *testing.T) {
+	fixture := *testFixture
+	fixture.Faults = []Fault{{Endpoint: "/user/get", Timeout: true}}
+	server, token := testServer(t, &fixture)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/user/get?userid=u1&access_token="+token, nil)
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatal("timeout fault answered the request")
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	server, token := testServer(t, testFixture)
+	q := "?access_token=" + token
+	state := func() map[string]interface{} { return call(t, server, http.MethodGet, "/_mock/state", nil) }
+
+	call(t, server, http.MethodPost, "/chat/create"+q, map[string]interface{}{"name": "新群", "owner": "u1", "useridlist": []string{"u1"}})
+	if chats := state()["chats"].([]interface{}); len(chats) != 2 {
+		t.Fatalf("state chats = %v", chats)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		httpStatus int
+		faults     int
+	}{
+		{"添加故障规则", http.MethodPost, "/_mock/faults", Fault{Endpoint: "/user/get", Errcode: 90018}, http.StatusNoContent, 1},
+		{"再添加一条", http.MethodPost, "/_mock/faults", Fault{Errcode: 90002, Rate: 0.5}, http.StatusNoContent, 2},
+		{"规则格式错误", http.MethodPost, "/_mock/faults", "not json", http.StatusBadRequest, 2},
+		{"不支持的方法", http.MethodGet, "/_mock/faults", nil, http.StatusMethodNotAllowed, 2},
+		{"清除故障规则", http.MethodDelete, "/_mock/faults", nil, http.StatusNoContent, 0},
+		{"重置只接受POST", http.MethodGet, "/_mock/reset", nil, http.StatusMethodNotAllowed, 0},
+	}
+	for _, tt := range tests {
+		result := call(t, server, tt.method, tt.path, tt.body)
+		if result["http_status"] != float64(tt.httpStatus) {
+			t.Errorf("%s: HTTP %v, want %d", tt.name, result["http_status"], tt.httpStatus)
+		}
+		if faults := state()["faults"].([]interface{}); len(faults) != tt.faults {
+			t.Errorf("%s: %d faults, want %d", tt.name, len(faults), tt.faults)
+		}
+	}
+
+	// 重置后恢复为初始数据，并清除通过接口添加的故障规则
+	call(t, server, http.MethodPost, "/_mock/faults", Fault{Errcode: 90018})
+	if result := call(t, server, http.MethodPost, "/_mock/reset", nil); result["http_status"] != float64(http.StatusNoContent) {
+		t.Fatalf("reset: HTTP %v", result["http_status"])
+	}
+	after := state()
+	if chats := after["chats"].([]interface{}); len(chats) != 1 || chats[0].(map[string]interface{})["chatid"] != "chat1" {
+		t.Errorf("chats after reset = %v", chats)
+	}
+	if faults := after["faults"].([]interface{}); len(faults) != 0 {
+		t.Errorf("faults after reset = %v", faults)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "fixture.json")
+	content := `{
+  "app_key": "app-key",
+  "token_ttl": 600,
+  "users": [{"userid": "u1", "name": "张三", "mobile": "13800000001", "department": [2]}],
+  "chats": [{"chatid": "chat1", "name": "研发群", "owner": "u1", "useridlist": ["u1"]}],
+  "faults": [{"endpoint": "/chat/create", "errcode": 90018, "count": 1}]
+}`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := LoadFixture(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixture.AppKey != "app-key" || fixture.TokenTTL != 600 || len(fixture.Users) != 1 ||
+		len(fixture.Chats) != 1 || fixture.Chats[0].OwnerID != "u1" || len(fixture.Faults) != 1 || fixture.Faults[0].Count != 1 {
+		t.Fatalf("fixture = %+v", fixture)
+	}
+
+	server, token := testServer(t, fixture)
+	q := "?access_token=" + token
+	if chats := call(t, server, http.MethodGet, "/_mock/state", nil)["chats"].([]interface{}); len(chats) != 1 {
+		t.Errorf("fixture chats = %v", chats)
+	}
+	if result := call(t, server, http.MethodGet, "/user/get"+q+"&userid=u1", nil); result["name"] != "张三" {
+		t.Errorf("fixture user: %v", result)
+	}
+	if result := call(t, server, http.MethodPost, "/chat/create"+q, map[string]interface{}{"name": "新群", "owner": "u1"}); errcode(result) != 90018 {
+		t.Errorf("fixture fault: errcode = %d", errcode(result))
+	}
+
+	for name, data := range map[string]string{"missing.json": "", "broken.json": "{"} {
+		path := filepath.Join(dir, name)
+		if data != "" {
+			os.WriteFile(path, []byte(data), 0644)
+		}
+		if _, err := LoadFixture(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestExampleFixture(t *testing.T) {
+	fixture, err := LoadFixture(filepath.Join("..", "..", "data", "mock_fixture_example.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixture.Users) == 0 || len(fixture.Departments) == 0 || len(fixture.Chats) == 0 {
+		t.Fatalf("example fixture = %+v", fixture)
+	}
+	if chats := New(fixture, nil).currentFake().Chats(); len(chats) != len(fixture.Chats) {
+		t.Fatalf("loaded %d chats, want %d", len(chats), len(fixture.Chats))
+	}
+}
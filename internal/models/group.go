@@ -17,6 +17,13 @@ type Group struct {
 	Members     []string  `json:"members"`      // 成员用户ID列表
 	GroupType   string    `json:"group_type"`   // 群组类型: internal(内部群), external(外部群)
 	IsExternal  bool      `json:"is_external"`  // 是否为外部群
+
+	LinkedDepartments []int64  `json:"linked_departments,omitempty"` // 关联的部门ID，成员随部门自动同步
+	LinkRecursive     bool     `json:"link_recursive,omitempty"`     // 关联部门是否包含下级部门
+	LinkedMembers     []string `json:"linked_members,omitempty"`     // 上次同步时来自关联部门的成员
+
+	Tags     []string      `json:"tags,omitempty"`     // 标签（由清单管理）
+	Settings *ChatSettings `json:"settings,omitempty"` // 最近一次从钉钉获取或修改后的群设置，nil表示未知
 }
 
 // GroupCreateRequest 创建群组请求
@@ -31,9 +38,10 @@ type GroupCreateRequest struct {
 
 // GroupCreateResponse 创建群组响应
 type GroupCreateResponse struct {
-	GroupID string `json:"group_id"` // 群组ID
-	Success bool   `json:"success"`  // 是否成功
-	Message string `json:"message"`  // 响应消息
+	GroupID string            `json:"group_id"`          // 群组ID
+	Success bool              `json:"success"`           // 是否成功
+	Message string            `json:"message"`           // 响应消息
+	Results []BatchItemResult `json:"results,omitempty"` // 批量创建时每行的结果，按行号排列
 }
 
 // GroupListResponse 群组列表响应
@@ -51,18 +59,356 @@ type GroupMemberRequest struct {
 
 // GroupMemberResponse 群组成员操作响应
 type GroupMemberResponse struct {
+	Success  bool              `json:"success"`           // 是否成功
+	Message  string            `json:"message"`           // 响应消息
+	Affected int               `json:"affected"`          // 影响的群组数量
+	Results  []BatchItemResult `json:"results,omitempty"` // 每个群组的结果，按群组顺序排列
+}
+
+// 批量操作中单项的结果状态
+const (
+	ItemSucceeded  = "succeeded"   // 成功
+	ItemFailed     = "failed"      // 失败
+	ItemSkipped    = "skipped"     // 无需处理，如上次运行已完成
+	ItemUnknown    = "unknown"     // 结果未知，需要人工核实
+	ItemNotStarted = "not_started" // 操作中断，未处理
+)
+
+// BatchItemResult 批量操作中单项（CSV中的一行或一个群组）的处理结果
+type BatchItemResult struct {
+	Row        int    `json:"row,omitempty"`      // 在CSV或Excel文件中的行号（表头为第1行），不是按文件处理时为0
+	Name       string `json:"name"`               // 群名称
+	GroupID    string `json:"group_id,omitempty"` // 群组ID
+	Status     string `json:"status"`             // 结果状态
+	Errcode    int    `json:"errcode,omitempty"`  // 钉钉接口错误码
+	Error      string `json:"error,omitempty"`    // 错误信息
+	DurationMS int64  `json:"duration_ms"`        // 处理耗时（毫秒）
+}
+
+// 导入文件检查问题的级别
+const (
+	IssueError   = "error"   // 错误，按文件创建群组会失败
+	IssueWarning = "warning" // 警告，可以创建但结果可能不符合预期
+)
+
+// ValidationIssue 导入文件检查发现的一个问题
+type ValidationIssue struct {
+	Line    int    `json:"line"`    // 文件中的行号（标题行为第1行）
+	Level   string `json:"level"`   // 问题级别
+	Message string `json:"message"` // 问题描述
+}
+
+// ValidationResponse 导入文件检查结果
+type ValidationResponse struct {
+	Rows     int               `json:"rows"`     // 数据行数
+	Errors   int               `json:"errors"`   // 错误数
+	Warnings int               `json:"warnings"` // 警告数
+	Issues   []ValidationIssue `json:"issues"`   // 按行号排列的问题列表
+}
+
+// ChatInfo 钉钉中群会话的实时信息（chat/get 返回）
+type ChatInfo struct {
+	ChatID          string   `json:"chatid"`          // 群会话ID
+	Name            string   `json:"name"`            // 群名称
+	OwnerID         string   `json:"owner"`           // 群主用户ID
+	Members         []string `json:"useridlist"`      // 成员用户ID列表
+	ConversationTag int      `json:"conversationTag"` // 会话类型: 1内部群, 2外部群
+	ChatSettings
+}
+
+// ChatSettings 群设置
+type ChatSettings struct {
+	ShowHistoryType     int `json:"showHistoryType"`     // 新成员是否可查看历史消息: 0否, 1是
+	ValidationType      int `json:"validationType"`      // 入群是否需要验证: 0否, 1是
+	Searchable          int `json:"searchable"`          // 群是否可被搜索: 0否, 1是
+	MentionAllAuthority int `json:"mentionAllAuthority"` // @所有人权限: 0所有人, 1仅群主
+	ManagementType      int `json:"managementType"`      // 群管理方式: 0所有人可管理, 1仅群主可管理
+	ChatBannedType      int `json:"chatBannedType"`      // 是否全员禁言: 0否, 1是
+}
+
+// ChatSettingsUpdate 群设置修改，nil 表示不修改该项
+type ChatSettingsUpdate struct {
+	ShowHistoryType     *int `json:"showHistoryType,omitempty"`
+	ValidationType      *int `json:"validationType,omitempty"`
+	Searchable          *int `json:"searchable,omitempty"`
+	MentionAllAuthority *int `json:"mentionAllAuthority,omitempty"`
+	ManagementType      *int `json:"managementType,omitempty"`
+	ChatBannedType      *int `json:"chatBannedType,omitempty"`
+}
+
+// IsEmpty 检查是否没有修改任何设置
+func (u ChatSettingsUpdate) IsEmpty() bool {
+	return u.ShowHistoryType == nil && u.ValidationType == nil && u.Searchable == nil &&
+		u.MentionAllAuthority == nil && u.ManagementType == nil && u.ChatBannedType == nil
+}
+
+// Apply 将修改应用到群设置
+func (u ChatSettingsUpdate) Apply(settings *ChatSettings) {
+	set := func(dst *int, v *int) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	set(&settings.ShowHistoryType, u.ShowHistoryType)
+	set(&settings.ValidationType, u.ValidationType)
+	set(&settings.Searchable, u.Searchable)
+	set(&settings.MentionAllAuthority, u.MentionAllAuthority)
+	set(&settings.ManagementType, u.ManagementType)
+	set(&settings.ChatBannedType, u.ChatBannedType)
+}
+
+// GroupUpdateRequest 修改群组请求，空字段表示不修改
+type GroupUpdateRequest struct {
+	GroupID     string             `json:"group_id"`    // 群组ID
+	Name        string             `json:"name"`        // 新群名称
+	Description string             `json:"description"` // 新群描述（钉钉群会话没有描述，只更新本地记录）
+	OwnerID     string             `json:"owner_id"`    // 新群主用户ID
+	Settings    ChatSettingsUpdate `json:"settings"`    // 群设置
+}
+
+// GroupUpdateResponse 批量修改群组响应
+type GroupUpdateResponse struct {
 	Success  bool   `json:"success"`  // 是否成功
 	Message  string `json:"message"`  // 响应消息
-	Affected int    `json:"affected"` // 影响的群组数量
+	Affected int    `json:"affected"` // 修改成功的群组数量
+}
+
+// GroupFieldDiff 本地记录与钉钉实时信息不一致的字段
+type GroupFieldDiff struct {
+	Field  string `json:"field"`  // 字段名: name, owner, group_type, members, status
+	Local  string `json:"local"`  // 本地记录中的值
+	Remote string `json:"remote"` // 钉钉中的值
+}
+
+// GroupDetailResponse 群组详情（钉钉实时信息与本地记录对比）
+type GroupDetailResponse struct {
+	Remote *ChatInfo        `json:"remote"` // 钉钉中的实时信息
+	Local  *Group           `json:"local"`  // 本地记录，不在本地存储中时为nil
+	Diffs  []GroupFieldDiff `json:"diffs"`  // 不一致的字段
+}
+
+// 同步动作
+const (
+	SyncUpdated = "updated" // 本地记录已按钉钉更新
+	SyncDeleted = "deleted" // 群组在钉钉中已解散，本地标记为删除
+)
+
+// GroupSyncChange 一个群组的同步变更
+type GroupSyncChange struct {
+	GroupID string           `json:"group_id"` // 群组ID
+	Name    string           `json:"name"`     // 本地记录中的群名称
+	Action  string           `json:"action"`   // 同步动作: updated, deleted
+	Diffs   []GroupFieldDiff `json:"diffs"`    // 不一致的字段
+}
+
+// GroupSyncResponse 同步结果
+type GroupSyncResponse struct {
+	CheckOnly bool              `json:"check_only"` // 是否只检查不修改
+	Checked   int               `json:"checked"`    // 已核实的群组数量
+	Changes   []GroupSyncChange `json:"changes"`    // 有变更的群组
+	Errors    []string          `json:"errors"`     // 核实或保存失败的群组
+	Skipped   int               `json:"skipped"`    // 因操作中断未处理的群组数量
+}
+
+// GroupType 返回会话类型对应的群组类型: internal, external
+func (c *ChatInfo) GroupType() string {
+	if c.ConversationTag == 2 {
+		return "external"
+	}
+	return "internal"
+}
+
+// 远程核实状态
+const (
+	RemoteAlive   = "alive"   // 群组在钉钉中仍然存在
+	RemoteGone    = "gone"    // 群组在钉钉中已不存在（已解散）
+	RemoteUnknown = "unknown" // 未核实或核实失败
+)
+
+// 同名群组的来源
+const (
+	SourceLocal    = "local"    // 本地存储
+	SourceRegistry = "registry" // 共享登记表
+)
+
+// GroupMatch 同名群组的一处匹配
+type GroupMatch struct {
+	ChatID       string   `json:"chat_id"`       // 群会话ID
+	Sources      []string `json:"sources"`       // 在哪里找到: local, registry
+	RemoteStatus string   `json:"remote_status"` // 远程核实状态: alive, gone, unknown
+	RemoteName   string   `json:"remote_name"`   // 钉钉中的当前群名称
+	Error        string   `json:"error"`         // 核实失败的原因
+}
+
+// GroupCheckResponse 群名重复检查结果
+type GroupCheckResponse struct {
+	Name    string       `json:"name"`    // 检查的群名称
+	Exists  bool         `json:"exists"`  // 是否存在仍有效或无法确认状态的同名群组
+	Matches []GroupMatch `json:"matches"` // 全部匹配
+}
+
+// 共享登记表的登记来源
+const (
+	RegistrySourceCreated = "created" // 通过本工具创建
+	RegistrySourceAdopted = "adopted" // 纳管的已有群组
+)
+
+// RegistryEntry 共享登记表中的群组
+type RegistryEntry struct {
+	ChatID  string    `json:"chat_id"`  // 群会话ID
+	Name    string    `json:"name"`     // 群名称
+	OwnerID string    `json:"owner_id"` // 群主用户ID
+	Source  string    `json:"source"`   // 登记来源: created(本工具创建), adopted(纳管已有群组)
+	AddedBy string    `json:"added_by"` // 登记人
+	AddedAt time.Time `json:"added_at"` // 登记时间
+}
+
+// 群主转移结果状态
+const (
+	TransferDone    = "transferred" // 已转移
+	TransferSkipped = "skipped"     // 无需转移
+	TransferFailed  = "failed"      // 转移失败
+)
+
+// OwnerTransferResult 单个群组的群主转移结果
+type OwnerTransferResult struct {
+	GroupID string `json:"group_id"` // 群组ID
+	Name    string `json:"name"`     // 群名称
+	Status  string `json:"status"`   // 结果: transferred, skipped, failed
+	Message string `json:"message"`  // 说明
+}
+
+// OwnerTransferResponse 批量转移群主结果
+type OwnerTransferResponse struct {
+	From    string                `json:"from"`    // 原群主用户ID
+	To      string                `json:"to"`      // 新群主用户ID
+	Results []OwnerTransferResult `json:"results"` // 每个群组的结果
+	Skipped int                   `json:"skipped"` // 因操作中断未处理的群组数量
+}
+
+// OffboardGroupResult 员工离职时单个群组的处理结果
+type OffboardGroupResult struct {
+	GroupID  string   `json:"group_id"`  // 群组ID
+	Name     string   `json:"name"`      // 群名称
+	WasOwner bool     `json:"was_owner"` // 离职员工是否为群主
+	Actions  []string `json:"actions"`   // 已执行的变更
+	FollowUp string   `json:"follow_up"` // 需要人工处理的原因，为空表示已处理完毕
+}
+
+// OffboardResponse 员工离职处理结果
+type OffboardResponse struct {
+	UserID          string                `json:"user_id"`          // 离职员工用户ID
+	Successor       string                `json:"successor"`        // 继任群主用户ID
+	SuccessorSource string                `json:"successor_source"` // 继任群主的确定方式
+	Checked         int                   `json:"checked"`          // 检查的群组数量
+	Groups          []OffboardGroupResult `json:"groups"`           // 包含该员工的群组
+	Skipped         int                   `json:"skipped"`          // 因操作中断未处理的群组数量
+}
+
+// 入职入群结果状态
+const (
+	OnboardAdded   = "added"   // 已加入
+	OnboardPlanned = "planned" // 演练模式下将要加入
+	OnboardMember  = "member"  // 已是群成员
+	OnboardFailed  = "failed"  // 加入失败
+)
+
+// OnboardGroupResult 新员工入职时单个群组的处理结果
+type OnboardGroupResult struct {
+	GroupID string   `json:"group_id"` // 群组ID
+	Name    string   `json:"name"`     // 群名称
+	Rules   []string `json:"rules"`    // 命中的规则名称
+	Status  string   `json:"status"`   // 结果: added, planned, member, failed
+	Message string   `json:"message"`  // 说明
+}
+
+// OnboardResponse 新员工入职处理结果
+type OnboardResponse struct {
+	User    *User                `json:"user"`    // 通讯录中的员工信息
+	DryRun  bool                 `json:"dry_run"` // 是否为演练模式
+	Groups  []OnboardGroupResult `json:"groups"`  // 命中规则的群组
+	Errors  []string             `json:"errors"`  // 规则配置错误（如群组不存在）
+	Skipped int                  `json:"skipped"` // 因操作中断未处理的群组数量
+}
+
+// ReconcileResult 单个关联部门群组的成员同步结果
+type ReconcileResult struct {
+	GroupID string   `json:"group_id"` // 群组ID
+	Name    string   `json:"name"`     // 群名称
+	Added   []string `json:"added"`    // 新加入的成员（部门新员工）
+	Removed []string `json:"removed"`  // 移出的成员（已离开部门）
+	Error   string   `json:"error"`    // 失败原因
+}
+
+// ReconcileResponse 关联部门群组的成员同步结果
+type ReconcileResponse struct {
+	Results []ReconcileResult `json:"results"` // 每个群组的结果
+	Skipped int               `json:"skipped"` // 因操作中断未处理的群组数量
+}
+
+// 批量操作日志中的行状态
+const (
+	JournalPending = "pending" // 即将调用钉钉接口，进程在此之后退出时结果未知
+	JournalCreated = "created" // 钉钉接口已成功，尚未保存到本地
+	JournalDone    = "done"    // 已完成
+	JournalFailed  = "failed"  // 确定失败，可以重做
+)
+
+// JournalEntry 批量操作日志中的一条记录
+type JournalEntry struct {
+	Row    int       `json:"row"`               // 在CSV或Excel文件中的行号（表头为第1行）
+	Key    string    `json:"key"`               // 行的标识，如群名称
+	State  string    `json:"state"`             // 行状态
+	ChatID string    `json:"chat_id,omitempty"` // 已创建的群组ID
+	Error  string    `json:"error,omitempty"`   // 失败原因
+	Time   time.Time `json:"time"`              // 记录时间
+}
+
+// 演练模式下的操作对象
+const (
+	DryRunTargetDingTalk = "dingtalk" // 钉钉接口
+	DryRunTargetStorage  = "storage"  // 本地存储
+	DryRunTargetRegistry = "registry" // 共享登记表
+)
+
+// DryRunOperation 演练模式下记录的一项未执行的操作
+type DryRunOperation struct {
+	Target string `json:"target"` // 操作对象
+	Detail string `json:"detail"` // 接口请求或存储变更的内容
 }
 
 // CSVGroupData CSV文件中的群组数据
+//
+// 导入时按标题行匹配列，csv 标签为标准列名，alias 标签为可接受的英文列名。
 type CSVGroupData struct {
-	Name        string `csv:"群名称"`
-	Description string `csv:"群描述"`
-	OwnerID     string `csv:"群主用户ID"`
-	MemberIDs   string `csv:"群成员用户ID列表"`
-	GroupType   string `csv:"群组类型"` // 内部群/外部群
+	Name        string `csv:"群名称,required" alias:"name,group_name"`
+	Description string `csv:"群描述" alias:"description,desc"`
+	OwnerID     string `csv:"群主用户ID,required" alias:"owner_id,owner"`
+	MemberIDs   string `csv:"群成员用户ID列表" alias:"members,member_ids"`
+	GroupType   string `csv:"群组类型" alias:"type,group_type"` // 内部群/外部群
+	Line        int    // 在CSV或Excel文件中的行号（表头为第1行）
+}
+
+// CSVGroupMemberData Excel文件成员工作表中的一行，每行一个群成员
+type CSVGroupMemberData struct {
+	GroupName string `csv:"群名称,required" alias:"name,group_name,group"`
+	UserID    string `csv:"成员用户ID,required" alias:"user_id,userid,member"`
+	Line      int    // 在成员工作表中的行号
+}
+
+// CSVGroupUpdateData CSV文件中的群组修改数据，空字段表示不修改
+type CSVGroupUpdateData struct {
+	Group               string `csv:"群组,required" alias:"group,group_id,chat_id"` // 群组ID或当前群名称
+	Name                string `csv:"新群名称" alias:"new_name"`
+	Description         string `csv:"群描述" alias:"description,desc"`
+	OwnerID             string `csv:"新群主用户ID" alias:"new_owner,owner_id"`
+	ShowHistoryType     string `csv:"新成员可查看历史消息" alias:"show_history"` // 是/否
+	ValidationType      string `csv:"入群需要验证" alias:"validation"`       // 是/否
+	Searchable          string `csv:"可被搜索" alias:"searchable"`         // 是/否
+	MentionAllAuthority string `csv:"@所有人权限" alias:"mention_all"`      // 所有人/仅群主
+	ManagementType      string `csv:"群管理权限" alias:"management"`        // 所有人/仅群主
+	ChatBannedType      string `csv:"全员禁言" alias:"chat_banned"`        // 是/否
+	Line                int    // 在CSV或Excel文件中的行号（表头为第1行）
 }
 
 // NewGroup 创建新的群组实例
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Manifest 群组清单，声明群组的期望状态
+type Manifest struct {
+	Groups []ManifestGroup `json:"groups" yaml:"groups"`
+}
+
+// ManifestGroup 清单中的一个群组
+//
+// 有 ID 时按ID匹配本地群组，否则按名称匹配；没有匹配的群组将被创建。
+// Members 为完整的成员列表（群主自动包含），未填写时不管理成员；
+// Tags 未填写时不修改标签。
+type ManifestGroup struct {
+	ID          string            `json:"id,omitempty" yaml:"id,omitempty"`                   // 群组ID
+	Name        string            `json:"name" yaml:"name"`                                   // 群名称
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"` // 群描述
+	Type        string            `json:"type,omitempty" yaml:"type,omitempty"`               // 群组类型: internal, external
+	Owner       string            `json:"owner" yaml:"owner"`                                 // 群主用户ID
+	Members     []string          `json:"members,omitempty" yaml:"members,omitempty"`         // 成员用户ID列表
+	Settings    *ManifestSettings `json:"settings,omitempty" yaml:"settings,omitempty"`       // 群设置
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`               // 标签
+}
+
+// ManifestSettings 清单中的群设置，未填写的项不修改
+type ManifestSettings struct {
+	ShowHistory *bool  `json:"show_history,omitempty" yaml:"show_history,omitempty"` // 新成员可查看历史消息
+	Validation  *bool  `json:"validation,omitempty" yaml:"validation,omitempty"`     // 入群需要验证
+	Searchable  *bool  `json:"searchable,omitempty" yaml:"searchable,omitempty"`     // 群可被搜索
+	MentionAll  string `json:"mention_all,omitempty" yaml:"mention_all,omitempty"`   // @所有人权限: all, owner
+	Management  string `json:"management,omitempty" yaml:"management,omitempty"`     // 群管理权限: all, owner
+	ChatBanned  *bool  `json:"chat_banned,omitempty" yaml:"chat_banned,omitempty"`   // 全员禁言
+}
+
+// 计划动作
+const (
+	PlanCreate = "create" // 创建群组
+	PlanUpdate = "update" // 修改已有群组
+)
+
+// Plan 按清单生成的执行计划，可保存为文件审阅后执行
+type Plan struct {
+	Manifest  string       `json:"manifest"`           // 清单文件路径
+	Live      bool         `json:"live"`               // 是否与钉钉实时信息对比生成
+	CreatedAt time.Time    `json:"created_at"`         // 生成时间
+	Actions   []PlanAction `json:"actions"`            // 需要执行的动作
+	Unchanged int          `json:"unchanged"`          // 无需修改的群组数量
+	Warnings  []string     `json:"warnings,omitempty"` // 无法通过计划处理的差异
+}
+
+// PlanAction 对一个群组执行的动作
+type PlanAction struct {
+	Action        string              `json:"action"`                   // 动作: create, update
+	GroupID       string              `json:"group_id,omitempty"`       // 群组ID（创建时为空）
+	Name          string              `json:"name"`                     // 群名称
+	Baseline      time.Time           `json:"baseline,omitempty"`       // 生成计划时本地记录的更新时间，执行前用于检查记录是否已变化
+	Create        *GroupCreateRequest `json:"create,omitempty"`         // 创建请求
+	Update        *GroupUpdateRequest `json:"update,omitempty"`         // 修改名称、描述、群主和群设置
+	AddMembers    []string            `json:"add_members,omitempty"`    // 要添加的成员
+	RemoveMembers []string            `json:"remove_members,omitempty"` // 要移除的成员
+	SetTags       bool                `json:"set_tags,omitempty"`       // 是否设置标签
+	Tags          []string            `json:"tags,omitempty"`           // 新标签
+	Changes       []PlanChange        `json:"changes"`                  // 变更说明
+}
+
+// PlanChange 一项变更的说明
+type PlanChange struct {
+	Field string `json:"field"` // 字段
+	From  string `json:"from"`  // 当前值
+	To    string `json:"to"`    // 目标值
+}
+
+// PlanResult 执行计划中一个动作的结果
+type PlanResult struct {
+	Action  string `json:"action"`   // 动作: create, update
+	GroupID string `json:"group_id"` // 群组ID
+	Name    string `json:"name"`     // 群名称
+	Error   string `json:"error"`    // 失败原因，为空表示成功
+}
+
+// ApplyResponse 执行计划的结果
+type ApplyResponse struct {
+	Results []PlanResult `json:"results"` // 每个动作的结果
+	Skipped int          `json:"skipped"` // 因操作中断未执行的动作数量
+}
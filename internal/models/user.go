@@ -0,0 +1,33 @@
+package models
+
+import "strings"
+
+// Department 钉钉部门信息
+type Department struct {
+	ID             int64  `json:"id"`                              // 部门ID
+	Name           string `json:"name"`                            // 部门名称
+	ParentID       int64  `json:"parentid"`                        // 父部门ID，根部门为0
+	ManagerUserIDs string `json:"deptManagerUseridList,omitempty"` // 部门主管用户ID，多个以 | 分隔（仅 department/get 返回）
+}
+
+// Managers 返回部门主管用户ID列表
+func (d *Department) Managers() []string {
+	var managers []string
+	for _, id := range strings.Split(d.ManagerUserIDs, "|") {
+		if id = strings.TrimSpace(id); id != "" {
+			managers = append(managers, id)
+		}
+	}
+	return managers
+}
+
+// User 钉钉用户信息
+type User struct {
+	UserID        string  `json:"userid"`     // 用户ID
+	Name          string  `json:"name"`       // 姓名
+	Mobile        string  `json:"mobile"`     // 手机号
+	Email         string  `json:"email"`      // 邮箱
+	Position      string  `json:"position"`   // 职位
+	DepartmentIDs []int64 `json:"department"` // 所属部门ID列表
+	Active        bool    `json:"active"`     // 是否已激活钉钉
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// SetConcurrency 设置批量操作同时处理的群组数量，小于1时按1处理
+//
+// 并发请求仍受钉钉客户端的QPS限流约束，本地存储的写入由存储层串行化。
+func (s *GroupService) SetConcurrency(n int) {
+	s.concurrency = n
+}
+
+// runBatch 用最多 s.concurrency 个协程按下标顺序处理第 0 到 n-1 项，ctx 取消后不再开始新的项
+//
+// 返回已开始处理的项数，未开始的项总是排在末尾。task 按下标写入各自的结果，
+// 汇总时按下标顺序输出即可得到与输入顺序一致的报告。
+func (s *GroupService) runBatch(ctx context.Context, n int, task func(i int)) int {
+	workers := s.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				task(i)
+			}
+		}()
+	}
+
+	started := 0
+	for started < n && ctx.Err() == nil {
+		select {
+		case next <- started:
+			started++
+		case <-ctx.Done():
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	return started
+}
+
+// itemResult 生成单项结果，err 不为 nil 时为失败，并提取其中的钉钉错误码
+func itemResult(groupID string, err error) models.BatchItemResult {
+	if err != nil {
+		return models.BatchItemResult{
+			GroupID: groupID,
+			Status:  models.ItemFailed,
+			Errcode: dingtalk.Errcode(err),
+			Error:   err.Error(),
+		}
+	}
+	return models.BatchItemResult{GroupID: groupID, Status: models.ItemSucceeded}
+}
+
+// notStartedItem 因操作中断未处理的单项结果
+func notStartedItem(name string) models.BatchItemResult {
+	return models.BatchItemResult{Name: name, Status: models.ItemNotStarted, Error: "操作已中断，未处理"}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ti-dding/internal/dingtalk/dingtalktest"
+)
+
+func TestRunBatchConcurrency(t *testing.T) {
+	service, _ := newTestService(t, dingtalktest.NewFake())
+	service.SetConcurrency(3)
+
+	var running, peak int32
+	done := make([]bool, 10)
+	started := service.runBatch(context.Background(), len(done), func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		done[i] = true
+	})
+
+	if started != len(done) {
+		t.Fatalf("started = %d, want %d", started, len(done))
+	}
+	for i, ok := range done {
+		if !ok {
+			t.Errorf("item %d not processed", i)
+		}
+	}
+	if peak < 2 || peak > 3 {
+		t.Errorf("peak concurrency = %d, want 2..3", peak)
+	}
+}
+
+func TestRunBatchSequentialOrder(t *testing.T) {
+	service, _ := newTestService(t, dingtalktest.NewFake())
+	service.SetConcurrency(0)
+
+	var order []int
+	started := service.runBatch(context.Background(), 5, func(i int) {
+		order = append(order, i)
+	})
+	if started != 5 {
+		t.Fatalf("started = %d", started)
+	}
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("order = %v", order)
+		}
+	}
+
+	if started := service.runBatch(context.Background(), 0, func(int) { t.Error("task called") }); started != 0 {
+		t.Errorf("empty batch started = %d", started)
+	}
+}
+
+func TestRunBatchCancel(t *testing.T) {
+	service, _ := newTestService(t, dingtalktest.NewFake())
+	service.SetConcurrency(2)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var mu sync.Mutex
+	processed := map[int]bool{}
+	started := service.runBatch(ctx, 20, func(i int) {
+		if i == 3 {
+			cancel()
+		}
+		mu.Lock()
+		processed[i] = true
+		mu.Unlock()
+	})
+
+	if started < 4 || started >= 20 {
+		t.Fatalf("started = %d, want stop shortly after cancel", started)
+	}
+	// 已开始的项都已处理完，未开始的项排在末尾
+	for i := 0; i < 20; i++ {
+		if processed[i] != (i < started) {
+			t.Fatalf("item %d processed = %v, started = %d", i, processed[i], started)
+		}
+	}
+}
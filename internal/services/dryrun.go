@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+	"ti-dding/internal/storage"
+)
+
+// DryRunLog 演练模式下记录的操作，按发生顺序排列
+type DryRunLog struct {
+	mu         sync.Mutex
+	operations []models.DryRunOperation
+}
+
+// NewDryRunLog 创建演练日志
+func NewDryRunLog() *DryRunLog {
+	return &DryRunLog{}
+}
+
+// Operations 返回已记录的操作
+func (l *DryRunLog) Operations() []models.DryRunOperation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]models.DryRunOperation(nil), l.operations...)
+}
+
+// recorder 返回向日志追加指定对象操作的函数
+func (l *DryRunLog) recorder(target string) func(string) {
+	return func(detail string) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.operations = append(l.operations, models.DryRunOperation{Target: target, Detail: detail})
+	}
+}
+
+// EnableDryRun 开启演练模式
+//
+// 之后的校验、重复检查和查询接口照常执行，钉钉修改类接口、本地存储和共享登记表的
+// 写入只记录到 log 中，不做实际修改。本地存储的修改保留在内存中，同一次演练中
+// 后续的操作能看到之前的结果。钉钉客户端不支持演练模式时返回错误。
+func (s *GroupService) EnableDryRun(log *DryRunLog) error {
+	client, ok := s.dingtalkClient.(dingtalk.DryRunner)
+	if !ok {
+		return fmt.Errorf("钉钉客户端不支持演练模式")
+	}
+	client.SetDryRun(log.recorder(models.DryRunTargetDingTalk))
+
+	s.storage = storage.NewDryRunStorage(s.storage, log.recorder(models.DryRunTargetStorage))
+	if s.registry != nil {
+		s.registry.SetDryRun(log.recorder(models.DryRunTargetRegistry))
+	}
+	s.dryRun = true
+	return nil
+}
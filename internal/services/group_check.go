@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// CheckGroupExists 检查群名是否已被使用
+//
+// 同时查找本地存储和共享登记表中的同名群组，remote 为 true 时再通过
+// chat/get 在钉钉中逐个核实：已解散的群组不算重复，被改名的群组以钉钉中
+// 的当前名称为准，核实失败的群组按存在处理，避免误建重复群组。
+func (s *GroupService) CheckGroupExists(ctx context.Context, name string, remote bool) (*models.GroupCheckResponse, error) {
+	matches, err := s.findMatches(name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.GroupCheckResponse{Name: name, Matches: matches}
+	for i := range resp.Matches {
+		match := &resp.Matches[i]
+		match.RemoteStatus = models.RemoteUnknown
+
+		if remote {
+			info, err := s.dingtalkClient.GetGroup(ctx, match.ChatID)
+			switch {
+			case err == nil:
+				match.RemoteStatus = models.RemoteAlive
+				match.RemoteName = info.Name
+			case dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound):
+				match.RemoteStatus = models.RemoteGone
+			default:
+				match.Error = errorText(err)
+			}
+		}
+
+		if occupies(*match, name) {
+			resp.Exists = true
+		}
+	}
+
+	return resp, nil
+}
+
+// AdoptGroup 将钉钉中已有的群组纳入本地存储和共享登记表管理
+func (s *GroupService) AdoptGroup(ctx context.Context, chatID, groupType string) (*models.Group, error) {
+	if _, err := s.storage.GetGroupByID(chatID); err == nil {
+		return nil, fmt.Errorf("群组已在本地存储中: %s", chatID)
+	}
+
+	info, err := s.dingtalkClient.GetGroup(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	if groupType == "" {
+		groupType = info.GroupType()
+	}
+	isExternal := groupType == "external"
+
+	group := models.NewGroupWithType(info.Name, "", info.OwnerID, groupType, isExternal)
+	group.ID = info.ChatID
+	if len(info.Members) > 0 {
+		group.Members = info.Members
+	}
+	group.MemberCount = len(group.Members)
+
+	if err := s.storage.AddGroup(*group); err != nil {
+		return nil, fmt.Errorf("保存群组失败: %w", err)
+	}
+	if err := s.register(group, models.RegistrySourceAdopted); err != nil {
+		return nil, fmt.Errorf("写入共享登记表失败: %w", err)
+	}
+
+	return group, nil
+}
+
+// findMatches 在本地存储和共享登记表中查找同名群组，按群会话ID合并
+func (s *GroupService) findMatches(name string) ([]models.GroupMatch, error) {
+	var matches []models.GroupMatch
+	index := make(map[string]int)
+	add := func(chatID, source string) {
+		if i, ok := index[chatID]; ok {
+			matches[i].Sources = append(matches[i].Sources, source)
+			return
+		}
+		index[chatID] = len(matches)
+		matches = append(matches, models.GroupMatch{ChatID: chatID, Sources: []string{source}})
+	}
+
+	groups, err := s.storage.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("加载群组列表失败: %w", err)
+	}
+	for _, group := range groups {
+		if group.Status != "deleted" && group.Name == name {
+			add(group.ID, models.SourceLocal)
+		}
+	}
+
+	if s.registry != nil {
+		entries, err := s.registry.FindByName(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			add(entry.ChatID, models.SourceRegistry)
+		}
+	}
+
+	return matches, nil
+}
+
+// releaseStaleMatches 根据核实结果修正本地记录：已解散的群组标记为删除，
+// 已改名的群组更新为钉钉中的名称，使本地存储不再占用该群名
+func (s *GroupService) releaseStaleMatches(check *models.GroupCheckResponse) error {
+	for _, match := range check.Matches {
+		if !hasSource(match, models.SourceLocal) {
+			continue
+		}
+
+		switch match.RemoteStatus {
+		case models.RemoteGone:
+			if err := s.storage.DeleteGroup(match.ChatID); err != nil {
+				return err
+			}
+		case models.RemoteAlive:
+			if match.RemoteName == check.Name {
+				continue
+			}
+			group, err := s.storage.GetGroupByID(match.ChatID)
+			if err != nil {
+				return err
+			}
+			group.Name = match.RemoteName
+			group.UpdatedAt = time.Now()
+			if err := s.storage.UpdateGroup(*group); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// register 将群组写入共享登记表，未配置登记表时不做任何操作
+func (s *GroupService) register(group *models.Group, source string) error {
+	if s.registry == nil {
+		return nil
+	}
+	return s.registry.Put(models.RegistryEntry{
+		ChatID:  group.ID,
+		Name:    group.Name,
+		OwnerID: group.OwnerID,
+		Source:  source,
+		AddedBy: currentUser(),
+		AddedAt: time.Now(),
+	})
+}
+
+// describeMatches 生成同名群组的简要说明，如 "chat123(本地存储, 钉钉中存在)"
+func describeMatches(check *models.GroupCheckResponse) string {
+	var parts []string
+	for _, match := range check.Matches {
+		if !occupies(match, check.Name) {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s(%s, %s)", match.ChatID, SourceText(match.Sources), RemoteStatusText(match)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// occupies 判断匹配的群组是否占用该群名：钉钉中仍以该名称存在，或无法确认状态
+func occupies(match models.GroupMatch, name string) bool {
+	return match.RemoteStatus == models.RemoteUnknown ||
+		(match.RemoteStatus == models.RemoteAlive && match.RemoteName == name)
+}
+
+// SourceText 返回匹配来源的中文描述
+func SourceText(sources []string) string {
+	names := make([]string, 0, len(sources))
+	for _, source := range sources {
+		switch source {
+		case models.SourceLocal:
+			names = append(names, "本地存储")
+		case models.SourceRegistry:
+			names = append(names, "共享登记表")
+		default:
+			names = append(names, source)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// RemoteStatusText 返回远程核实状态的中文描述
+func RemoteStatusText(match models.GroupMatch) string {
+	switch match.RemoteStatus {
+	case models.RemoteAlive:
+		return "钉钉中存在"
+	case models.RemoteGone:
+		return "钉钉中已解散"
+	default:
+		if match.Error != "" {
+			return "核实失败: " + match.Error
+		}
+		return "未核实"
+	}
+}
+
+// hasSource 检查匹配是否来自指定来源
+func hasSource(match models.GroupMatch, source string) bool {
+	for _, s := range match.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// currentUser 返回当前操作系统用户名，用于登记表中的登记人
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "unknown"
+}
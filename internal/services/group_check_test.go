@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+	"ti-dding/internal/storage"
+)
+
+// newRegistryService 创建配置了共享登记表的群组服务
+func newRegistryService(t *testing.T, fake *dingtalktest.Fake) (*GroupService, *storage.FileStorage, *storage.Registry) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "registry.json")
+	store := storage.NewFileStorage(t.TempDir())
+	return NewGroupService(fake, store, &config.GroupConfig{RegistryFile: file}), store, storage.NewRegistry(file)
+}
+
+func TestCheckGroupExists(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store, registry := newRegistryService(t, fake)
+	ctx := context.Background()
+
+	alive := addLocalGroup(t, fake, store, "研发群", "u1", "u1")
+	// 其他人登记的同名群组已在钉钉中解散
+	if err := registry.Put(models.RegistryEntry{ChatID: "gone", Name: "研发群"}); err != nil {
+		t.Fatal(err)
+	}
+
+	check, err := service.CheckGroupExists(ctx, "研发群", false)
+	if err != nil {
+		t.Fatalf("CheckGroupExists: %v", err)
+	}
+	if !check.Exists || len(check.Matches) != 2 || len(fake.Calls()) != 0 {
+		t.Fatalf("local check = %+v", check)
+	}
+
+	check, err = service.CheckGroupExists(ctx, "研发群", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := map[string]string{}
+	for _, match := range check.Matches {
+		status[match.ChatID] = match.RemoteStatus
+	}
+	if !check.Exists || status[alive] != models.RemoteAlive || status["gone"] != models.RemoteGone {
+		t.Fatalf("remote check = %+v", check)
+	}
+	if got := describeMatches(check); !strings.Contains(got, alive) || strings.Contains(got, "gone") {
+		t.Errorf("describeMatches = %q", got)
+	}
+}
+
+func TestCheckGroupExistsRenamedOrUnverified(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store, _ := newRegistryService(t, fake)
+	ctx := context.Background()
+
+	// 本地记录的群名称已在钉钉中被修改
+	chatID := fake.AddChat(dingtalktest.Chat{Name: "新市场群", OwnerID: "u1", Members: []string{"u1"}})
+	group := models.NewGroupWithType("市场群", "", "u1", "internal", false)
+	group.ID = chatID
+	if err := store.AddGroup(*group); err != nil {
+		t.Fatal(err)
+	}
+
+	check, err := service.CheckGroupExists(ctx, "市场群", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Exists || check.Matches[0].RemoteName != "新市场群" {
+		t.Fatalf("renamed group = %+v", check)
+	}
+
+	// 核实失败时按存在处理
+	fake.FailNext(dingtalktest.MethodGetGroup, dingtalktest.NewAPIError(dingtalktest.MethodGetGroup, dingtalk.ErrcodeNoPermission, "权限不足"))
+	check, err = service.CheckGroupExists(ctx, "市场群", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.Exists || check.Matches[0].RemoteStatus != models.RemoteUnknown || check.Matches[0].Error == "" {
+		t.Fatalf("unverified group = %+v", check)
+	}
+}
+
+func TestAdoptGroup(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store, registry := newRegistryService(t, fake)
+	ctx := context.Background()
+	chatID := fake.AddChat(dingtalktest.Chat{Name: "客户群", OwnerID: "u1", Members: []string{"u1", "u2"}, External: true})
+
+	group, err := service.AdoptGroup(ctx, chatID, "")
+	if err != nil {
+		t.Fatalf("AdoptGroup: %v", err)
+	}
+	if group.GroupType != "external" || group.MemberCount != 2 {
+		t.Errorf("adopted group = %+v", group)
+	}
+	if _, err := store.GetGroupByID(chatID); err != nil {
+		t.Errorf("adopted group not stored: %v", err)
+	}
+	entries, _ := registry.FindByName("客户群")
+	if len(entries) != 1 || entries[0].Source != models.RegistrySourceAdopted {
+		t.Errorf("registry entries = %+v", entries)
+	}
+
+	if _, err := service.AdoptGroup(ctx, chatID, ""); err == nil {
+		t.Error("adopting a stored group should fail")
+	}
+	if _, err := service.AdoptGroup(ctx, "missing", ""); err == nil {
+		t.Error("adopting a missing chat should fail")
+	}
+}
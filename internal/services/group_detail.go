@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ti-dding/internal/models"
+)
+
+// GetGroupDetail 获取群组在钉钉中的实时信息，并与本地记录逐项对比
+//
+// 本地记录已标记为删除时同样对比，差异中包含 status 字段。群组不在本地存储中时
+// 只返回实时信息，Local 为 nil。
+func (s *GroupService) GetGroupDetail(ctx context.Context, groupID string) (*models.GroupDetailResponse, error) {
+	info, err := s.dingtalkClient.GetGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.storage.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("加载群组列表失败: %w", err)
+	}
+
+	resp := &models.GroupDetailResponse{Remote: info}
+	for i := range groups {
+		// 同一ID可能先被删除后又重新纳管，优先使用未删除的记录
+		if groups[i].ID == groupID && (resp.Local == nil || resp.Local.Status == "deleted") {
+			resp.Local = &groups[i]
+		}
+	}
+	if resp.Local != nil {
+		resp.Diffs = diffGroup(resp.Local, info)
+	}
+
+	return resp, nil
+}
+
+// diffGroup 对比本地记录与钉钉实时信息，返回不一致的字段
+func diffGroup(local *models.Group, remote *models.ChatInfo) []models.GroupFieldDiff {
+	var diffs []models.GroupFieldDiff
+	add := func(field, localValue, remoteValue string) {
+		if localValue != remoteValue {
+			diffs = append(diffs, models.GroupFieldDiff{Field: field, Local: localValue, Remote: remoteValue})
+		}
+	}
+
+	add("name", local.Name, remote.Name)
+	add("owner", local.OwnerID, remote.OwnerID)
+	add("group_type", local.GroupType, remote.GroupType())
+	add("members", memberText(local.Members), memberText(remote.Members))
+	if local.Settings != nil {
+		add("settings", SettingsText(*local.Settings), SettingsText(remote.ChatSettings))
+	}
+	if local.Status == "deleted" {
+		add("status", local.Status, "active")
+	}
+
+	return diffs
+}
+
+// SettingsText 返回群设置的简要描述
+func SettingsText(settings models.ChatSettings) string {
+	return fmt.Sprintf("查看历史消息=%s 入群验证=%s 可搜索=%s @所有人=%s 群管理=%s 全员禁言=%s",
+		YesNoText(settings.ShowHistoryType), YesNoText(settings.ValidationType), YesNoText(settings.Searchable),
+		AuthorityText(settings.MentionAllAuthority), AuthorityText(settings.ManagementType), YesNoText(settings.ChatBannedType))
+}
+
+// YesNoText 返回开关类群设置（1为开启）的显示文本
+func YesNoText(v int) string {
+	if v == 1 {
+		return "是"
+	}
+	return "否"
+}
+
+// AuthorityText 返回权限类群设置（1为仅群主）的显示文本
+func AuthorityText(v int) string {
+	if v == 1 {
+		return "仅群主"
+	}
+	return "所有人"
+}
+
+// memberText 将成员列表排序后拼接，用于对比和展示
+func memberText(members []string) string {
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// MemberChanges 返回从 before 到 after 新增和移除的成员
+func MemberChanges(before, after []string) (added, removed []string) {
+	inBefore := make(map[string]bool, len(before))
+	for _, id := range before {
+		inBefore[id] = true
+	}
+	inAfter := make(map[string]bool, len(after))
+	for _, id := range after {
+		inAfter[id] = true
+		if !inBefore[id] {
+			added = append(added, id)
+		}
+	}
+	for _, id := range before {
+		if !inAfter[id] {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+func TestGetGroupDetail(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2", "u3")
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u2")
+	ctx := context.Background()
+
+	resp, err := service.GetGroupDetail(ctx, chatID)
+	if err != nil {
+		t.Fatalf("GetGroupDetail: %v", err)
+	}
+	if resp.Remote.Name != "研发群" || resp.Local == nil || len(resp.Diffs) != 0 {
+		t.Fatalf("in sync: %+v", resp)
+	}
+
+	// 在钉钉中被修改：改名、更换群主、增加成员
+	changed := fake.AddChat(dingtalktest.Chat{Name: "测试一群", OwnerID: "u2", Members: []string{"u1", "u2", "u3"}})
+	group := models.NewGroupWithType("测试群", "", "u1", "internal", false)
+	group.ID = changed
+	group.Members = []string{"u1", "u2"}
+	if err := store.AddGroup(*group); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = service.GetGroupDetail(ctx, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.GroupFieldDiff{
+		{Field: "name", Local: "测试群", Remote: "测试一群"},
+		{Field: "owner", Local: "u1", Remote: "u2"},
+		{Field: "members", Local: "u1,u2", Remote: "u1,u2,u3"},
+	}
+	if !reflect.DeepEqual(resp.Diffs, want) {
+		t.Errorf("diffs = %+v, want %+v", resp.Diffs, want)
+	}
+}
+
+func TestGetGroupDetailNotManaged(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, _ := newTestService(t, fake)
+	chatID := fake.AddChat(dingtalktest.Chat{Name: "未纳管群", OwnerID: "u1", Members: []string{"u1"}})
+
+	resp, err := service.GetGroupDetail(context.Background(), chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Local != nil || resp.Diffs != nil || resp.Remote.Name != "未纳管群" {
+		t.Errorf("unmanaged group detail = %+v", resp)
+	}
+
+	if _, err := service.GetGroupDetail(context.Background(), "missing"); !dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound) {
+		t.Errorf("missing chat: err = %v", err)
+	}
+}
+
+func TestGetGroupDetailDeletedRecord(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u1")
+	if err := store.DeleteGroup(chatID); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := service.GetGroupDetail(context.Background(), chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.GroupFieldDiff{{Field: "status", Local: "deleted", Remote: "active"}}
+	if !reflect.DeepEqual(resp.Diffs, want) {
+		t.Errorf("diffs = %+v, want %+v", resp.Diffs, want)
+	}
+}
+
+func TestGetGroupDetailSettings(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u1")
+
+	group, err := store.GetGroupByID(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.Settings = &models.ChatSettings{Searchable: 1}
+	if err := store.UpdateGroup(*group); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := service.GetGroupDetail(context.Background(), chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Diffs) != 1 || resp.Diffs[0].Field != "settings" {
+		t.Fatalf("diffs = %+v, want settings", resp.Diffs)
+	}
+	if want := "查看历史消息=否 入群验证=否 可搜索=是 @所有人=所有人 群管理=所有人 全员禁言=否"; resp.Diffs[0].Local != want {
+		t.Errorf("local settings = %q, want %q", resp.Diffs[0].Local, want)
+	}
+}
+
+func TestMemberChanges(t *testing.T) {
+	tests := []struct {
+		before, after  []string
+		added, removed []string
+	}{
+		{[]string{"u1", "u2"}, []string{"u1", "u2"}, nil, nil},
+		{[]string{"u1"}, []string{"u1", "u2", "u3"}, []string{"u2", "u3"}, nil},
+		{[]string{"u1", "u2", "u3"}, []string{"u2"}, nil, []string{"u1", "u3"}},
+		{[]string{"u1", "u2"}, []string{"u2", "u3"}, []string{"u3"}, []string{"u1"}},
+		{nil, []string{"u1"}, []string{"u1"}, nil},
+	}
+	for _, tt := range tests {
+		added, removed := MemberChanges(tt.before, tt.after)
+		if !reflect.DeepEqual(added, tt.added) || !reflect.DeepEqual(removed, tt.removed) {
+			t.Errorf("MemberChanges(%v, %v) = %v, %v, want %v, %v", tt.before, tt.after, added, removed, tt.added, tt.removed)
+		}
+	}
+}
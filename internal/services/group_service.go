@@ -1,8 +1,12 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"ti-dding/internal/config"
 	"ti-dding/internal/dingtalk"
@@ -12,123 +16,119 @@ import (
 
 // GroupService 群组服务
 type GroupService struct {
-	dingtalkClient *dingtalk.Client
+	dingtalkClient dingtalk.GroupAPI
 	storage        storage.Storage
+	directory      dingtalk.DirectoryAPI
+	registry       *storage.Registry
 	config         *config.GroupConfig
+	dryRun         bool // 演练模式，见 EnableDryRun
+	concurrency    int  // 批量操作的并发数，见 SetConcurrency
+
+	directoryCache *storage.DirectoryCache // 本地通讯录缓存，见 SetDirectoryCache
+	users          userDirectory           // 解析 email:、name: 成员标识时使用的通讯录
 }
 
 // NewGroupService 创建新的群组服务
-func NewGroupService(client *dingtalk.Client, storage storage.Storage, config *config.GroupConfig) *GroupService {
-	return &GroupService{
+//
+// client 通常为 *dingtalk.Client，测试时可传入 dingtalktest.Fake。client 同时实现
+// dingtalk.DirectoryAPI 时，依赖通讯录的功能（如离职处理）才可用。
+func NewGroupService(client dingtalk.GroupAPI, store storage.Storage, config *config.GroupConfig) *GroupService {
+	s := &GroupService{
 		dingtalkClient: client,
-		storage:        storage,
+		storage:        store,
 		config:         config,
 	}
+	if directory, ok := client.(dingtalk.DirectoryAPI); ok {
+		s.directory = directory
+	}
+	if config != nil && config.RegistryFile != "" {
+		s.registry = storage.NewRegistry(config.RegistryFile)
+	}
+	return s
 }
 
 // CreateGroupsFromCSV 从CSV文件批量创建群组
-func (s *GroupService) CreateGroupsFromCSV(csvFile string) (*models.GroupCreateResponse, error) {
+//
+// journal 不为 nil 时在每次调用创建接口前后写入操作日志，日志已存在时必须使用
+// resume 继续上次的运行：已完成的行直接跳过；钉钉中已创建但未保存到本地的行补写
+// 本地记录；上次退出时结果未知的行，本地已有同名群组时视为已完成，否则在
+// retryUnknown 为 true 时重新创建，为 false 时列出等待人工核实。全部行完成后删除日志。
+//
+// 各行按 SetConcurrency 设置的并发数同时处理，结果按行号顺序汇总。
+// ctx 取消后不再发起新的创建请求，已创建的群组照常写入本地存储，
+// 返回的消息中包含已处理部分的统计和未处理的群组数量。
+func (s *GroupService) CreateGroupsFromCSV(ctx context.Context, csvFile string, journal *storage.Journal, resume, retryUnknown bool) (*models.GroupCreateResponse, error) {
 	// 从CSV文件加载群组数据
-	csvGroups, err := s.storage.(*storage.FileStorage).LoadGroupsFromCSV(csvFile)
+	csvGroups, warnings, err := s.storage.LoadGroupsFromCSV(csvFile)
 	if err != nil {
-		return nil, fmt.Errorf("加载CSV文件失败: %w", err)
+		return nil, fmt.Errorf("加载群组文件失败: %w", err)
 	}
 
 	if len(csvGroups) == 0 {
 		return &models.GroupCreateResponse{
 			Success: false,
-			Message: "CSV文件中没有有效的群组数据",
+			Message: withWarnings(warnings, "文件中没有有效的群组数据"),
 		}, nil
 	}
-
-	var successCount, failCount int
-	var failedGroups []string
-
-	// 逐个创建群组
 	for _, csvGroup := range csvGroups {
-		// 检查群名是否已存在
-		if s.storage.GroupExists(csvGroup.Name) {
-			failedGroups = append(failedGroups, fmt.Sprintf("%s (群名已存在)", csvGroup.Name))
-			failCount++
-			continue
-		}
-
-		// 解析成员ID列表
-		memberIDs := []string{}
-		if csvGroup.MemberIDs != "" {
-			memberIDs = strings.Split(csvGroup.MemberIDs, ",")
-			// 清理空白字符
-			for i, id := range memberIDs {
-				memberIDs[i] = strings.TrimSpace(id)
-			}
+		if missing := missingFields(csvGroup); missing != "" {
+			return nil, fmt.Errorf("加载群组文件失败: 第%d行%s", csvGroup.Line, missing)
 		}
+	}
 
-		// 确保群主在成员列表中
-		ownerInMembers := false
-		for _, memberID := range memberIDs {
-			if memberID == csvGroup.OwnerID {
-				ownerInMembers = true
-				break
-			}
+	entries := map[string]models.JournalEntry{}
+	if journal != nil {
+		if journal.Exists() && !resume {
+			return nil, fmt.Errorf("存在上次未完成的操作日志 %s，请使用 --resume 继续；如需重新开始请先删除该文件", journal.Path())
 		}
-		if !ownerInMembers {
-			memberIDs = append(memberIDs, csvGroup.OwnerID)
-		}
-
-		// 确定群组类型
-		groupType := "internal"
-		isExternal := false
-		if csvGroup.GroupType != "" {
-			switch strings.ToLower(strings.TrimSpace(csvGroup.GroupType)) {
-			case "external", "外部群", "外部":
-				groupType = "external"
-				isExternal = true
-			case "internal", "内部群", "内部":
-				groupType = "internal"
-				isExternal = false
-			default:
-				groupType = "internal"
-				isExternal = false
-			}
+		if entries, err = journal.Load(); err != nil {
+			return nil, err
 		}
+	}
 
-		// 创建群组请求
-		req := &models.GroupCreateRequest{
-			Name:        csvGroup.Name,
-			Description: csvGroup.Description,
-			OwnerID:     csvGroup.OwnerID,
-			MemberIDs:   memberIDs,
-			GroupType:   groupType,
-			IsExternal:  isExternal,
-		}
+	var successCount, failCount, skippedCount, resumedCount int
+	var failedGroups, unknownGroups []string
 
-		// 调用钉钉API创建群组
-		resp, err := s.dingtalkClient.CreateGroup(req)
-		if err != nil {
-			failedGroups = append(failedGroups, fmt.Sprintf("%s (API调用失败: %s)", csvGroup.Name, err.Error()))
-			failCount++
-			continue
+	// 同名的行只处理第一行，避免并发创建时重复检查同时通过
+	firstRows := make(map[string]int)
+	for _, csvGroup := range csvGroups {
+		if _, ok := firstRows[csvGroup.Name]; !ok {
+			firstRows[csvGroup.Name] = csvGroup.Line
 		}
+	}
 
-		if !resp.Success {
-			failedGroups = append(failedGroups, fmt.Sprintf("%s (%s)", csvGroup.Name, resp.Message))
-			failCount++
-			continue
+	// 并发创建群组，结果按行号保存
+	results := make([]models.BatchItemResult, len(csvGroups))
+	started := s.runBatch(ctx, len(csvGroups), func(i int) {
+		begin := time.Now()
+		csvGroup := csvGroups[i]
+		if first := firstRows[csvGroup.Name]; first != csvGroup.Line {
+			results[i] = itemResult("", fmt.Errorf("与第%d行群名重复", first))
+		} else {
+			results[i] = s.createCSVRow(ctx, csvGroup.Line, csvGroup, entries, journal, retryUnknown)
 		}
+		results[i].Row, results[i].Name = csvGroup.Line, csvGroup.Name
+		results[i].DurationMS = time.Since(begin).Milliseconds()
+	})
+	for i := started; i < len(csvGroups); i++ {
+		results[i] = notStartedItem(csvGroups[i].Name)
+		results[i].Row = csvGroups[i].Line
+	}
 
-		// 创建成功，保存到本地存储
-		group := models.NewGroup(csvGroup.Name, csvGroup.Description, csvGroup.OwnerID)
-		group.ID = resp.GroupID
-		group.Members = memberIDs
-		group.MemberCount = len(memberIDs)
-
-		if err := s.storage.AddGroup(*group); err != nil {
-			failedGroups = append(failedGroups, fmt.Sprintf("%s (保存失败: %s)", csvGroup.Name, err.Error()))
+	for _, result := range results {
+		switch result.Status {
+		case models.ItemSucceeded:
+			successCount++
+		case models.ItemSkipped:
+			resumedCount++
+		case models.ItemUnknown:
+			unknownGroups = append(unknownGroups, fmt.Sprintf("%s (第%d行)", result.Name, result.Row))
+		case models.ItemNotStarted:
+			skippedCount++
+		default:
+			failedGroups = append(failedGroups, fmt.Sprintf("%s (%s)", result.Name, result.Error))
 			failCount++
-			continue
 		}
-
-		successCount++
 	}
 
 	// 构建响应消息
@@ -141,17 +141,235 @@ func (s *GroupService) CreateGroupsFromCSV(csvFile string) (*models.GroupCreateR
 	} else {
 		message = "没有成功创建任何群组"
 	}
+	if resumedCount > 0 {
+		message += fmt.Sprintf("，跳过上次已完成的 %d 个群组", resumedCount)
+	}
 
 	if len(failedGroups) > 0 {
 		message += "\n失败的群组：" + strings.Join(failedGroups, "; ")
 	}
+	if len(unknownGroups) > 0 {
+		message += "\n上次运行中断时创建结果未知的群组：" + strings.Join(unknownGroups, "; ") +
+			"\n请在钉钉中核实：已创建的使用 adopt --group-id 纳管，未创建的使用 --resume --retry-unknown 重新创建"
+	}
+	if skippedCount > 0 {
+		message += fmt.Sprintf("\n操作已中断：剩余 %d 个群组未处理", skippedCount)
+	}
+
+	if journal != nil && !s.dryRun {
+		if failCount == 0 && skippedCount == 0 && len(unknownGroups) == 0 {
+			if err := journal.Remove(); err != nil {
+				message += "\n" + err.Error()
+			}
+		} else {
+			message += fmt.Sprintf("\n进度已记录到 %s，可使用 --resume 继续", journal.Path())
+		}
+	}
+	message = withWarnings(warnings, message)
 
 	return &models.GroupCreateResponse{
 		Success: successCount > 0,
 		Message: message,
+		Results: results,
 	}, nil
 }
 
+// createCSVRow 创建 CSV 中的一行群组，entries 为上次运行的日志
+//
+// 返回的结果中只填写状态、群组ID和错误，行号、群名称和耗时由调用方填写。
+func (s *GroupService) createCSVRow(ctx context.Context, rowNum int, csvGroup models.CSVGroupData, entries map[string]models.JournalEntry, journal *storage.Journal, retryUnknown bool) models.BatchItemResult {
+	// 解析成员ID列表
+	memberIDs := []string{}
+	if csvGroup.MemberIDs != "" {
+		memberIDs = strings.Split(csvGroup.MemberIDs, ",")
+		// 清理空白字符
+		for i, id := range memberIDs {
+			memberIDs[i] = strings.TrimSpace(id)
+		}
+	}
+
+	// 确定群组类型
+	groupType := ParseGroupType(csvGroup.GroupType)
+	isExternal := groupType == "external"
+
+	// 创建群组请求
+	req := &models.GroupCreateRequest{
+		Name:        csvGroup.Name,
+		Description: csvGroup.Description,
+		OwnerID:     csvGroup.OwnerID,
+		MemberIDs:   memberIDs,
+		GroupType:   groupType,
+		IsExternal:  isExternal,
+	}
+	row := models.JournalEntry{Row: rowNum, Key: csvGroup.Name}
+
+	entry, resumed := entries[row.Key]
+	if resumed && entry.State == models.JournalDone {
+		return models.BatchItemResult{Status: models.ItemSkipped, GroupID: entry.ChatID}
+	}
+
+	// 解析 mobile:、email:、name: 形式的群主和成员
+	if err := s.resolveRequestUsers(ctx, req); err != nil {
+		return itemResult("", err)
+	}
+
+	// 按上次运行的日志处理
+	if resumed {
+		switch entry.State {
+		case models.JournalCreated:
+			return itemResult(entry.ChatID, s.recoverCreated(ctx, req, entry.ChatID, journal, row))
+		case models.JournalPending:
+			if group, err := s.storage.GetGroupByName(row.Key); err == nil {
+				// 上次已保存到本地，只是没来得及写入完成记录
+				row.State, row.ChatID = models.JournalDone, group.ID
+				s.appendJournal(journal, row)
+				return models.BatchItemResult{Status: models.ItemSkipped, GroupID: group.ID}
+			}
+			if !retryUnknown {
+				return models.BatchItemResult{Status: models.ItemUnknown, Error: "上次运行中断时创建结果未知，请在钉钉中核实"}
+			}
+		}
+	}
+
+	group, err := s.createGroupWithJournal(ctx, req, journal, row)
+	if err != nil {
+		return itemResult("", err)
+	}
+	return itemResult(group.ID, nil)
+}
+
+// ParseGroupType 解析群组类型（内部群/外部群/internal/external），无法识别时为内部群
+func ParseGroupType(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "external", "外部群", "外部":
+		return "external"
+	default:
+		return "internal"
+	}
+}
+
+// createGroup 检查重名后在钉钉中创建群组，并写入本地存储和共享登记表
+//
+// 群主不在成员列表中时自动加入。返回的错误信息可直接展示给用户。
+func (s *GroupService) createGroup(ctx context.Context, req *models.GroupCreateRequest) (*models.Group, error) {
+	return s.createGroupWithJournal(ctx, req, nil, models.JournalEntry{})
+}
+
+// createGroupWithJournal 同 createGroup，journal 不为 nil 时在调用创建接口前后写入 row 的状态
+func (s *GroupService) createGroupWithJournal(ctx context.Context, req *models.GroupCreateRequest, journal *storage.Journal, row models.JournalEntry) (*models.Group, error) {
+	// 检查群名是否已存在（本地存储、共享登记表，并在钉钉中核实）
+	check, err := s.CheckGroupExists(ctx, req.Name, true)
+	if err != nil {
+		return nil, fmt.Errorf("重复检查失败: %s", err.Error())
+	}
+	if check.Exists {
+		return nil, fmt.Errorf("群名已存在: %s", describeMatches(check))
+	}
+	if err := s.releaseStaleMatches(check); err != nil {
+		return nil, fmt.Errorf("更新本地记录失败: %s", err.Error())
+	}
+
+	// 确保群主在成员列表中
+	if !contains(req.MemberIDs, req.OwnerID) {
+		req.MemberIDs = append(req.MemberIDs, req.OwnerID)
+	}
+
+	row.State = models.JournalPending
+	if err := s.appendJournal(journal, row); err != nil {
+		return nil, err
+	}
+
+	// 调用钉钉API创建群组
+	resp, err := s.dingtalkClient.CreateGroup(ctx, req)
+	if err != nil {
+		if definitelyFailed(err) {
+			row.State, row.Error = models.JournalFailed, err.Error()
+			s.appendJournal(journal, row)
+		}
+		if ctx.Err() != nil {
+			// 请求可能已送达钉钉，无法确定群组是否已创建
+			return nil, fmt.Errorf("操作中断，群组可能已在钉钉创建，请核实")
+		}
+		return nil, wrapError("API调用失败: ", err)
+	}
+
+	row.State, row.ChatID = models.JournalCreated, resp.GroupID
+	if err := s.appendJournal(journal, row); err != nil {
+		return nil, fmt.Errorf("已在钉钉创建 (%s)，但%s", resp.GroupID, err.Error())
+	}
+
+	group, err := s.saveCreated(req, resp.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	row.State = models.JournalDone
+	s.appendJournal(journal, row)
+	return group, nil
+}
+
+// saveCreated 将钉钉中已创建的群组写入本地存储和共享登记表，本地已有该群组时只写登记表
+func (s *GroupService) saveCreated(req *models.GroupCreateRequest, chatID string) (*models.Group, error) {
+	group, err := s.storage.GetGroupByID(chatID)
+	if err != nil {
+		group = models.NewGroupWithType(req.Name, req.Description, req.OwnerID, req.GroupType, req.IsExternal)
+		group.ID = chatID
+		group.Members = req.MemberIDs
+		group.MemberCount = len(req.MemberIDs)
+
+		if err := s.storage.AddGroup(*group); err != nil {
+			return nil, fmt.Errorf("保存失败: %s", err.Error())
+		}
+	}
+
+	if err := s.register(group, models.RegistrySourceCreated); err != nil {
+		return nil, fmt.Errorf("已创建，但写入共享登记表失败: %s", err.Error())
+	}
+
+	return group, nil
+}
+
+// recoverCreated 补救上次运行中已在钉钉创建但未保存到本地的群组
+//
+// 先在钉钉中核实群组仍然存在，成员以钉钉中的实时信息为准；群组已解散时记为失败，
+// 下次继续时重新创建。
+func (s *GroupService) recoverCreated(ctx context.Context, req *models.GroupCreateRequest, chatID string, journal *storage.Journal, row models.JournalEntry) error {
+	row.ChatID = chatID
+
+	info, err := s.dingtalkClient.GetGroup(ctx, chatID)
+	if err != nil {
+		if dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound) {
+			row.State, row.Error = models.JournalFailed, err.Error()
+			s.appendJournal(journal, row)
+			return fmt.Errorf("上次创建的群组 %s 已不存在，下次继续时将重新创建", chatID)
+		}
+		return wrapError(fmt.Sprintf("核实上次创建的群组 %s 失败: ", chatID), err)
+	}
+	req.MemberIDs = info.Members
+
+	if _, err := s.saveCreated(req, chatID); err != nil {
+		return err
+	}
+
+	row.State = models.JournalDone
+	s.appendJournal(journal, row)
+	return nil
+}
+
+// appendJournal 写入批量操作日志，未使用日志或演练模式下不做任何操作
+func (s *GroupService) appendJournal(journal *storage.Journal, entry models.JournalEntry) error {
+	if journal == nil || s.dryRun {
+		return nil
+	}
+	return journal.Append(entry)
+}
+
+// definitelyFailed 钉钉是否明确拒绝了请求（返回错误码或限流），此时请求未生效，可以安全重做
+func definitelyFailed(err error) bool {
+	var apiErr *dingtalk.APIError
+	return errors.As(err, &apiErr) && (apiErr.Errcode != 0 || apiErr.HTTPStatus == http.StatusTooManyRequests)
+}
+
 // ListGroups 获取群组列表
 func (s *GroupService) ListGroups() (*models.GroupListResponse, error) {
 	groups, err := s.storage.LoadGroups()
@@ -173,103 +391,39 @@ func (s *GroupService) ListGroups() (*models.GroupListResponse, error) {
 	}, nil
 }
 
-// AddMembers 添加成员到群组
-func (s *GroupService) AddMembers(req *models.GroupMemberRequest) (*models.GroupMemberResponse, error) {
-	if len(req.UserIDs) == 0 {
-		return &models.GroupMemberResponse{
-			Success: false,
-			Message: "用户ID列表不能为空",
-		}, nil
-	}
-
-	var affectedGroups int
-	var errors []string
-
-	if req.AllGroups {
-		// 添加到所有群组
-		groups, err := s.storage.LoadGroups()
-		if err != nil {
-			return nil, fmt.Errorf("加载群组列表失败: %w", err)
-		}
-
-		for _, group := range groups {
-			if group.Status == "deleted" {
-				continue
-			}
-
-			// 调用钉钉API添加成员
-			if err := s.dingtalkClient.AddGroupMembers(group.ID, req.UserIDs); err != nil {
-				errors = append(errors, fmt.Sprintf("群组 %s: %s", group.Name, err.Error()))
-				continue
-			}
-
-			// 更新本地存储
-			for _, userID := range req.UserIDs {
-				group.AddMember(userID)
-			}
-			if err := s.storage.UpdateGroup(group); err != nil {
-				errors = append(errors, fmt.Sprintf("群组 %s 更新失败: %s", group.Name, err.Error()))
-				continue
-			}
-
-			affectedGroups++
-		}
-	} else {
-		// 添加到指定群组
-		if req.GroupID == "" {
-			return &models.GroupMemberResponse{
-				Success: false,
-				Message: "指定群组操作时必须提供群组ID",
-			}, nil
-		}
-
-		group, err := s.storage.GetGroupByID(req.GroupID)
-		if err != nil {
-			return &models.GroupMemberResponse{
-				Success: false,
-				Message: fmt.Sprintf("群组不存在: %s", err.Error()),
-			}, nil
-		}
-
+// AddMembers 添加成员到群组，全部群组模式下按 SetConcurrency 设置的并发数处理，
+// ctx 取消后不再处理剩余群组
+func (s *GroupService) AddMembers(ctx context.Context, req *models.GroupMemberRequest) (*models.GroupMemberResponse, error) {
+	return s.changeMembers(ctx, req, "成功添加成员到 %d 个群组", func(group *models.Group) error {
 		// 调用钉钉API添加成员
-		if err := s.dingtalkClient.AddGroupMembers(group.ID, req.UserIDs); err != nil {
-			return &models.GroupMemberResponse{
-				Success: false,
-				Message: fmt.Sprintf("添加成员失败: %s", err.Error()),
-			}, nil
+		if err := s.dingtalkClient.AddGroupMembers(ctx, group.ID, req.UserIDs); err != nil {
+			return wrapError("", err)
 		}
-
-		// 更新本地存储
 		for _, userID := range req.UserIDs {
 			group.AddMember(userID)
 		}
-		if err := s.storage.UpdateGroup(*group); err != nil {
-			return &models.GroupMemberResponse{
-				Success: false,
-				Message: fmt.Sprintf("更新群组信息失败: %s", err.Error()),
-			}, nil
-		}
-
-		affectedGroups = 1
-	}
-
-	// 构建响应消息
-	var message string
-	if len(errors) == 0 {
-		message = fmt.Sprintf("成功添加成员到 %d 个群组", affectedGroups)
-	} else {
-		message = fmt.Sprintf("部分成功：%d 个群组，错误：%s", affectedGroups, strings.Join(errors, "; "))
-	}
+		return nil
+	})
+}
 
-	return &models.GroupMemberResponse{
-		Success:  affectedGroups > 0,
-		Message:  message,
-		Affected: affectedGroups,
-	}, nil
+// RemoveMembers 从群组移除成员，全部群组模式下按 SetConcurrency 设置的并发数处理，
+// ctx 取消后不再处理剩余群组
+func (s *GroupService) RemoveMembers(ctx context.Context, req *models.GroupMemberRequest) (*models.GroupMemberResponse, error) {
+	return s.changeMembers(ctx, req, "成功从 %d 个群组移除成员", func(group *models.Group) error {
+		// 调用钉钉API移除成员
+		if err := s.dingtalkClient.RemoveGroupMembers(ctx, group.ID, req.UserIDs); err != nil {
+			return wrapError("", err)
+		}
+		for _, userID := range req.UserIDs {
+			group.RemoveMember(userID)
+		}
+		return nil
+	})
 }
 
-// RemoveMembers 从群组移除成员
-func (s *GroupService) RemoveMembers(req *models.GroupMemberRequest) (*models.GroupMemberResponse, error) {
+// changeMembers 对指定群组或全部群组执行成员变更，change 调用钉钉接口并修改 group，
+// 成功后写入本地存储。返回的 Results 按群组顺序包含每个群组的结果。
+func (s *GroupService) changeMembers(ctx context.Context, req *models.GroupMemberRequest, successFormat string, change func(group *models.Group) error) (*models.GroupMemberResponse, error) {
 	if len(req.UserIDs) == 0 {
 		return &models.GroupMemberResponse{
 			Success: false,
@@ -277,40 +431,25 @@ func (s *GroupService) RemoveMembers(req *models.GroupMemberRequest) (*models.Gr
 		}, nil
 	}
 
-	var affectedGroups int
-	var errors []string
+	// 解析 mobile:、email:、name: 形式的成员
+	userIDs, err := s.resolveUserIDs(ctx, req.UserIDs)
+	if err != nil {
+		return nil, fmt.Errorf("解析成员失败: %w", err)
+	}
+	req.UserIDs = userIDs
 
+	var groups []models.Group
 	if req.AllGroups {
-		// 从所有群组移除
-		groups, err := s.storage.LoadGroups()
+		allGroups, err := s.storage.LoadGroups()
 		if err != nil {
 			return nil, fmt.Errorf("加载群组列表失败: %w", err)
 		}
-
-		for _, group := range groups {
-			if group.Status == "deleted" {
-				continue
-			}
-
-			// 调用钉钉API移除成员
-			if err := s.dingtalkClient.RemoveGroupMembers(group.ID, req.UserIDs); err != nil {
-				errors = append(errors, fmt.Sprintf("群组 %s: %s", group.Name, err.Error()))
-				continue
+		for _, group := range allGroups {
+			if group.Status != "deleted" {
+				groups = append(groups, group)
 			}
-
-			// 更新本地存储
-			for _, userID := range req.UserIDs {
-				group.RemoveMember(userID)
-			}
-			if err := s.storage.UpdateGroup(group); err != nil {
-				errors = append(errors, fmt.Sprintf("群组 %s 更新失败: %s", group.Name, err.Error()))
-				continue
-			}
-
-			affectedGroups++
 		}
 	} else {
-		// 从指定群组移除
 		if req.GroupID == "" {
 			return &models.GroupMemberResponse{
 				Success: false,
@@ -325,50 +464,103 @@ func (s *GroupService) RemoveMembers(req *models.GroupMemberRequest) (*models.Gr
 				Message: fmt.Sprintf("群组不存在: %s", err.Error()),
 			}, nil
 		}
+		groups = []models.Group{*group}
+	}
 
-		// 调用钉钉API移除成员
-		if err := s.dingtalkClient.RemoveGroupMembers(group.ID, req.UserIDs); err != nil {
-			return &models.GroupMemberResponse{
-				Success: false,
-				Message: fmt.Sprintf("移除成员失败: %s", err.Error()),
-			}, nil
-		}
+	// 并发处理各群组，结果按群组顺序保存
+	results := make([]models.BatchItemResult, len(groups))
+	started := s.runBatch(ctx, len(groups), func(i int) {
+		begin := time.Now()
+		group := groups[i]
 
-		// 更新本地存储
-		for _, userID := range req.UserIDs {
-			group.RemoveMember(userID)
-		}
-		if err := s.storage.UpdateGroup(*group); err != nil {
-			return &models.GroupMemberResponse{
-				Success: false,
-				Message: fmt.Sprintf("更新群组信息失败: %s", err.Error()),
-			}, nil
+		err := change(&group)
+		if err == nil {
+			// 更新本地存储
+			if err = s.storage.UpdateGroup(group); err != nil {
+				err = fmt.Errorf("更新群组信息失败: %w", err)
+			}
 		}
 
-		affectedGroups = 1
+		results[i] = itemResult(group.ID, err)
+		results[i].Name = group.Name
+		results[i].DurationMS = time.Since(begin).Milliseconds()
+	})
+	for i := started; i < len(groups); i++ {
+		results[i] = notStartedItem(groups[i].Name)
+		results[i].GroupID = groups[i].ID
 	}
 
 	// 构建响应消息
+	var affectedGroups, skippedGroups int
+	var failures []string
+	for _, result := range results {
+		switch result.Status {
+		case models.ItemSucceeded:
+			affectedGroups++
+		case models.ItemNotStarted:
+			skippedGroups++
+		default:
+			failures = append(failures, fmt.Sprintf("群组 %s: %s", result.Name, result.Error))
+		}
+	}
+
 	var message string
-	if len(errors) == 0 {
-		message = fmt.Sprintf("成功从 %d 个群组移除成员", affectedGroups)
-	} else {
-		message = fmt.Sprintf("部分成功：%d 个群组，错误：%s", affectedGroups, strings.Join(errors, "; "))
+	switch {
+	case !req.AllGroups && len(failures) > 0:
+		message = results[0].Error
+	case len(failures) == 0:
+		message = fmt.Sprintf(successFormat, affectedGroups)
+	default:
+		message = fmt.Sprintf("部分成功：%d 个群组，错误：%s", affectedGroups, strings.Join(failures, "; "))
+	}
+	if skippedGroups > 0 {
+		message += fmt.Sprintf("\n操作已中断：剩余 %d 个群组未处理", skippedGroups)
 	}
 
 	return &models.GroupMemberResponse{
 		Success:  affectedGroups > 0,
 		Message:  message,
 		Affected: affectedGroups,
+		Results:  results,
 	}, nil
 }
 
-// CheckGroupExists 检查群组是否存在
-func (s *GroupService) CheckGroupExists(name string) bool {
-	return s.storage.GroupExists(name)
+// countActiveGroups 统计未删除的群组数量
+func countActiveGroups(groups []models.Group) int {
+	count := 0
+	for _, group := range groups {
+		if group.Status != "deleted" {
+			count++
+		}
+	}
+	return count
 }
 
 // ExportGroups 导出群组数据
 func (s *GroupService) ExportGroups(outputFile string) error {
-	return s.storage.(*storage.FileStorage).ExportGroupsToCSV(outputFile)
+	return s.storage.ExportGroupsToCSV(outputFile)
+}
+
+// withWarnings 将导入文件时的警告放在消息之前
+func withWarnings(warnings []string, message string) string {
+	for i := len(warnings) - 1; i >= 0; i-- {
+		message = "注意: " + warnings[i] + "\n" + message
+	}
+	return message
+}
+
+// wrapError 生成与 errorText 格式相同的错误，保留原始错误以便提取钉钉错误码
+func wrapError(prefix string, err error) error {
+	if hint := dingtalk.ErrorHint(err); hint != "" {
+		return fmt.Errorf("%s%w [提示: %s]", prefix, err, hint)
+	}
+	return fmt.Errorf("%s%w", prefix, err)
+}
+
+// errorText 格式化错误信息，钉钉错误码有处理建议时一并附上
+func errorText(err error) string {
+	if hint := dingtalk.ErrorHint(err); hint != "" {
+		return fmt.Sprintf("%s [提示: %s]", err.Error(), hint)
+	}
+	return err.Error()
 }
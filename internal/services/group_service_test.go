@@ -0,0 +1,550 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+	"ti-dding/internal/storage"
+)
+
+// newTestService 创建使用内存钉钉实现和临时数据目录的群组服务
+func newTestService(t *testing.T, fake *dingtalktest.Fake) (*GroupService, *storage.FileStorage) {
+	t.Helper()
+	store := storage.NewFileStorage(t.TempDir())
+	return NewGroupService(fake, store, &config.GroupConfig{}), store
+}
+
+// testDirectory 在内存钉钉实现上增加通讯录接口
+//
+// 未设置 departments 时只有部门1，全部员工都属于该部门。
+type testDirectory struct {
+	*dingtalktest.Fake
+	departments []models.Department
+	users       []models.User
+	mobileErr   error // GetUserIDByMobile 返回的错误
+	listCalls   int   // ListDepartmentUserDetails 的调用次数
+}
+
+var _ dingtalk.DirectoryAPI = (*testDirectory)(nil)
+
+// newDirectoryService 创建使用 directory 作为企业通讯录的群组服务
+func newDirectoryService(t *testing.T, directory *testDirectory, cfg *config.GroupConfig) (*GroupService, *storage.FileStorage) {
+	t.Helper()
+	if directory.Fake == nil {
+		directory.Fake = dingtalktest.NewFake()
+	}
+	store := storage.NewFileStorage(t.TempDir())
+	return NewGroupService(directory, store, cfg), store
+}
+
+func (d *testDirectory) ListDepartments(ctx context.Context) ([]models.Department, error) {
+	if d.departments == nil {
+		return []models.Department{{ID: 1, Name: "总部"}}, nil
+	}
+	return append([]models.Department(nil), d.departments...), nil
+}
+
+func (d *testDirectory) GetDepartment(ctx context.Context, departmentID int64) (*models.Department, error) {
+	if d.departments == nil {
+		return &models.Department{ID: departmentID, Name: "总部"}, nil
+	}
+	for _, dept := range d.departments {
+		if dept.ID == departmentID {
+			return &dept, nil
+		}
+	}
+	return nil, dingtalktest.NewAPIError(dingtalktest.MethodGetGroup, dingtalk.ErrcodeDepartmentNotFound, "部门不存在")
+}
+
+func (d *testDirectory) ListDepartmentUsers(ctx context.Context, departmentID int64) ([]models.User, error) {
+	return d.departmentUsers(departmentID), nil
+}
+
+func (d *testDirectory) ListDepartmentUserDetails(ctx context.Context, departmentID int64) ([]models.User, error) {
+	d.listCalls++
+	return d.departmentUsers(departmentID), nil
+}
+
+// departmentUsers 返回直接属于部门的员工
+func (d *testDirectory) departmentUsers(departmentID int64) []models.User {
+	if d.departments == nil {
+		return append([]models.User(nil), d.users...)
+	}
+	var users []models.User
+	for _, user := range d.users {
+		for _, id := range user.DepartmentIDs {
+			if id == departmentID {
+				users = append(users, user)
+				break
+			}
+		}
+	}
+	return users
+}
+
+func (d *testDirectory) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	for _, user := range d.users {
+		if user.UserID == userID {
+			return &user, nil
+		}
+	}
+	return nil, dingtalktest.NewAPIError(dingtalktest.MethodGetGroup, dingtalk.ErrcodeUserNotFound, "找不到该用户")
+}
+
+func (d *testDirectory) GetUserIDByMobile(ctx context.Context, mobile string) (string, error) {
+	if d.mobileErr != nil {
+		return "", d.mobileErr
+	}
+	for _, user := range d.users {
+		if user.Mobile == mobile {
+			return user.UserID, nil
+		}
+	}
+	return "", dingtalktest.NewAPIError(dingtalktest.MethodGetGroup, dingtalk.ErrcodeUserNotFound, "找不到该用户")
+}
+
+// writeFile 在临时目录中写入文件，返回文件路径
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// addLocalGroup 在钉钉和本地存储中同时预置一个群组，返回群组ID
+func addLocalGroup(t *testing.T, fake *dingtalktest.Fake, store *storage.FileStorage, name, owner string, members ...string) string {
+	t.Helper()
+	chatID := fake.AddChat(dingtalktest.Chat{Name: name, OwnerID: owner, Members: members})
+	chat, _ := fake.Chat(chatID)
+
+	group := models.NewGroupWithType(name, "", owner, "internal", false)
+	group.ID = chatID
+	group.Members = chat.Members
+	group.MemberCount = len(chat.Members)
+	if err := store.AddGroup(*group); err != nil {
+		t.Fatal(err)
+	}
+	return chatID
+}
+
+// sorted 返回排序后的副本，便于比较成员列表
+func sorted(values []string) []string {
+	values = append([]string(nil), values...)
+	sort.Strings(values)
+	return values
+}
+
+func TestCreateGroupsFromCSV(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表,群组类型\n"+
+		"研发群,u1,\"u2, u3\",内部群\n"+
+		"客户群,u4,u5,外部群\n")
+
+	resp, err := service.CreateGroupsFromCSV(context.Background(), file, nil, false, false)
+	if err != nil {
+		t.Fatalf("CreateGroupsFromCSV: %v", err)
+	}
+	if !resp.Success || len(resp.Results) != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	chats := fake.Chats()
+	if len(chats) != 2 {
+		t.Fatalf("created %d chats, want 2", len(chats))
+	}
+	byName := map[string]dingtalktest.Chat{}
+	for _, chat := range chats {
+		byName[chat.Name] = chat
+	}
+	if got := sorted(byName["研发群"].Members); strings.Join(got, ",") != "u1,u2,u3" {
+		t.Errorf("研发群 members = %v", got)
+	}
+	if !byName["客户群"].External {
+		t.Error("客户群 should be external")
+	}
+
+	for _, result := range resp.Results {
+		if result.Status != models.ItemSucceeded {
+			t.Fatalf("row %d: %+v", result.Row, result)
+		}
+		group, err := store.GetGroupByID(result.GroupID)
+		if err != nil {
+			t.Fatalf("row %d not saved locally: %v", result.Row, err)
+		}
+		if group.Name != result.Name {
+			t.Errorf("row %d saved as %s", result.Row, group.Name)
+		}
+	}
+}
+
+func TestCreateGroupsFromCSVPartialFailure(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2")
+	service, store := newTestService(t, fake)
+	addLocalGroup(t, fake, store, "已有群", "u1")
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表\n"+
+		"新群,u1,u2\n"+
+		"已有群,u1,u2\n"+
+		"无效成员群,u1,nobody\n"+
+		"新群,u2,u1\n")
+
+	resp, err := service.CreateGroupsFromCSV(context.Background(), file, nil, false, false)
+	if err != nil {
+		t.Fatalf("CreateGroupsFromCSV: %v", err)
+	}
+
+	want := []struct {
+		row    int
+		status string
+		errSub string
+	}{
+		{2, models.ItemSucceeded, ""},
+		{3, models.ItemFailed, "群名已存在"},
+		{4, models.ItemFailed, "找不到该用户"},
+		{5, models.ItemFailed, "与第2行群名重复"},
+	}
+	for i, w := range want {
+		got := resp.Results[i]
+		if got.Row != w.row || got.Status != w.status || !strings.Contains(got.Error, w.errSub) {
+			t.Errorf("result %d = %+v, want row %d status %s error containing %q", i, got, w.row, w.status, w.errSub)
+		}
+	}
+	if got := resp.Results[2].Errcode; got != dingtalk.ErrcodeUserNotFound {
+		t.Errorf("errcode = %d, want %d", got, dingtalk.ErrcodeUserNotFound)
+	}
+	if len(fake.Chats()) != 2 {
+		t.Errorf("fake has %d chats, want 2", len(fake.Chats()))
+	}
+}
+
+func TestCreateGroupsFromCSVCancelled(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2")
+	service, store := newTestService(t, fake)
+	service.SetConcurrency(1)
+
+	// 创建第3个群时收到中断信号
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake.FailWhen(func(call dingtalktest.Call) error {
+		if call.Method == dingtalktest.MethodCreateGroup && call.Request.Name == "群3" {
+			cancel()
+		}
+		return nil
+	})
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表\n"+
+		"群1,u1,u2\n群2,u1,u2\n群3,u1,u2\n群4,u1,u2\n群5,u1,u2\n")
+	resp, err := service.CreateGroupsFromCSV(ctx, file, nil, false, false)
+	if err != nil {
+		t.Fatalf("CreateGroupsFromCSV: %v", err)
+	}
+
+	wantStatus := []string{models.ItemSucceeded, models.ItemSucceeded, models.ItemFailed, models.ItemNotStarted, models.ItemNotStarted}
+	for i, want := range wantStatus {
+		if got := resp.Results[i]; got.Status != want || got.Row != i+2 {
+			t.Errorf("result %d = %+v, want status %s", i, got, want)
+		}
+	}
+
+	// 中断前创建的群组已写入本地存储
+	groups, err := store.LoadGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Name != "群1" || groups[1].Name != "群2" {
+		t.Errorf("stored groups = %+v", groups)
+	}
+
+	for _, sub := range []string{"成功创建 2 个群组", "失败 1 个群组", "操作已中断：剩余 2 个群组未处理"} {
+		if !strings.Contains(resp.Message, sub) {
+			t.Errorf("message %q missing %q", resp.Message, sub)
+		}
+	}
+}
+
+func TestCreateGroupsFromCSVMissingOwner(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, _ := newTestService(t, fake)
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID\n研发群,u1\n测试群,\n")
+	_, err := service.CreateGroupsFromCSV(context.Background(), file, nil, false, false)
+	if err == nil || !strings.Contains(err.Error(), "第3行群主用户ID不能为空") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Fatalf("made %d API calls before rejecting the file", len(fake.Calls()))
+	}
+}
+
+func TestAddAndRemoveMembers(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u2")
+	ctx := context.Background()
+
+	resp, err := service.AddMembers(ctx, &models.GroupMemberRequest{GroupID: chatID, UserIDs: []string{"u3", "u4"}})
+	if err != nil || !resp.Success {
+		t.Fatalf("AddMembers: %+v, %v", resp, err)
+	}
+	chat, _ := fake.Chat(chatID)
+	if got := strings.Join(sorted(chat.Members), ","); got != "u1,u2,u3,u4" {
+		t.Fatalf("members after add = %s", got)
+	}
+	group, _ := store.GetGroupByID(chatID)
+	if got := strings.Join(sorted(group.Members), ","); got != "u1,u2,u3,u4" || group.MemberCount != 4 {
+		t.Fatalf("local members after add = %s (%d)", got, group.MemberCount)
+	}
+
+	resp, err = service.RemoveMembers(ctx, &models.GroupMemberRequest{GroupID: chatID, UserIDs: []string{"u2", "u4"}})
+	if err != nil || !resp.Success {
+		t.Fatalf("RemoveMembers: %+v, %v", resp, err)
+	}
+	chat, _ = fake.Chat(chatID)
+	if got := strings.Join(sorted(chat.Members), ","); got != "u1,u3" {
+		t.Fatalf("members after remove = %s", got)
+	}
+	group, _ = store.GetGroupByID(chatID)
+	if got := strings.Join(sorted(group.Members), ","); got != "u1,u3" {
+		t.Fatalf("local members after remove = %s", got)
+	}
+}
+
+func TestAddMembersAllGroupsPartialFailure(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	service.SetConcurrency(4)
+	first := addLocalGroup(t, fake, store, "群1", "u1")
+	second := addLocalGroup(t, fake, store, "群2", "u1")
+	third := addLocalGroup(t, fake, store, "群3", "u1")
+
+	fake.FailWhen(func(call dingtalktest.Call) error {
+		if call.Method == dingtalktest.MethodAddGroupMembers && call.ChatID == second {
+			return dingtalktest.NewAPIError(call.Method, dingtalk.ErrcodeNoPermission, "权限不足")
+		}
+		return nil
+	})
+
+	resp, err := service.AddMembers(context.Background(), &models.GroupMemberRequest{UserIDs: []string{"u9"}, AllGroups: true})
+	if err != nil {
+		t.Fatalf("AddMembers: %v", err)
+	}
+	if resp.Affected != 2 || len(resp.Results) != 3 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Results[1].Status != models.ItemFailed || resp.Results[1].Errcode != dingtalk.ErrcodeNoPermission {
+		t.Fatalf("群2 result = %+v", resp.Results[1])
+	}
+
+	for _, chatID := range []string{first, third} {
+		group, _ := store.GetGroupByID(chatID)
+		if !group.IsMember("u9") {
+			t.Errorf("%s: u9 not recorded locally", group.Name)
+		}
+	}
+	group, _ := store.GetGroupByID(second)
+	if group.IsMember("u9") {
+		t.Error("群2: failed change recorded locally")
+	}
+}
+
+func TestRemoveMembersKeepsOwner(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u2")
+
+	if _, err := service.RemoveMembers(context.Background(), &models.GroupMemberRequest{GroupID: chatID, UserIDs: []string{"u1"}}); err != nil {
+		t.Fatal(err)
+	}
+	chat, _ := fake.Chat(chatID)
+	if chat.OwnerID != "u1" || strings.Join(sorted(chat.Members), ",") != "u1,u2" {
+		t.Fatalf("owner removed from chat: %+v", chat)
+	}
+}
+
+func TestChangeMembersRequiresUsers(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, _ := newTestService(t, fake)
+
+	resp, err := service.AddMembers(context.Background(), &models.GroupMemberRequest{GroupID: "chat1"})
+	if err != nil || resp.Success {
+		t.Fatalf("AddMembers without users: %+v, %v", resp, err)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Fatalf("made %d API calls", len(fake.Calls()))
+	}
+}
+
+func TestCreateGroupsFromCSVResume(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	ctx := context.Background()
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表\n"+
+		"已完成群,u1,u2\n"+
+		"已创建群,u1,u2\n"+
+		"已保存群,u1,u2\n"+
+		"未知群,u1,u2\n"+
+		"新群,u1,u2\n")
+
+	// 上次运行在各个阶段中断
+	createdID := fake.AddChat(dingtalktest.Chat{Name: "已创建群", OwnerID: "u1", Members: []string{"u1", "u2", "u3"}})
+	savedID := addLocalGroup(t, fake, store, "已保存群", "u1", "u2")
+	journal := storage.NewJournal(t.TempDir(), "create", file)
+	for _, entry := range []models.JournalEntry{
+		{Row: 2, Key: "已完成群", State: models.JournalDone, ChatID: "chat-done"},
+		{Row: 3, Key: "已创建群", State: models.JournalCreated, ChatID: createdID},
+		{Row: 4, Key: "已保存群", State: models.JournalPending},
+		{Row: 5, Key: "未知群", State: models.JournalPending},
+	} {
+		if err := journal.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := service.CreateGroupsFromCSV(ctx, file, journal, false, false); err == nil || !strings.Contains(err.Error(), "--resume") {
+		t.Fatalf("run without --resume: err = %v", err)
+	}
+
+	resp, err := service.CreateGroupsFromCSV(ctx, file, journal, true, false)
+	if err != nil {
+		t.Fatalf("CreateGroupsFromCSV: %v", err)
+	}
+	want := []struct {
+		status  string
+		groupID string
+	}{
+		{models.ItemSkipped, "chat-done"},
+		{models.ItemSucceeded, createdID},
+		{models.ItemSkipped, savedID},
+		{models.ItemUnknown, ""},
+		{models.ItemSucceeded, ""},
+	}
+	for i, w := range want {
+		got := resp.Results[i]
+		if got.Row != i+2 || got.Status != w.status || (w.groupID != "" && got.GroupID != w.groupID) {
+			t.Errorf("result %d = %+v, want status %s group %s", i, got, w.status, w.groupID)
+		}
+	}
+	if !strings.Contains(resp.Message, "未知群 (第5行)") {
+		t.Errorf("message %q missing the unknown row", resp.Message)
+	}
+
+	// 已创建的群组以钉钉中的成员补写本地记录，不重复创建
+	group, err := store.GetGroupByID(createdID)
+	if err != nil || strings.Join(sorted(group.Members), ",") != "u1,u2,u3" {
+		t.Fatalf("recovered group = %+v, %v", group, err)
+	}
+	creates := 0
+	for _, call := range fake.Calls() {
+		if call.Method == dingtalktest.MethodCreateGroup {
+			creates++
+		}
+	}
+	if creates != 1 {
+		t.Errorf("CreateGroup called %d times, want 1 (新群 only)", creates)
+	}
+	if _, err := store.GetGroupByName("未知群"); err == nil || !journal.Exists() {
+		t.Fatal("unknown row should be left for manual verification and the journal kept")
+	}
+
+	// 确认未创建后重新创建，全部完成时删除日志
+	resp, err = service.CreateGroupsFromCSV(ctx, file, journal, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Results[3].Status != models.ItemSucceeded {
+		t.Fatalf("retry unknown = %+v", resp.Results[3])
+	}
+	for i, result := range resp.Results {
+		if i != 3 && result.Status != models.ItemSkipped {
+			t.Errorf("result %d = %+v, want skipped", i, result)
+		}
+	}
+	if journal.Exists() {
+		t.Error("journal not removed after all rows completed")
+	}
+}
+
+func TestCreateGroupsFromCSVResumeDissolved(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, _ := newTestService(t, fake)
+	ctx := context.Background()
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID\n研发群,u1\n")
+	journal := storage.NewJournal(t.TempDir(), "create", file)
+	if err := journal.Append(models.JournalEntry{Row: 2, Key: "研发群", State: models.JournalCreated, ChatID: "chat-gone"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := service.CreateGroupsFromCSV(ctx, file, journal, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Results[0].Status != models.ItemFailed || !strings.Contains(resp.Results[0].Error, "已不存在") {
+		t.Fatalf("result = %+v", resp.Results[0])
+	}
+
+	// 下次继续时重新创建
+	resp, err = service.CreateGroupsFromCSV(ctx, file, journal, true, false)
+	if err != nil || resp.Results[0].Status != models.ItemSucceeded {
+		t.Fatalf("second resume = %+v, %v", resp, err)
+	}
+	if len(fake.Chats()) != 1 || journal.Exists() {
+		t.Fatalf("chats = %d, journal exists = %v", len(fake.Chats()), journal.Exists())
+	}
+}
+
+func TestCSVRowsNumberedByFileLine(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, _ := newTestService(t, fake)
+	ctx := context.Background()
+
+	// 引号中的成员列表跨越两行，之后还有一个空行
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表\n"+
+		"研发群,u1,\"u2,\nu3\"\n"+
+		"\n"+
+		"研发群,u4,u5\n"+
+		"测试群,,u6\n")
+
+	validation, err := service.ValidateGroupsCSV(ctx, file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := map[int]bool{}
+	for _, issue := range validation.Issues {
+		lines[issue.Line] = true
+	}
+	if len(lines) != 2 || !lines[5] || !lines[6] {
+		t.Fatalf("issues = %+v, want lines 5 and 6", validation.Issues)
+	}
+
+	if _, err := service.CreateGroupsFromCSV(ctx, file, nil, false, false); err == nil || !strings.Contains(err.Error(), "第6行群主用户ID不能为空") {
+		t.Fatalf("err = %v", err)
+	}
+
+	file = writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表\n"+
+		"研发群,u1,\"u2,\nu3\"\n"+
+		"\n"+
+		"研发群,u4,u5\n")
+	resp, err := service.CreateGroupsFromCSV(ctx, file, nil, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Results[0].Row != 2 || resp.Results[1].Row != 5 || !strings.Contains(resp.Results[1].Error, "与第2行群名重复") {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+}
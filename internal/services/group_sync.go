@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// SyncGroups 从钉钉拉取本地存储中每个群组的实时信息并更新本地记录
+//
+// 名称、群主、成员和会话类型以钉钉为准，钉钉中已解散的群组标记为 deleted。
+// checkOnly 为 true 时只报告差异，不修改本地存储。ctx 取消后不再处理剩余群组。
+func (s *GroupService) SyncGroups(ctx context.Context, checkOnly bool) (*models.GroupSyncResponse, error) {
+	groups, err := s.storage.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("加载群组列表失败: %w", err)
+	}
+
+	resp := &models.GroupSyncResponse{CheckOnly: checkOnly}
+	for i, group := range groups {
+		if ctx.Err() != nil {
+			resp.Skipped = countActiveGroups(groups[i:])
+			break
+		}
+
+		if group.Status == "deleted" {
+			continue
+		}
+
+		info, err := s.dingtalkClient.GetGroup(ctx, group.ID)
+		if err != nil && !dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound) {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("群组 %s: %s", group.Name, errorText(err)))
+			continue
+		}
+		resp.Checked++
+
+		change := models.GroupSyncChange{GroupID: group.ID, Name: group.Name}
+		if err != nil {
+			change.Action = models.SyncDeleted
+			change.Diffs = []models.GroupFieldDiff{{Field: "status", Local: group.Status, Remote: "deleted"}}
+		} else {
+			change.Action = models.SyncUpdated
+			change.Diffs = diffGroup(&group, info)
+			if len(change.Diffs) == 0 {
+				// 本地还没有群设置时顺便记录，不算作差异
+				if group.Settings == nil && !checkOnly {
+					applyChatInfo(&group, info)
+					if err := s.storage.UpdateGroup(group); err != nil {
+						resp.Errors = append(resp.Errors, fmt.Sprintf("群组 %s 更新失败: %s", group.Name, err.Error()))
+					}
+				}
+				continue
+			}
+		}
+
+		if !checkOnly {
+			var err error
+			if change.Action == models.SyncDeleted {
+				err = s.storage.DeleteGroup(group.ID)
+			} else {
+				applyChatInfo(&group, info)
+				err = s.storage.UpdateGroup(group)
+			}
+			if err != nil {
+				resp.Errors = append(resp.Errors, fmt.Sprintf("群组 %s 更新失败: %s", group.Name, err.Error()))
+				continue
+			}
+		}
+
+		resp.Changes = append(resp.Changes, change)
+	}
+
+	return resp, nil
+}
+
+// applyChatInfo 用钉钉实时信息覆盖本地记录
+func applyChatInfo(group *models.Group, info *models.ChatInfo) {
+	group.Name = info.Name
+	group.OwnerID = info.OwnerID
+	group.Members = append([]string(nil), info.Members...)
+	group.MemberCount = len(group.Members)
+	group.GroupType = info.GroupType()
+	group.IsExternal = group.GroupType == "external"
+	settings := info.ChatSettings
+	group.Settings = &settings
+	group.UpdatedAt = time.Now()
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+// driftedStore 预置三个群组：一个与钉钉一致，一个在钉钉中被改名，一个已在钉钉中解散
+func driftedStore(t *testing.T) (*GroupService, *dingtalktest.Fake, map[string]string) {
+	t.Helper()
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	ids := map[string]string{
+		"一致":  addLocalGroup(t, fake, store, "一致群", "u1", "u1", "u2"),
+		"改名":  addLocalGroup(t, fake, store, "研发群", "u1", "u1", "u2"),
+		"已解散": addLocalGroup(t, fake, store, "临时群", "u1", "u1"),
+	}
+	if err := fake.UpdateGroup(context.Background(), &models.GroupUpdateRequest{GroupID: ids["改名"], Name: "研发一群"}); err != nil {
+		t.Fatal(err)
+	}
+	fake.RemoveChat(ids["已解散"])
+	return service, fake, ids
+}
+
+func TestSyncGroupsCheckOnly(t *testing.T) {
+	service, _, ids := driftedStore(t)
+	before, err := service.storage.LoadGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := service.SyncGroups(context.Background(), true)
+	if err != nil {
+		t.Fatalf("SyncGroups: %v", err)
+	}
+	if !resp.CheckOnly || resp.Checked != 3 || len(resp.Errors) != 0 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if len(resp.Changes) != 2 {
+		t.Fatalf("changes = %+v, want 2", resp.Changes)
+	}
+	for _, change := range resp.Changes {
+		switch change.GroupID {
+		case ids["改名"]:
+			if change.Action != models.SyncUpdated || len(change.Diffs) != 1 || change.Diffs[0].Remote != "研发一群" {
+				t.Errorf("renamed group change = %+v", change)
+			}
+		case ids["已解散"]:
+			if change.Action != models.SyncDeleted {
+				t.Errorf("dissolved group change = %+v", change)
+			}
+		default:
+			t.Errorf("unexpected change %+v", change)
+		}
+	}
+
+	// 只检查时不修改本地存储
+	after, err := service.storage.LoadGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range before {
+		if after[i].Name != before[i].Name || after[i].Status != before[i].Status || after[i].Settings != nil {
+			t.Errorf("group %s modified by --check: %+v", before[i].ID, after[i])
+		}
+	}
+}
+
+func TestSyncGroupsApply(t *testing.T) {
+	service, fake, ids := driftedStore(t)
+	ctx := context.Background()
+
+	resp, err := service.SyncGroups(ctx, false)
+	if err != nil {
+		t.Fatalf("SyncGroups: %v", err)
+	}
+	if len(resp.Changes) != 2 || len(resp.Errors) != 0 {
+		t.Fatalf("resp = %+v", resp)
+	}
+
+	renamed, err := service.storage.GetGroupByID(ids["改名"])
+	if err != nil || renamed.Name != "研发一群" || renamed.Settings == nil {
+		t.Errorf("renamed group = %+v, %v", renamed, err)
+	}
+	in, err := service.storage.GetGroupByID(ids["一致"])
+	if err != nil || in.Settings == nil {
+		t.Errorf("in-sync group settings not recorded: %+v, %v", in, err)
+	}
+	groups, _ := service.storage.LoadGroups()
+	for _, group := range groups {
+		if group.ID == ids["已解散"] && group.Status != "deleted" {
+			t.Errorf("dissolved group status = %s", group.Status)
+		}
+	}
+
+	// 再次同步时没有差异，已删除的群组不再查询
+	calls := len(fake.Calls())
+	resp, err = service.SyncGroups(ctx, true)
+	if err != nil || len(resp.Changes) != 0 || resp.Checked != 2 {
+		t.Errorf("second sync = %+v, %v", resp, err)
+	}
+	if got := len(fake.Calls()) - calls; got != 2 {
+		t.Errorf("second sync made %d calls, want 2", got)
+	}
+}
+
+func TestSyncGroupsReportsErrors(t *testing.T) {
+	service, fake, _ := driftedStore(t)
+	fake.FailNext(dingtalktest.MethodGetGroup, dingtalktest.NewAPIError(dingtalktest.MethodGetGroup, 60011, "权限不足"))
+
+	resp, err := service.SyncGroups(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) != 1 || resp.Checked != 2 {
+		t.Errorf("resp = %+v, want one error and two checked groups", resp)
+	}
+}
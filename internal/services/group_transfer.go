@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// TransferOwnership 将 from 担任群主的全部群组转移给 to
+//
+// 按本地记录查找群组；verify 为 true 时先从钉钉核实当前群主，并用实时信息
+// 刷新本地记录，群主已不是 from 的群组跳过。新群主不在群中时先将其添加为成员。
+// ctx 取消后不再处理剩余群组。
+func (s *GroupService) TransferOwnership(ctx context.Context, from, to string, verify bool) (*models.OwnerTransferResponse, error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("原群主和新群主不能为空")
+	}
+	if from == to {
+		return nil, fmt.Errorf("原群主和新群主不能相同")
+	}
+
+	groups, err := s.storage.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("加载群组列表失败: %w", err)
+	}
+
+	var owned []models.Group
+	for _, group := range groups {
+		if group.Status != "deleted" && group.OwnerID == from {
+			owned = append(owned, group)
+		}
+	}
+
+	resp := &models.OwnerTransferResponse{From: from, To: to}
+	for i, group := range owned {
+		if ctx.Err() != nil {
+			resp.Skipped = len(owned) - i
+			break
+		}
+
+		result := models.OwnerTransferResult{GroupID: group.ID, Name: group.Name}
+		result.Status, result.Message = s.transferOne(ctx, group, from, to, verify)
+		resp.Results = append(resp.Results, result)
+	}
+
+	return resp, nil
+}
+
+// transferOne 转移单个群组的群主，返回结果状态和说明
+func (s *GroupService) transferOne(ctx context.Context, group models.Group, from, to string, verify bool) (string, string) {
+	if verify {
+		info, err := s.dingtalkClient.GetGroup(ctx, group.ID)
+		if dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound) {
+			if err := s.storage.DeleteGroup(group.ID); err != nil {
+				return models.TransferFailed, fmt.Sprintf("群组已在钉钉中解散，更新本地记录失败: %s", err.Error())
+			}
+			return models.TransferSkipped, "群组已在钉钉中解散，已标记为删除"
+		}
+		if err != nil {
+			return models.TransferFailed, fmt.Sprintf("核实群主失败: %s", errorText(err))
+		}
+
+		// 用实时信息刷新本地记录，确保按真实成员列表判断新群主是否需要入群
+		if len(diffGroup(&group, info)) > 0 {
+			applyChatInfo(&group, info)
+			if err := s.storage.UpdateGroup(group); err != nil {
+				return models.TransferFailed, fmt.Sprintf("更新本地记录失败: %s", err.Error())
+			}
+		}
+		if info.OwnerID == to {
+			return models.TransferSkipped, fmt.Sprintf("钉钉中的群主已是 %s，已更新本地记录", to)
+		}
+		if info.OwnerID != from {
+			return models.TransferSkipped, fmt.Sprintf("钉钉中的群主已变为 %s，已更新本地记录", info.OwnerID)
+		}
+	}
+
+	if _, err := s.UpdateGroup(ctx, &models.GroupUpdateRequest{GroupID: group.ID, OwnerID: to}); err != nil {
+		return models.TransferFailed, errorText(err)
+	}
+	return models.TransferDone, ""
+}
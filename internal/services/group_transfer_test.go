@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+func TestTransferOwnership(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2", "u3")
+	service, store := newTestService(t, fake)
+	withSuccessor := addLocalGroup(t, fake, store, "研发群", "u1", "u1", "u2")
+	withoutSuccessor := addLocalGroup(t, fake, store, "市场群", "u1", "u1", "u3")
+	addLocalGroup(t, fake, store, "其他群", "u3", "u3")
+
+	resp, err := service.TransferOwnership(context.Background(), "u1", "u2", false)
+	if err != nil {
+		t.Fatalf("TransferOwnership: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Skipped != 0 {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+	for _, result := range resp.Results {
+		if result.Status != models.TransferDone {
+			t.Errorf("result = %+v", result)
+		}
+	}
+
+	for _, chatID := range []string{withSuccessor, withoutSuccessor} {
+		chat, _ := fake.Chat(chatID)
+		group, _ := store.GetGroupByID(chatID)
+		if chat.OwnerID != "u2" || group.OwnerID != "u2" || !group.IsMember("u2") {
+			t.Errorf("%s: chat owner %s, stored %+v", chatID, chat.OwnerID, group)
+		}
+	}
+	// 新群主原本不在群中时先入群
+	if chat, _ := fake.Chat(withoutSuccessor); len(chat.Members) != 3 {
+		t.Errorf("members = %v, want successor added", chat.Members)
+	}
+}
+
+func TestTransferOwnershipVerify(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2", "u3")
+	service, store := newTestService(t, fake)
+	ctx := context.Background()
+	stale := addLocalGroup(t, fake, store, "已换群主", "u1", "u1", "u3")
+	dissolved := addLocalGroup(t, fake, store, "已解散", "u1", "u1")
+	normal := addLocalGroup(t, fake, store, "研发群", "u1", "u1", "u2")
+
+	// 钉钉中已换了群主、群已解散，本地记录尚未同步
+	if err := fake.UpdateGroup(ctx, &models.GroupUpdateRequest{GroupID: stale, OwnerID: "u3"}); err != nil {
+		t.Fatal(err)
+	}
+	fake.RemoveChat(dissolved)
+
+	resp, err := service.TransferOwnership(ctx, "u1", "u2", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{stale: models.TransferSkipped, dissolved: models.TransferSkipped, normal: models.TransferDone}
+	for _, result := range resp.Results {
+		if result.Status != want[result.GroupID] {
+			t.Errorf("%s: status %s (%s), want %s", result.Name, result.Status, result.Message, want[result.GroupID])
+		}
+	}
+
+	if chat, _ := fake.Chat(stale); chat.OwnerID != "u3" {
+		t.Errorf("stale group owner changed to %s", chat.OwnerID)
+	}
+	if group, _ := store.GetGroupByID(stale); group.OwnerID != "u3" {
+		t.Errorf("stale local record not refreshed: %+v", group)
+	}
+	groups, _ := store.LoadGroups()
+	for _, group := range groups {
+		if group.ID == dissolved && group.Status != "deleted" {
+			t.Errorf("dissolved group status = %s", group.Status)
+		}
+	}
+}
+
+func TestTransferOwnershipFailure(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2")
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u1", "u2")
+	fake.FailNext(dingtalktest.MethodUpdateGroup, dingtalktest.NewAPIError(dingtalktest.MethodUpdateGroup, 60011, "权限不足"))
+
+	resp, err := service.TransferOwnership(context.Background(), "u1", "u2", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Status != models.TransferFailed {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+	if group, _ := store.GetGroupByID(chatID); group.OwnerID != "u1" {
+		t.Errorf("local owner changed after failure: %s", group.OwnerID)
+	}
+
+	for _, users := range [][2]string{{"", "u2"}, {"u1", ""}, {"u1", "u1"}} {
+		if _, err := service.TransferOwnership(context.Background(), users[0], users[1], false); err == nil {
+			t.Errorf("TransferOwnership(%q, %q) should fail", users[0], users[1])
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"ti-dding/internal/models"
+)
+
+// ResolveGroup 按群组ID或群名称查找本地存储中的群组，先按ID查找
+func (s *GroupService) ResolveGroup(ref string) (*models.Group, error) {
+	if group, err := s.storage.GetGroupByID(ref); err == nil {
+		return group, nil
+	}
+	group, err := s.storage.GetGroupByName(ref)
+	if err != nil {
+		return nil, fmt.Errorf("群组不存在: %s", ref)
+	}
+	return group, nil
+}
+
+// UpdateGroup 修改群组的名称、群主和群设置，成功后更新本地记录
+//
+// 与本地记录相同的字段不提交；新名称与其他群组冲突时拒绝修改；新群主不在群中时
+// 先将其添加为成员。
+func (s *GroupService) UpdateGroup(ctx context.Context, req *models.GroupUpdateRequest) (*models.Group, error) {
+	group, err := s.storage.GetGroupByID(req.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateGroup(ctx, group, req)
+}
+
+// updateGroup 按 group 判断需要修改的字段，修改群组后将结果写入本地记录
+//
+// group 通常就是本地记录，按实时信息执行计划时为已刷新为钉钉当前状态的记录。
+// 本地还没有群设置时，以钉钉中的当前设置为基础记录修改后的设置，使下次对比时
+// 不再把已修改的设置当作差异。
+func (s *GroupService) updateGroup(ctx context.Context, group *models.Group, req *models.GroupUpdateRequest) (*models.Group, error) {
+	update := *req
+	if update.Name == group.Name {
+		update.Name = ""
+	}
+	if update.OwnerID == group.OwnerID {
+		update.OwnerID = ""
+	}
+	if update.Description == group.Description {
+		update.Description = ""
+	}
+	if update.Name == "" && update.OwnerID == "" && update.Description == "" && update.Settings.IsEmpty() {
+		return nil, fmt.Errorf("没有需要修改的内容")
+	}
+
+	if update.Name != "" {
+		if err := s.checkNameAvailable(ctx, update.Name, group.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if update.OwnerID != "" && !group.IsMember(update.OwnerID) {
+		if err := s.dingtalkClient.AddGroupMembers(ctx, group.ID, []string{update.OwnerID}); err != nil {
+			return nil, fmt.Errorf("添加新群主为群成员失败: %w", err)
+		}
+		group.AddMember(update.OwnerID)
+		if err := s.storage.UpdateGroup(*group); err != nil {
+			return nil, fmt.Errorf("更新群组信息失败: %w", err)
+		}
+	}
+
+	if update.Name != "" || update.OwnerID != "" || !update.Settings.IsEmpty() {
+		if err := s.dingtalkClient.UpdateGroup(ctx, &update); err != nil {
+			return nil, err
+		}
+	}
+
+	if update.Name != "" {
+		group.Name = update.Name
+	}
+	if update.OwnerID != "" {
+		group.OwnerID = update.OwnerID
+	}
+	if update.Description != "" {
+		group.Description = update.Description
+	}
+	if group.Settings == nil && !update.Settings.IsEmpty() {
+		group.Settings = s.currentSettings(ctx, group.ID)
+	}
+	if group.Settings != nil {
+		update.Settings.Apply(group.Settings)
+	}
+	group.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateGroup(*group); err != nil {
+		return nil, fmt.Errorf("更新群组信息失败: %w", err)
+	}
+	if update.Name != "" && s.registry != nil {
+		if err := s.registry.Rename(group.ID, group.Name); err != nil {
+			return nil, fmt.Errorf("更新共享登记表失败: %w", err)
+		}
+	}
+
+	return group, nil
+}
+
+// currentSettings 从钉钉读取群组的完整群设置，读取失败时返回全部为默认值的设置，
+// 修改的各项随后照常记录
+func (s *GroupService) currentSettings(ctx context.Context, groupID string) *models.ChatSettings {
+	if info, err := s.dingtalkClient.GetGroup(ctx, groupID); err == nil {
+		settings := info.ChatSettings
+		return &settings
+	}
+	return &models.ChatSettings{}
+}
+
+// UpdateGroupsFromCSV 从CSV文件批量修改群组，ctx 取消后不再处理剩余行
+func (s *GroupService) UpdateGroupsFromCSV(ctx context.Context, csvFile string) (*models.GroupUpdateResponse, error) {
+	rows, warnings, err := s.storage.LoadGroupUpdatesFromCSV(csvFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载群组文件失败: %w", err)
+	}
+
+	var successCount, skippedCount int
+	var failedGroups []string
+
+	for i, row := range rows {
+		if ctx.Err() != nil {
+			skippedCount = len(rows) - i
+			break
+		}
+
+		group, err := s.ResolveGroup(row.Group)
+		if err != nil {
+			failedGroups = append(failedGroups, fmt.Sprintf("%s (%s)", row.Group, err.Error()))
+			continue
+		}
+
+		req, err := updateRequestFromCSV(group.ID, row)
+		if err != nil {
+			failedGroups = append(failedGroups, fmt.Sprintf("%s (%s)", row.Group, err.Error()))
+			continue
+		}
+
+		if _, err := s.UpdateGroup(ctx, req); err != nil {
+			failedGroups = append(failedGroups, fmt.Sprintf("%s (%s)", row.Group, errorText(err)))
+			continue
+		}
+
+		successCount++
+	}
+
+	// 构建响应消息
+	var message string
+	if successCount > 0 {
+		message = fmt.Sprintf("成功修改 %d 个群组", successCount)
+		if len(failedGroups) > 0 {
+			message += fmt.Sprintf("，失败 %d 个群组", len(failedGroups))
+		}
+	} else {
+		message = "没有成功修改任何群组"
+	}
+
+	if len(failedGroups) > 0 {
+		message += "\n失败的群组：" + strings.Join(failedGroups, "; ")
+	}
+	if skippedCount > 0 {
+		message += fmt.Sprintf("\n操作已中断：剩余 %d 个群组未处理", skippedCount)
+	}
+	message = withWarnings(warnings, message)
+
+	return &models.GroupUpdateResponse{
+		Success:  successCount > 0,
+		Message:  message,
+		Affected: successCount,
+	}, nil
+}
+
+// checkNameAvailable 检查群名是否未被其他群组占用，selfID 为正在改名的群组
+func (s *GroupService) checkNameAvailable(ctx context.Context, name, selfID string) error {
+	check, err := s.CheckGroupExists(ctx, name, true)
+	if err != nil {
+		return fmt.Errorf("重复检查失败: %w", err)
+	}
+
+	var others []models.GroupMatch
+	for _, match := range check.Matches {
+		if match.ChatID != selfID {
+			others = append(others, match)
+		}
+	}
+	check.Matches = others
+
+	if text := describeMatches(check); text != "" {
+		return fmt.Errorf("群名已存在: %s", text)
+	}
+	return nil
+}
+
+// updateRequestFromCSV 将CSV行转换为修改请求
+func updateRequestFromCSV(groupID string, row models.CSVGroupUpdateData) (*models.GroupUpdateRequest, error) {
+	req := &models.GroupUpdateRequest{
+		GroupID:     groupID,
+		Name:        row.Name,
+		Description: row.Description,
+		OwnerID:     row.OwnerID,
+	}
+
+	var err error
+	settings := []struct {
+		dst   **int
+		value string
+		parse func(string) (*int, error)
+	}{
+		{&req.Settings.ShowHistoryType, row.ShowHistoryType, ParseSwitch},
+		{&req.Settings.ValidationType, row.ValidationType, ParseSwitch},
+		{&req.Settings.Searchable, row.Searchable, ParseSwitch},
+		{&req.Settings.MentionAllAuthority, row.MentionAllAuthority, ParseAuthority},
+		{&req.Settings.ManagementType, row.ManagementType, ParseAuthority},
+		{&req.Settings.ChatBannedType, row.ChatBannedType, ParseSwitch},
+	}
+	for _, setting := range settings {
+		if *setting.dst, err = setting.parse(setting.value); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// ParseSwitch 解析开关类设置（是/否），空字符串表示不修改
+func ParseSwitch(value string) (*int, error) {
+	var v int
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return nil, nil
+	case "是", "1", "true", "yes", "on":
+		v = 1
+	case "否", "0", "false", "no", "off":
+		v = 0
+	default:
+		return nil, fmt.Errorf("无效的开关值: %s (应为 是/否)", value)
+	}
+	return &v, nil
+}
+
+// ParseAuthority 解析权限类设置（所有人/仅群主），空字符串表示不修改
+func ParseAuthority(value string) (*int, error) {
+	var v int
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return nil, nil
+	case "所有人", "all", "0":
+		v = 0
+	case "仅群主", "群主", "owner", "1":
+		v = 1
+	default:
+		return nil, fmt.Errorf("无效的权限值: %s (应为 所有人/仅群主)", value)
+	}
+	return &v, nil
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+func TestUpdateGroup(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2", "u3")
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u1", "u2")
+	addLocalGroup(t, fake, store, "市场群", "u1", "u1")
+	ctx := context.Background()
+
+	// 新群主不在群中时先加入群
+	group, err := service.UpdateGroup(ctx, &models.GroupUpdateRequest{GroupID: chatID, Name: "研发一群", OwnerID: "u3", Description: "新的描述"})
+	if err != nil {
+		t.Fatalf("UpdateGroup: %v", err)
+	}
+	if group.Name != "研发一群" || group.OwnerID != "u3" || group.Description != "新的描述" || !group.IsMember("u3") {
+		t.Errorf("returned group = %+v", group)
+	}
+	chat, _ := fake.Chat(chatID)
+	if chat.Name != "研发一群" || chat.OwnerID != "u3" || strings.Join(sorted(chat.Members), ",") != "u1,u2,u3" {
+		t.Errorf("chat = %+v", chat)
+	}
+	stored, err := store.GetGroupByID(chatID)
+	if err != nil || stored.Name != "研发一群" || stored.OwnerID != "u3" || stored.MemberCount != 3 {
+		t.Errorf("stored = %+v, %v", stored, err)
+	}
+
+	tests := []struct {
+		name   string
+		req    models.GroupUpdateRequest
+		errSub string
+	}{
+		{"与当前相同", models.GroupUpdateRequest{GroupID: chatID, Name: "研发一群", OwnerID: "u3"}, "没有需要修改的内容"},
+		{"名称冲突", models.GroupUpdateRequest{GroupID: chatID, Name: "市场群"}, "群名已存在"},
+		{"群组不存在", models.GroupUpdateRequest{GroupID: "missing", Name: "新群"}, "不存在"},
+	}
+	for _, tt := range tests {
+		calls := len(fake.Calls())
+		_, err := service.UpdateGroup(ctx, &tt.req)
+		if err == nil || !strings.Contains(err.Error(), tt.errSub) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.errSub)
+		}
+		for _, call := range fake.Calls()[calls:] {
+			if call.Method == dingtalktest.MethodUpdateGroup {
+				t.Errorf("%s: chat/update called", tt.name)
+			}
+		}
+	}
+}
+
+func TestUpdateGroupsFromCSV(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	fake.SetDirectory("u1", "u2")
+	service, store := newTestService(t, fake)
+	byID := addLocalGroup(t, fake, store, "研发群", "u1", "u1", "u2")
+	byName := addLocalGroup(t, fake, store, "市场群", "u1", "u1", "u2")
+	banned := addLocalGroup(t, fake, store, "公告群", "u1", "u1")
+
+	file := writeFile(t, "update.csv", "群组,新群名称,群描述,新群主用户ID,全员禁言\n"+
+		byID+",研发一群,,,\n"+
+		"市场群,,,u2,\n"+
+		"公告群,,,,是\n"+
+		"不存在的群,新名称,,,\n"+
+		"研发一群,,,,也许\n")
+
+	resp, err := service.UpdateGroupsFromCSV(context.Background(), file)
+	if err != nil {
+		t.Fatalf("UpdateGroupsFromCSV: %v", err)
+	}
+	if resp.Affected != 3 || !resp.Success {
+		t.Errorf("resp = %+v", resp)
+	}
+	for _, sub := range []string{"成功修改 3 个群组", "失败 2 个群组", "不存在的群 (群组不存在", "无效的开关值: 也许"} {
+		if !strings.Contains(resp.Message, sub) {
+			t.Errorf("message %q missing %q", resp.Message, sub)
+		}
+	}
+
+	if chat, _ := fake.Chat(byID); chat.Name != "研发一群" {
+		t.Errorf("renamed chat = %+v", chat)
+	}
+	if chat, _ := fake.Chat(byName); chat.OwnerID != "u2" {
+		t.Errorf("owner not transferred: %+v", chat)
+	}
+	if chat, _ := fake.Chat(banned); chat.Settings.ChatBannedType != 1 {
+		t.Errorf("chat banned not set: %+v", chat.Settings)
+	}
+	if group, _ := store.GetGroupByID(banned); group.Settings == nil || group.Settings.ChatBannedType != 1 {
+		t.Errorf("stored settings = %+v", group.Settings)
+	}
+}
+
+func TestParseSettingValues(t *testing.T) {
+	tests := []struct {
+		parse func(string) (*int, error)
+		value string
+		want  int // -1 表示不修改，-2 表示无效
+	}{
+		{ParseSwitch, "是", 1},
+		{ParseSwitch, " ON ", 1},
+		{ParseSwitch, "否", 0},
+		{ParseSwitch, "", -1},
+		{ParseSwitch, "也许", -2},
+		{ParseAuthority, "仅群主", 1},
+		{ParseAuthority, "owner", 1},
+		{ParseAuthority, "所有人", 0},
+		{ParseAuthority, "", -1},
+		{ParseAuthority, "管理员", -2},
+	}
+	for _, tt := range tests {
+		got, err := tt.parse(tt.value)
+		switch {
+		case tt.want == -2:
+			if err == nil {
+				t.Errorf("%q: expected error", tt.value)
+			}
+		case tt.want == -1:
+			if got != nil || err != nil {
+				t.Errorf("%q = %v, %v, want nil", tt.value, got, err)
+			}
+		case err != nil || got == nil || *got != tt.want:
+			t.Errorf("%q = %v, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+}
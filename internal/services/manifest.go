@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// PlanManifest 对比清单与本地存储，生成使群组达到清单所描述状态的执行计划
+//
+// live 为 true 时已有群组的当前状态取自钉钉实时信息而不是本地记录。
+// 本地记录没有群设置时，清单中的群设置总是列入计划。群组类型无法修改，
+// 类型不一致时只给出警告。清单中不存在的本地群组保持不变。
+func (s *GroupService) PlanManifest(ctx context.Context, manifest *models.Manifest, source string, live bool) (*models.Plan, error) {
+	groups, err := s.storage.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("加载群组列表失败: %w", err)
+	}
+
+	byID := make(map[string]models.Group)
+	byName := make(map[string]models.Group)
+	for _, group := range groups {
+		if group.Status == "deleted" {
+			continue
+		}
+		byID[group.ID] = group
+		if _, ok := byName[group.Name]; !ok {
+			byName[group.Name] = group
+		}
+	}
+
+	plan := &models.Plan{Manifest: source, Live: live, CreatedAt: time.Now()}
+	names := make(map[string]bool)
+	for i, mg := range manifest.Groups {
+		if mg.Name == "" {
+			return nil, fmt.Errorf("清单第%d个群组缺少群名称", i+1)
+		}
+		if names[mg.Name] {
+			return nil, fmt.Errorf("清单中群名称重复: %s", mg.Name)
+		}
+		names[mg.Name] = true
+
+		settings, err := manifestSettings(mg.Settings)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", mg.Name, err)
+		}
+
+		current, ok := byName[mg.Name]
+		if mg.ID != "" {
+			if current, ok = byID[mg.ID]; !ok {
+				return nil, fmt.Errorf("%s: 本地存储中没有群组 %s", mg.Name, mg.ID)
+			}
+		}
+		if !ok {
+			if mg.Owner == "" {
+				return nil, fmt.Errorf("%s: 创建群组需要指定群主", mg.Name)
+			}
+			plan.Actions = append(plan.Actions, planCreate(mg, settings))
+			continue
+		}
+
+		baseline := current.UpdatedAt
+		if live {
+			info, err := s.dingtalkClient.GetGroup(ctx, current.ID)
+			if dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound) {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s (%s): 群组已在钉钉中解散，请先运行 sync", mg.Name, current.ID))
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", mg.Name, err)
+			}
+			applyChatInfo(&current, info)
+		}
+
+		if mg.Type != "" && ParseGroupType(mg.Type) != current.GroupType {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s (%s): 群组类型无法修改（当前为 %s）", mg.Name, current.ID, current.GroupType))
+		}
+
+		action := planUpdate(current, mg, settings)
+		if action == nil {
+			plan.Unchanged++
+			continue
+		}
+		action.Baseline = baseline
+		plan.Actions = append(plan.Actions, *action)
+	}
+
+	return plan, nil
+}
+
+// ApplyPlan 按计划逐个执行动作，单个动作失败不影响其他动作
+//
+// 已有群组的本地记录在生成计划后发生变化时，该动作不执行，需要重新生成计划。
+// 按实时信息生成的计划在执行时同样以钉钉中的当前状态判断需要修改的字段。
+// ctx 取消后不再执行剩余动作。
+func (s *GroupService) ApplyPlan(ctx context.Context, plan *models.Plan) (*models.ApplyResponse, error) {
+	resp := &models.ApplyResponse{}
+	for i, action := range plan.Actions {
+		if ctx.Err() != nil {
+			resp.Skipped = len(plan.Actions) - i
+			break
+		}
+
+		result := models.PlanResult{Action: action.Action, GroupID: action.GroupID, Name: action.Name}
+		var err error
+		switch action.Action {
+		case models.PlanCreate:
+			result.GroupID, err = s.applyCreate(ctx, action)
+		case models.PlanUpdate:
+			err = s.applyUpdate(ctx, action, plan.Live)
+		default:
+			err = fmt.Errorf("未知的动作: %s", action.Action)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	return resp, nil
+}
+
+// applyCreate 执行创建动作，返回新群组ID
+func (s *GroupService) applyCreate(ctx context.Context, action models.PlanAction) (string, error) {
+	if action.Create == nil {
+		return "", fmt.Errorf("计划缺少创建请求")
+	}
+
+	req := *action.Create
+	req.MemberIDs = append([]string(nil), req.MemberIDs...)
+	group, err := s.createGroup(ctx, &req)
+	if err != nil {
+		return "", err
+	}
+
+	if action.Update != nil && !action.Update.Settings.IsEmpty() {
+		update := &models.GroupUpdateRequest{GroupID: group.ID, Settings: action.Update.Settings}
+		if _, err := s.UpdateGroup(ctx, update); err != nil {
+			return group.ID, fmt.Errorf("已创建，但修改群设置失败: %s", errorText(err))
+		}
+	}
+	if action.SetTags {
+		if err := s.setTags(group.ID, action.Tags); err != nil {
+			return group.ID, fmt.Errorf("已创建，但%s", err.Error())
+		}
+	}
+
+	return group.ID, nil
+}
+
+// applyUpdate 执行修改动作：先修改名称、群主和群设置，再增减成员，最后设置标签
+//
+// live 为 true 时与生成计划时一样，先从钉钉读取群组当前状态，按其判断需要修改的字段，
+// 并用其刷新本地记录。
+func (s *GroupService) applyUpdate(ctx context.Context, action models.PlanAction, live bool) error {
+	group, err := s.storage.GetGroupByID(action.GroupID)
+	if err != nil {
+		return err
+	}
+	if !group.UpdatedAt.Equal(action.Baseline) {
+		return fmt.Errorf("本地记录在生成计划后已变化，请重新生成计划")
+	}
+
+	if action.Update != nil {
+		if live {
+			info, err := s.dingtalkClient.GetGroup(ctx, group.ID)
+			if err != nil {
+				return fmt.Errorf("获取群组信息失败: %s", errorText(err))
+			}
+			applyChatInfo(group, info)
+		}
+		if _, err := s.updateGroup(ctx, group, action.Update); err != nil {
+			return fmt.Errorf("修改群组失败: %s", errorText(err))
+		}
+	}
+
+	if len(action.AddMembers) > 0 {
+		if err := s.dingtalkClient.AddGroupMembers(ctx, action.GroupID, action.AddMembers); err != nil {
+			return fmt.Errorf("添加成员失败: %s", errorText(err))
+		}
+		if err := s.updateLocal(action.GroupID, func(group *models.Group) {
+			for _, userID := range action.AddMembers {
+				group.AddMember(userID)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(action.RemoveMembers) > 0 {
+		if err := s.dingtalkClient.RemoveGroupMembers(ctx, action.GroupID, action.RemoveMembers); err != nil {
+			return fmt.Errorf("移除成员失败: %s", errorText(err))
+		}
+		if err := s.updateLocal(action.GroupID, func(group *models.Group) {
+			for _, userID := range action.RemoveMembers {
+				group.RemoveMember(userID)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+
+	if action.SetTags {
+		return s.setTags(action.GroupID, action.Tags)
+	}
+	return nil
+}
+
+// setTags 设置群组标签（只保存在本地）
+func (s *GroupService) setTags(groupID string, tags []string) error {
+	return s.updateLocal(groupID, func(group *models.Group) {
+		group.Tags = append([]string(nil), tags...)
+	})
+}
+
+// updateLocal 读取本地记录，修改后保存
+func (s *GroupService) updateLocal(groupID string, fn func(group *models.Group)) error {
+	group, err := s.storage.GetGroupByID(groupID)
+	if err != nil {
+		return err
+	}
+	fn(group)
+	group.UpdatedAt = time.Now()
+	if err := s.storage.UpdateGroup(*group); err != nil {
+		return fmt.Errorf("更新群组信息失败: %w", err)
+	}
+	return nil
+}
+
+// planCreate 生成创建动作
+func planCreate(mg models.ManifestGroup, settings models.ChatSettingsUpdate) models.PlanAction {
+	groupType := ParseGroupType(mg.Type)
+	members := append([]string(nil), mg.Members...)
+	if !contains(members, mg.Owner) {
+		members = append(members, mg.Owner)
+	}
+
+	action := models.PlanAction{
+		Action: models.PlanCreate,
+		Name:   mg.Name,
+		Create: &models.GroupCreateRequest{
+			Name:        mg.Name,
+			Description: mg.Description,
+			OwnerID:     mg.Owner,
+			MemberIDs:   members,
+			GroupType:   groupType,
+			IsExternal:  groupType == "external",
+		},
+		Changes: []models.PlanChange{
+			{Field: "owner", To: mg.Owner},
+			{Field: "group_type", To: groupType},
+			{Field: "members", To: strings.Join(members, ",")},
+		},
+	}
+	if mg.Description != "" {
+		action.Changes = append(action.Changes, models.PlanChange{Field: "description", To: mg.Description})
+	}
+	if !settings.IsEmpty() {
+		action.Update = &models.GroupUpdateRequest{Settings: settings}
+		action.Changes = append(action.Changes, models.PlanChange{Field: "settings", To: settingsUpdateText(settings)})
+	}
+	if len(mg.Tags) > 0 {
+		action.SetTags = true
+		action.Tags = mg.Tags
+		action.Changes = append(action.Changes, models.PlanChange{Field: "tags", To: strings.Join(mg.Tags, ",")})
+	}
+	return action
+}
+
+// planUpdate 对比群组当前状态与清单，生成修改动作，没有差异时返回nil
+func planUpdate(current models.Group, mg models.ManifestGroup, settings models.ChatSettingsUpdate) *models.PlanAction {
+	action := &models.PlanAction{Action: models.PlanUpdate, GroupID: current.ID, Name: mg.Name}
+	update := &models.GroupUpdateRequest{GroupID: current.ID}
+
+	if mg.Name != current.Name {
+		update.Name = mg.Name
+		action.Changes = append(action.Changes, models.PlanChange{Field: "name", From: current.Name, To: mg.Name})
+	}
+	if mg.Description != "" && mg.Description != current.Description {
+		update.Description = mg.Description
+		action.Changes = append(action.Changes, models.PlanChange{Field: "description", From: current.Description, To: mg.Description})
+	}
+
+	owner := current.OwnerID
+	if mg.Owner != "" && mg.Owner != current.OwnerID {
+		owner = mg.Owner
+		update.OwnerID = mg.Owner
+		action.Changes = append(action.Changes, models.PlanChange{Field: "owner", From: current.OwnerID, To: mg.Owner})
+	}
+
+	update.Settings = changedSettings(current.Settings, settings)
+	if !update.Settings.IsEmpty() {
+		from := "未知"
+		if current.Settings != nil {
+			from = SettingsText(*current.Settings)
+		}
+		action.Changes = append(action.Changes, models.PlanChange{Field: "settings", From: from, To: settingsUpdateText(update.Settings)})
+	}
+
+	if update.Name != "" || update.Description != "" || update.OwnerID != "" || !update.Settings.IsEmpty() {
+		action.Update = update
+	}
+
+	if mg.Members != nil {
+		desired := append([]string(nil), mg.Members...)
+		if !contains(desired, owner) {
+			desired = append(desired, owner)
+		}
+		added, removed := MemberChanges(current.Members, desired)
+		for _, userID := range added {
+			// 新群主在修改群主时已加入
+			if userID != update.OwnerID {
+				action.AddMembers = append(action.AddMembers, userID)
+			}
+		}
+		action.RemoveMembers = removed
+		if len(added) > 0 || len(removed) > 0 {
+			action.Changes = append(action.Changes, models.PlanChange{
+				Field: "members",
+				From:  memberText(current.Members),
+				To:    memberText(desired),
+			})
+		}
+	}
+
+	if mg.Tags != nil && memberText(mg.Tags) != memberText(current.Tags) {
+		action.SetTags = true
+		action.Tags = mg.Tags
+		action.Changes = append(action.Changes, models.PlanChange{Field: "tags", From: strings.Join(current.Tags, ","), To: strings.Join(mg.Tags, ",")})
+	}
+
+	if len(action.Changes) == 0 {
+		return nil
+	}
+	return action
+}
+
+// manifestSettings 将清单中的群设置转换为修改请求
+func manifestSettings(ms *models.ManifestSettings) (models.ChatSettingsUpdate, error) {
+	var update models.ChatSettingsUpdate
+	if ms == nil {
+		return update, nil
+	}
+
+	flag := func(v *bool) *int {
+		if v == nil {
+			return nil
+		}
+		i := 0
+		if *v {
+			i = 1
+		}
+		return &i
+	}
+	update.ShowHistoryType = flag(ms.ShowHistory)
+	update.ValidationType = flag(ms.Validation)
+	update.Searchable = flag(ms.Searchable)
+	update.ChatBannedType = flag(ms.ChatBanned)
+
+	var err error
+	if update.MentionAllAuthority, err = ParseAuthority(ms.MentionAll); err != nil {
+		return update, fmt.Errorf("mention_all: %w", err)
+	}
+	if update.ManagementType, err = ParseAuthority(ms.Management); err != nil {
+		return update, fmt.Errorf("management: %w", err)
+	}
+	return update, nil
+}
+
+// changedSettings 返回与当前设置不同的项，当前设置未知时全部保留
+func changedSettings(current *models.ChatSettings, update models.ChatSettingsUpdate) models.ChatSettingsUpdate {
+	if current == nil {
+		return update
+	}
+	keep := func(v *int, currentValue int) *int {
+		if v != nil && *v != currentValue {
+			return v
+		}
+		return nil
+	}
+	return models.ChatSettingsUpdate{
+		ShowHistoryType:     keep(update.ShowHistoryType, current.ShowHistoryType),
+		ValidationType:      keep(update.ValidationType, current.ValidationType),
+		Searchable:          keep(update.Searchable, current.Searchable),
+		MentionAllAuthority: keep(update.MentionAllAuthority, current.MentionAllAuthority),
+		ManagementType:      keep(update.ManagementType, current.ManagementType),
+		ChatBannedType:      keep(update.ChatBannedType, current.ChatBannedType),
+	}
+}
+
+// settingsUpdateText 返回群设置修改的简要描述，只包含要修改的项
+func settingsUpdateText(update models.ChatSettingsUpdate) string {
+	var parts []string
+	add := func(label string, v *int, on, off string) {
+		if v == nil {
+			return
+		}
+		value := off
+		if *v == 1 {
+			value = on
+		}
+		parts = append(parts, label+"="+value)
+	}
+	add("查看历史消息", update.ShowHistoryType, "是", "否")
+	add("入群验证", update.ValidationType, "是", "否")
+	add("可搜索", update.Searchable, "是", "否")
+	add("@所有人", update.MentionAllAuthority, "仅群主", "所有人")
+	add("群管理", update.ManagementType, "仅群主", "所有人")
+	add("全员禁言", update.ChatBannedType, "是", "否")
+	return strings.Join(parts, " ")
+}
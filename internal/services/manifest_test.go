@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+func boolPtr(v bool) *bool { return &v }
+
+func intPtr(v int) *int { return &v }
+
+// applyManifest 生成并执行计划，任一动作失败时测试失败
+func applyManifest(t *testing.T, service *GroupService, manifest *models.Manifest, live bool) *models.Plan {
+	t.Helper()
+	ctx := context.Background()
+	plan, err := service.PlanManifest(ctx, manifest, "groups.yaml", live)
+	if err != nil {
+		t.Fatalf("PlanManifest: %v", err)
+	}
+	resp, err := service.ApplyPlan(ctx, plan)
+	if err != nil {
+		t.Fatalf("ApplyPlan: %v", err)
+	}
+	for _, result := range resp.Results {
+		if result.Error != "" {
+			t.Fatalf("%s %s: %s", result.Action, result.Name, result.Error)
+		}
+	}
+	return plan
+}
+
+// assertConverged 再次生成计划时不应有任何动作
+func assertConverged(t *testing.T, service *GroupService, manifest *models.Manifest, live bool) {
+	t.Helper()
+	plan, err := service.PlanManifest(context.Background(), manifest, "groups.yaml", live)
+	if err != nil {
+		t.Fatalf("PlanManifest: %v", err)
+	}
+	for _, action := range plan.Actions {
+		t.Errorf("plan not converged: %s %s %+v", action.Action, action.Name, action.Changes)
+	}
+}
+
+func TestManifestCreateWithSettingsConverges(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	manifest := &models.Manifest{Groups: []models.ManifestGroup{{
+		Name:     "研发群",
+		Owner:    "u1",
+		Members:  []string{"u2"},
+		Settings: &models.ManifestSettings{Searchable: boolPtr(true), Management: "owner"},
+		Tags:     []string{"研发"},
+	}}}
+
+	plan := applyManifest(t, service, manifest, false)
+	if len(plan.Actions) != 1 || plan.Actions[0].Action != models.PlanCreate {
+		t.Fatalf("first plan = %+v", plan.Actions)
+	}
+
+	group, err := store.GetGroupByName("研发群")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.Settings == nil || group.Settings.Searchable != 1 || group.Settings.ManagementType != 1 {
+		t.Fatalf("settings not recorded locally: %+v", group.Settings)
+	}
+	chat, _ := fake.Chat(group.ID)
+	if chat.Settings.Searchable != 1 || chat.Settings.ManagementType != 1 {
+		t.Fatalf("settings not applied in DingTalk: %+v", chat.Settings)
+	}
+
+	assertConverged(t, service, manifest, false)
+	assertConverged(t, service, manifest, true)
+}
+
+func TestManifestUpdateSettingsWithoutLocalSettingsConverges(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1")
+	manifest := &models.Manifest{Groups: []models.ManifestGroup{{
+		Name:     "研发群",
+		Settings: &models.ManifestSettings{ChatBanned: boolPtr(true)},
+	}}}
+
+	applyManifest(t, service, manifest, false)
+
+	group, _ := store.GetGroupByID(chatID)
+	if group.Settings == nil || group.Settings.ChatBannedType != 1 {
+		t.Fatalf("settings not recorded locally: %+v", group.Settings)
+	}
+	assertConverged(t, service, manifest, false)
+}
+
+func TestManifestLiveApplyUsesDingTalkState(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	chatID := addLocalGroup(t, fake, store, "研发群", "u1", "u2")
+
+	// 有人在钉钉客户端中改了群名和群主，本地记录尚未同步
+	ctx := context.Background()
+	if err := fake.UpdateGroup(ctx, &models.GroupUpdateRequest{GroupID: chatID, Name: "改名后的群", OwnerID: "u2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &models.Manifest{Groups: []models.ManifestGroup{{ID: chatID, Name: "研发群", Owner: "u1"}}}
+	plan := applyManifest(t, service, manifest, true)
+	if len(plan.Actions) != 1 || plan.Actions[0].Update == nil {
+		t.Fatalf("live plan = %+v", plan.Actions)
+	}
+
+	chat, _ := fake.Chat(chatID)
+	if chat.Name != "研发群" || chat.OwnerID != "u1" {
+		t.Fatalf("chat after apply = %+v", chat)
+	}
+	group, _ := store.GetGroupByID(chatID)
+	if group.Name != "研发群" || group.OwnerID != "u1" {
+		t.Fatalf("local record after apply = %+v", group)
+	}
+	assertConverged(t, service, manifest, true)
+}
+
+func TestManifestApplyRejectsStalePlan(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	addLocalGroup(t, fake, store, "研发群", "u1")
+	ctx := context.Background()
+
+	manifest := &models.Manifest{Groups: []models.ManifestGroup{{Name: "研发群", Tags: []string{"研发"}}}}
+	plan, err := service.PlanManifest(ctx, manifest, "groups.yaml", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 生成计划后本地记录发生变化
+	if err := service.setTags(plan.Actions[0].GroupID, []string{"其他"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := service.ApplyPlan(ctx, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Results[0].Error, "重新生成计划") {
+		t.Fatalf("result = %+v", resp.Results[0])
+	}
+}
+
+func TestPlanUpdateDiff(t *testing.T) {
+	current := models.Group{
+		ID:       "chat1",
+		Name:     "研发群",
+		OwnerID:  "u1",
+		Members:  []string{"u1", "u2", "u3"},
+		Tags:     []string{"b", "a"},
+		Settings: &models.ChatSettings{Searchable: 1},
+	}
+
+	// 与当前状态一致（标签顺序不同）时没有动作
+	same := models.ManifestGroup{Name: "研发群", Owner: "u1", Members: []string{"u2", "u3"}, Tags: []string{"a", "b"}}
+	if action := planUpdate(current, same, models.ChatSettingsUpdate{Searchable: intPtr(1)}); action != nil {
+		t.Fatalf("expected no action, got %+v", action)
+	}
+
+	mg := models.ManifestGroup{Name: "研发群", Owner: "u4", Members: []string{"u2"}}
+	action := planUpdate(current, mg, models.ChatSettingsUpdate{Searchable: intPtr(1), ChatBannedType: intPtr(1)})
+	if action == nil || action.Update == nil {
+		t.Fatalf("expected update action, got %+v", action)
+	}
+	if action.Update.OwnerID != "u4" || action.Update.Name != "" {
+		t.Errorf("update = %+v", action.Update)
+	}
+	if action.Update.Settings.Searchable != nil || action.Update.Settings.ChatBannedType == nil {
+		t.Errorf("settings update = %+v, want only chat_banned", action.Update.Settings)
+	}
+	// 新群主在修改群主时加入，不重复添加
+	if len(action.AddMembers) != 0 {
+		t.Errorf("add members = %v", action.AddMembers)
+	}
+	if got := strings.Join(sorted(action.RemoveMembers), ","); got != "u1,u3" {
+		t.Errorf("remove members = %s", got)
+	}
+	if action.SetTags {
+		t.Error("tags not in manifest should not be managed")
+	}
+}
+
+func TestChangedSettings(t *testing.T) {
+	update := models.ChatSettingsUpdate{Searchable: intPtr(1), ValidationType: intPtr(0)}
+
+	all := changedSettings(nil, update)
+	if all.Searchable == nil || all.ValidationType == nil {
+		t.Fatalf("unknown current settings should keep all items: %+v", all)
+	}
+
+	changed := changedSettings(&models.ChatSettings{Searchable: 1, ValidationType: 1}, update)
+	if changed.Searchable != nil || changed.ValidationType == nil || *changed.ValidationType != 0 {
+		t.Fatalf("changed = %+v", changed)
+	}
+}
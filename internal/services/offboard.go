@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// OffboardUser 将离职员工移出其所在的全部群组
+//
+// 默认从钉钉核实每个群组的实时成员（并刷新本地记录），localOnly 为 true 时只按
+// 本地记录判断。员工担任群主的群组先转移给继任群主再移出：继任群主依次取
+// successor 参数、配置的 offboard_successor、员工所在部门（或上级部门）的主管。
+// 无法完成的群组在结果中标明需要人工处理。ctx 取消后不再处理剩余群组。
+func (s *GroupService) OffboardUser(ctx context.Context, userID, successor string, localOnly bool) (*models.OffboardResponse, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("用户ID不能为空")
+	}
+
+	groups, err := s.storage.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("加载群组列表失败: %w", err)
+	}
+
+	resp := &models.OffboardResponse{UserID: userID}
+	var successorErr error
+	successorResolved := false
+
+	for i, group := range groups {
+		if ctx.Err() != nil {
+			resp.Skipped = countActiveGroups(groups[i:])
+			break
+		}
+
+		if group.Status == "deleted" {
+			continue
+		}
+		resp.Checked++
+
+		result := models.OffboardGroupResult{GroupID: group.ID, Name: group.Name}
+
+		if !localOnly {
+			info, err := s.dingtalkClient.GetGroup(ctx, group.ID)
+			if dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound) {
+				if err := s.storage.DeleteGroup(group.ID); err != nil {
+					result.FollowUp = fmt.Sprintf("群组已在钉钉中解散，但删除本地记录失败: %s", err.Error())
+					resp.Groups = append(resp.Groups, result)
+				}
+				continue
+			}
+			if err != nil {
+				if group.IsMember(userID) {
+					result.WasOwner = group.IsOwner(userID)
+					result.FollowUp = fmt.Sprintf("无法核实群成员: %s", errorText(err))
+					resp.Groups = append(resp.Groups, result)
+				}
+				continue
+			}
+			if len(diffGroup(&group, info)) > 0 {
+				applyChatInfo(&group, info)
+				if err := s.storage.UpdateGroup(group); err != nil {
+					result.FollowUp = fmt.Sprintf("更新本地记录失败: %s", err.Error())
+					resp.Groups = append(resp.Groups, result)
+					continue
+				}
+			}
+		}
+
+		if !group.IsMember(userID) && !group.IsOwner(userID) {
+			continue
+		}
+		result.WasOwner = group.IsOwner(userID)
+
+		if result.WasOwner {
+			if !successorResolved {
+				resp.Successor, resp.SuccessorSource, successorErr = s.resolveSuccessor(ctx, userID, successor)
+				successorResolved = true
+			}
+			if successorErr != nil {
+				result.FollowUp = fmt.Sprintf("该员工是群主，未能确定继任群主: %s", successorErr.Error())
+				resp.Groups = append(resp.Groups, result)
+				continue
+			}
+
+			if _, err := s.UpdateGroup(ctx, &models.GroupUpdateRequest{GroupID: group.ID, OwnerID: resp.Successor}); err != nil {
+				result.FollowUp = fmt.Sprintf("转移群主失败: %s", errorText(err))
+				resp.Groups = append(resp.Groups, result)
+				continue
+			}
+			result.Actions = append(result.Actions, fmt.Sprintf("群主转移给 %s", resp.Successor))
+		}
+
+		if err := s.dingtalkClient.RemoveGroupMembers(ctx, group.ID, []string{userID}); err != nil {
+			result.FollowUp = fmt.Sprintf("移出群组失败: %s", errorText(err))
+			resp.Groups = append(resp.Groups, result)
+			continue
+		}
+
+		// 转移群主后本地记录已更新，重新读取再移除成员
+		if updated, err := s.storage.GetGroupByID(group.ID); err == nil {
+			group = *updated
+		}
+		group.RemoveMember(userID)
+		if err := s.storage.UpdateGroup(group); err != nil {
+			result.FollowUp = fmt.Sprintf("已在钉钉中移出，但更新本地记录失败: %s", err.Error())
+		}
+		result.Actions = append(result.Actions, "已移出群组")
+		resp.Groups = append(resp.Groups, result)
+	}
+
+	return resp, nil
+}
+
+// resolveSuccessor 确定继任群主，返回用户ID和确定方式
+func (s *GroupService) resolveSuccessor(ctx context.Context, userID, successor string) (string, string, error) {
+	source := "命令行参数"
+	if successor == "" && s.config != nil && s.config.OffboardSuccessor != "" {
+		successor, source = s.config.OffboardSuccessor, "配置文件"
+	}
+	if successor != "" {
+		if successor == userID {
+			return "", "", fmt.Errorf("继任群主不能是离职员工本人")
+		}
+		return successor, source, nil
+	}
+
+	if s.directory == nil {
+		return "", "", fmt.Errorf("未指定继任群主，且无法查询通讯录")
+	}
+
+	user, err := s.directory.GetUser(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("查询员工信息失败: %s", errorText(err))
+	}
+
+	// 依次查找员工所在部门及其上级部门的主管，跳过员工本人
+	for _, deptID := range user.DepartmentIDs {
+		for id := deptID; id != 0; {
+			dept, err := s.directory.GetDepartment(ctx, id)
+			if err != nil {
+				return "", "", fmt.Errorf("查询部门信息失败: %s", errorText(err))
+			}
+			for _, manager := range dept.Managers() {
+				if manager != userID {
+					return manager, fmt.Sprintf("部门主管 (%s)", dept.Name), nil
+				}
+			}
+			if dept.ParentID == id {
+				break
+			}
+			id = dept.ParentID
+		}
+	}
+
+	return "", "", fmt.Errorf("员工所在部门及上级部门均未设置其他主管，请使用 --successor 指定")
+}
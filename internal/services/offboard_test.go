@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+// offboardDepartments 总部 > 研发部 > 后端组，离职员工 leaver 是研发部主管
+var offboardDepartments = []models.Department{
+	{ID: 1, Name: "总部", ManagerUserIDs: "ceo"},
+	{ID: 2, Name: "研发部", ParentID: 1, ManagerUserIDs: "leaver"},
+	{ID: 3, Name: "后端组", ParentID: 2},
+}
+
+func TestOffboardUser(t *testing.T) {
+	directory := &testDirectory{
+		departments: offboardDepartments,
+		users:       []models.User{{UserID: "leaver", DepartmentIDs: []int64{3}}},
+	}
+	service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+	fake := directory.Fake
+	fake.SetDirectory("leaver", "u1", "u2", "ceo")
+
+	owned := addLocalGroup(t, fake, store, "研发群", "leaver", "leaver", "u1")
+	member := addLocalGroup(t, fake, store, "市场群", "u1", "u1", "leaver")
+	other := addLocalGroup(t, fake, store, "其他群", "u1", "u1", "u2")
+	dissolved := addLocalGroup(t, fake, store, "已解散", "u1", "u1", "leaver")
+	fake.RemoveChat(dissolved)
+
+	resp, err := service.OffboardUser(context.Background(), "leaver", "u2", false)
+	if err != nil {
+		t.Fatalf("OffboardUser: %v", err)
+	}
+	if resp.Successor != "u2" || resp.SuccessorSource != "命令行参数" || resp.Checked != 4 {
+		t.Errorf("resp = %+v", resp)
+	}
+	if len(resp.Groups) != 2 {
+		t.Fatalf("groups = %+v, want owned and member group", resp.Groups)
+	}
+	for _, result := range resp.Groups {
+		if result.FollowUp != "" {
+			t.Errorf("%s: follow-up %q", result.Name, result.FollowUp)
+		}
+		if result.WasOwner != (result.GroupID == owned) {
+			t.Errorf("%s: WasOwner = %v", result.Name, result.WasOwner)
+		}
+	}
+
+	chat, _ := fake.Chat(owned)
+	if chat.OwnerID != "u2" || strings.Join(sorted(chat.Members), ",") != "u1,u2" {
+		t.Errorf("owned chat = %+v", chat)
+	}
+	if chat, _ := fake.Chat(member); strings.Join(chat.Members, ",") != "u1" {
+		t.Errorf("member chat = %+v", chat)
+	}
+	if chat, _ := fake.Chat(other); len(chat.Members) != 2 {
+		t.Errorf("unrelated chat changed: %+v", chat)
+	}
+	for _, chatID := range []string{owned, member} {
+		if group, _ := store.GetGroupByID(chatID); group.IsMember("leaver") || group.OwnerID == "leaver" {
+			t.Errorf("local record still contains leaver: %+v", group)
+		}
+	}
+	groups, _ := store.LoadGroups()
+	for _, group := range groups {
+		if group.ID == dissolved && group.Status != "deleted" {
+			t.Errorf("dissolved group status = %s", group.Status)
+		}
+	}
+}
+
+func TestOffboardSuccessorChain(t *testing.T) {
+	tests := []struct {
+		name        string
+		departments []models.Department
+		successor   string // 命令行参数
+		configured  string // 配置的 offboard_successor
+		want        string
+		source      string
+	}{
+		{"命令行参数优先", offboardDepartments, "u2", "u3", "u2", "命令行参数"},
+		{"配置文件", offboardDepartments, "", "u3", "u3", "配置文件"},
+		{
+			"所在部门主管",
+			[]models.Department{{ID: 1, Name: "总部", ManagerUserIDs: "ceo"}, {ID: 3, Name: "后端组", ParentID: 1, ManagerUserIDs: "lead"}},
+			"", "", "lead", "部门主管 (后端组)",
+		},
+		{"跳过本人逐级向上", offboardDepartments, "", "", "ceo", "部门主管 (总部)"},
+		{
+			"同一部门的其他主管",
+			[]models.Department{{ID: 3, Name: "后端组", ManagerUserIDs: "leaver|lead"}},
+			"", "", "lead", "部门主管 (后端组)",
+		},
+	}
+	for _, tt := range tests {
+		directory := &testDirectory{
+			departments: tt.departments,
+			users:       []models.User{{UserID: "leaver", DepartmentIDs: []int64{3}}},
+		}
+		service, store := newDirectoryService(t, directory, &config.GroupConfig{OffboardSuccessor: tt.configured})
+		directory.SetDirectory("leaver", "u1", "u2", "u3", "lead", "ceo")
+		chatID := addLocalGroup(t, directory.Fake, store, "研发群", "leaver", "leaver", "u1")
+
+		resp, err := service.OffboardUser(context.Background(), "leaver", tt.successor, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if resp.Successor != tt.want || resp.SuccessorSource != tt.source {
+			t.Errorf("%s: successor = %s (%s), want %s (%s)", tt.name, resp.Successor, resp.SuccessorSource, tt.want, tt.source)
+		}
+		if chat, _ := directory.Chat(chatID); chat.OwnerID != tt.want {
+			t.Errorf("%s: chat owner = %s", tt.name, chat.OwnerID)
+		}
+	}
+}
+
+func TestOffboardWithoutSuccessor(t *testing.T) {
+	tests := []struct {
+		name      string
+		successor string
+		errSub    string
+	}{
+		{"部门没有其他主管", "", "均未设置其他主管"},
+		{"继任者是本人", "leaver", "不能是离职员工本人"},
+	}
+	for _, tt := range tests {
+		directory := &testDirectory{
+			departments: []models.Department{{ID: 1, Name: "总部", ManagerUserIDs: "leaver"}},
+			users:       []models.User{{UserID: "leaver", DepartmentIDs: []int64{1}}},
+		}
+		service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+		owned := addLocalGroup(t, directory.Fake, store, "研发群", "leaver", "leaver", "u1")
+		member := addLocalGroup(t, directory.Fake, store, "市场群", "u1", "u1", "leaver")
+
+		resp, err := service.OffboardUser(context.Background(), "leaver", tt.successor, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		// 担任群主的群组留待人工处理，其他群组照常移出
+		for _, result := range resp.Groups {
+			switch result.GroupID {
+			case owned:
+				if !strings.Contains(result.FollowUp, tt.errSub) || len(result.Actions) != 0 {
+					t.Errorf("%s: owned group result = %+v", tt.name, result)
+				}
+			case member:
+				if result.FollowUp != "" {
+					t.Errorf("%s: member group result = %+v", tt.name, result)
+				}
+			}
+		}
+		if chat, _ := directory.Chat(owned); chat.OwnerID != "leaver" || len(chat.Members) != 2 {
+			t.Errorf("%s: owned chat changed: %+v", tt.name, chat)
+		}
+	}
+}
+
+func TestOffboardLocalOnly(t *testing.T) {
+	directory := &testDirectory{}
+	service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+	chatID := addLocalGroup(t, directory.Fake, store, "市场群", "u1", "u1", "leaver")
+
+	resp, err := service.OffboardUser(context.Background(), "leaver", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Groups) != 1 || resp.Groups[0].FollowUp != "" {
+		t.Fatalf("groups = %+v", resp.Groups)
+	}
+	for _, call := range directory.Calls() {
+		if call.ChatID == chatID && call.Method != dingtalktest.MethodRemoveGroupMembers {
+			t.Errorf("local-only offboarding called %s", call.Method)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/models"
+)
+
+// OnboardUser 按配置的入职规则将新员工加入对应的群组
+//
+// 从通讯录查询员工的部门和职位，与 group.onboard_rules 逐条匹配，命中规则的
+// 群组合并去重后依次添加成员。演练模式下将要加入的群组标记为 OnboardPlanned。
+// ctx 取消后不再处理剩余群组。
+func (s *GroupService) OnboardUser(ctx context.Context, userID string) (*models.OnboardResponse, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("用户ID不能为空")
+	}
+	if s.directory == nil {
+		return nil, fmt.Errorf("无法查询通讯录")
+	}
+	if s.config == nil || len(s.config.OnboardRules) == 0 {
+		return nil, fmt.Errorf("未配置入职规则 (group.onboard_rules)")
+	}
+
+	user, err := s.directory.GetUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询员工信息失败: %w", err)
+	}
+
+	resp := &models.OnboardResponse{User: user, DryRun: s.dryRun}
+
+	// 员工所在部门及其全部上级部门，仅在规则包含下级部门时查询
+	var ancestors map[int64]bool
+
+	index := make(map[string]int)
+	for i, rule := range s.config.OnboardRules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("规则%d", i+1)
+		}
+
+		if rule.IncludeSubDepartments && len(rule.Departments) > 0 && ancestors == nil {
+			if ancestors, err = s.departmentAncestors(ctx, user.DepartmentIDs); err != nil {
+				return nil, err
+			}
+		}
+		if !matchRule(rule, user, ancestors) {
+			continue
+		}
+
+		for _, ref := range rule.Groups {
+			group, err := s.ResolveGroup(ref)
+			if err != nil {
+				resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %s", name, err.Error()))
+				continue
+			}
+			if i, ok := index[group.ID]; ok {
+				resp.Groups[i].Rules = append(resp.Groups[i].Rules, name)
+				continue
+			}
+			index[group.ID] = len(resp.Groups)
+			resp.Groups = append(resp.Groups, models.OnboardGroupResult{
+				GroupID: group.ID,
+				Name:    group.Name,
+				Rules:   []string{name},
+			})
+		}
+	}
+
+	for i := range resp.Groups {
+		if ctx.Err() != nil {
+			resp.Skipped = len(resp.Groups) - i
+			resp.Groups = resp.Groups[:i]
+			break
+		}
+
+		result := &resp.Groups[i]
+		group, err := s.storage.GetGroupByID(result.GroupID)
+		if err != nil {
+			result.Status, result.Message = models.OnboardFailed, err.Error()
+			continue
+		}
+		if group.IsMember(userID) {
+			result.Status = models.OnboardMember
+			continue
+		}
+		if err := s.dingtalkClient.AddGroupMembers(ctx, group.ID, []string{userID}); err != nil {
+			result.Status, result.Message = models.OnboardFailed, errorText(err)
+			continue
+		}
+		group.AddMember(userID)
+		if err := s.storage.UpdateGroup(*group); err != nil {
+			result.Status, result.Message = models.OnboardFailed, fmt.Sprintf("已在钉钉中加入，但更新本地记录失败: %s", err.Error())
+			continue
+		}
+		result.Status = models.OnboardAdded
+		if s.dryRun {
+			result.Status = models.OnboardPlanned
+		}
+	}
+
+	return resp, nil
+}
+
+// matchRule 判断员工是否满足规则的全部条件
+func matchRule(rule config.OnboardRule, user *models.User, ancestors map[int64]bool) bool {
+	if len(rule.Departments) > 0 {
+		matched := false
+		for _, deptID := range rule.Departments {
+			if rule.IncludeSubDepartments && ancestors[deptID] {
+				matched = true
+				break
+			}
+			for _, id := range user.DepartmentIDs {
+				if id == deptID {
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(rule.Positions) > 0 {
+		matched := false
+		for _, keyword := range rule.Positions {
+			if keyword != "" && strings.Contains(user.Position, keyword) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// departmentAncestors 返回部门及其全部上级部门的ID集合
+func (s *GroupService) departmentAncestors(ctx context.Context, deptIDs []int64) (map[int64]bool, error) {
+	ancestors := make(map[int64]bool)
+	for _, deptID := range deptIDs {
+		for id := deptID; id != 0 && !ancestors[id]; {
+			ancestors[id] = true
+			dept, err := s.directory.GetDepartment(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("查询部门信息失败: %w", err)
+			}
+			id = dept.ParentID
+		}
+	}
+	return ancestors, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/models"
+)
+
+// onboardDepartments 总部 > 研发部 > 后端组，总部 > 市场部
+var onboardDepartments = []models.Department{
+	{ID: 1, Name: "总部"},
+	{ID: 2, Name: "研发部", ParentID: 1},
+	{ID: 3, Name: "后端组", ParentID: 2},
+	{ID: 4, Name: "市场部", ParentID: 1},
+}
+
+func TestMatchRule(t *testing.T) {
+	backend := &models.User{UserID: "u1", Position: "高级后端工程师", DepartmentIDs: []int64{3}}
+	ancestors := map[int64]bool{1: true, 2: true, 3: true}
+
+	tests := []struct {
+		name string
+		rule config.OnboardRule
+		want bool
+	}{
+		{"没有条件", config.OnboardRule{}, true},
+		{"所在部门", config.OnboardRule{Departments: []int64{4, 3}}, true},
+		{"上级部门不含下级", config.OnboardRule{Departments: []int64{2}}, false},
+		{"上级部门含下级", config.OnboardRule{Departments: []int64{2}, IncludeSubDepartments: true}, true},
+		{"其他部门含下级", config.OnboardRule{Departments: []int64{4}, IncludeSubDepartments: true}, false},
+		{"职位关键字", config.OnboardRule{Positions: []string{"产品", "工程师"}}, true},
+		{"职位不匹配", config.OnboardRule{Positions: []string{"销售"}}, false},
+		{"空关键字不匹配", config.OnboardRule{Positions: []string{""}}, false},
+		{"部门和职位都满足", config.OnboardRule{Departments: []int64{3}, Positions: []string{"后端"}}, true},
+		{"部门满足职位不满足", config.OnboardRule{Departments: []int64{3}, Positions: []string{"前端"}}, false},
+	}
+	for _, tt := range tests {
+		if got := matchRule(tt.rule, backend, ancestors); got != tt.want {
+			t.Errorf("%s: matchRule = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOnboardUser(t *testing.T) {
+	directory := &testDirectory{
+		departments: onboardDepartments,
+		users:       []models.User{{UserID: "newbie", Position: "后端工程师", DepartmentIDs: []int64{3}}},
+	}
+	cfg := &config.GroupConfig{OnboardRules: []config.OnboardRule{
+		{Name: "全员", Groups: []string{"全员群"}},
+		{Name: "研发", Departments: []int64{2}, IncludeSubDepartments: true, Groups: []string{"研发群", "全员群"}},
+		{Name: "市场", Departments: []int64{4}, Groups: []string{"市场群"}},
+		{Departments: []int64{3}, Groups: []string{"后端群", "不存在的群"}},
+	}}
+	service, store := newDirectoryService(t, directory, cfg)
+	all := addLocalGroup(t, directory.Fake, store, "全员群", "admin", "admin")
+	dev := addLocalGroup(t, directory.Fake, store, "研发群", "admin", "admin")
+	addLocalGroup(t, directory.Fake, store, "市场群", "admin", "admin")
+	backend := addLocalGroup(t, directory.Fake, store, "后端群", "admin", "admin", "newbie")
+
+	resp, err := service.OnboardUser(context.Background(), "newbie")
+	if err != nil {
+		t.Fatalf("OnboardUser: %v", err)
+	}
+
+	want := []models.OnboardGroupResult{
+		{GroupID: all, Name: "全员群", Rules: []string{"全员", "研发"}, Status: models.OnboardAdded},
+		{GroupID: dev, Name: "研发群", Rules: []string{"研发"}, Status: models.OnboardAdded},
+		{GroupID: backend, Name: "后端群", Rules: []string{"规则4"}, Status: models.OnboardMember},
+	}
+	if !reflect.DeepEqual(resp.Groups, want) {
+		t.Errorf("groups = %+v\nwant %+v", resp.Groups, want)
+	}
+	if len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0], "规则4: 群组不存在: 不存在的群") {
+		t.Errorf("errors = %v", resp.Errors)
+	}
+
+	for _, chatID := range []string{all, dev} {
+		if chat, _ := directory.Chat(chatID); strings.Join(sorted(chat.Members), ",") != "admin,newbie" {
+			t.Errorf("%s members = %v", chat.Name, chat.Members)
+		}
+		if group, _ := store.GetGroupByID(chatID); !group.IsMember("newbie") {
+			t.Errorf("local %s members = %v", group.Name, group.Members)
+		}
+	}
+}
+
+func TestOnboardUserErrors(t *testing.T) {
+	rules := []config.OnboardRule{{Groups: []string{"全员群"}}}
+	tests := []struct {
+		name   string
+		userID string
+		rules  []config.OnboardRule
+		errSub string
+	}{
+		{"未配置规则", "newbie", nil, "未配置入职规则"},
+		{"员工不存在", "nobody", rules, "查询员工信息失败"},
+		{"用户ID为空", "", rules, "用户ID不能为空"},
+	}
+	for _, tt := range tests {
+		directory := &testDirectory{users: []models.User{{UserID: "newbie"}}}
+		service, _ := newDirectoryService(t, directory, &config.GroupConfig{OnboardRules: tt.rules})
+		if _, err := service.OnboardUser(context.Background(), tt.userID); err == nil || !strings.Contains(err.Error(), tt.errSub) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.errSub)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// LinkDepartments 将群组关联到部门，之后 ReconcileGroups 按部门成员同步群成员
+//
+// deptIDs 为空时取消关联。
+func (s *GroupService) LinkDepartments(ctx context.Context, groupID string, deptIDs []int64, recursive bool) (*models.Group, error) {
+	group, err := s.storage.GetGroupByID(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deptIDs) > 0 {
+		if s.directory == nil {
+			return nil, fmt.Errorf("无法查询通讯录")
+		}
+		for _, id := range deptIDs {
+			if _, err := s.directory.GetDepartment(ctx, id); err != nil {
+				return nil, fmt.Errorf("部门 %d: %w", id, err)
+			}
+		}
+	}
+
+	group.LinkedDepartments = deptIDs
+	group.LinkRecursive = recursive && len(deptIDs) > 0
+	if len(deptIDs) == 0 {
+		group.LinkedMembers = nil
+	}
+	group.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateGroup(*group); err != nil {
+		return nil, fmt.Errorf("更新群组信息失败: %w", err)
+	}
+	return group, nil
+}
+
+// ReconcileGroups 按关联部门的当前员工同步群成员
+//
+// 部门新员工加入群组；上次同步时来自部门、现已离开部门的成员移出群组。
+// 手动加入的成员和群主不会被移出。groupID 为空时处理全部关联部门的群组。
+// ctx 取消后不再处理剩余群组。
+func (s *GroupService) ReconcileGroups(ctx context.Context, groupID string) (*models.ReconcileResponse, error) {
+	if s.directory == nil {
+		return nil, fmt.Errorf("无法查询通讯录")
+	}
+
+	groups, err := s.storage.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("加载群组列表失败: %w", err)
+	}
+
+	var linked []models.Group
+	for _, group := range groups {
+		if group.Status == "deleted" || len(group.LinkedDepartments) == 0 {
+			continue
+		}
+		if groupID == "" || group.ID == groupID {
+			linked = append(linked, group)
+		}
+	}
+	if groupID != "" && len(linked) == 0 {
+		return nil, fmt.Errorf("群组不存在或未关联部门: %s", groupID)
+	}
+
+	r := &departmentResolver{directory: s.directory, users: make(map[int64][]string)}
+	resp := &models.ReconcileResponse{}
+	for i, group := range linked {
+		if ctx.Err() != nil {
+			resp.Skipped = len(linked) - i
+			break
+		}
+
+		result := models.ReconcileResult{GroupID: group.ID, Name: group.Name}
+		if err := s.reconcileGroup(ctx, r, group, &result); err != nil {
+			result.Error = err.Error()
+		}
+		resp.Results = append(resp.Results, result)
+	}
+
+	return resp, nil
+}
+
+// reconcileGroup 同步单个群组的成员，result 记录已完成的变更
+func (s *GroupService) reconcileGroup(ctx context.Context, r *departmentResolver, group models.Group, result *models.ReconcileResult) error {
+	desired, err := r.members(ctx, group.LinkedDepartments, group.LinkRecursive)
+	if err != nil {
+		return err
+	}
+
+	info, err := s.dingtalkClient.GetGroup(ctx, group.ID)
+	if dingtalk.HasErrcode(err, dingtalk.ErrcodeChatNotFound) {
+		if err := s.storage.DeleteGroup(group.ID); err != nil {
+			return err
+		}
+		return fmt.Errorf("群组已在钉钉中解散，已标记为删除")
+	}
+	if err != nil {
+		return fmt.Errorf("获取群成员失败: %s", errorText(err))
+	}
+	applyChatInfo(&group, info)
+
+	inGroup := make(map[string]bool, len(group.Members))
+	for _, member := range group.Members {
+		inGroup[member] = true
+	}
+	wasLinked := make(map[string]bool, len(group.LinkedMembers))
+	for _, member := range group.LinkedMembers {
+		wasLinked[member] = true
+	}
+
+	var toAdd, toRemove []string
+	for _, userID := range desired {
+		if !inGroup[userID] {
+			toAdd = append(toAdd, userID)
+		}
+	}
+	for _, member := range group.Members {
+		if wasLinked[member] && !contains(desired, member) && member != group.OwnerID {
+			toRemove = append(toRemove, member)
+		}
+	}
+
+	var opErr error
+	pending := toRemove
+	if len(toRemove) > 0 {
+		if err := s.dingtalkClient.RemoveGroupMembers(ctx, group.ID, toRemove); err != nil {
+			opErr = fmt.Errorf("移出成员失败: %s", errorText(err))
+		} else {
+			for _, userID := range toRemove {
+				group.RemoveMember(userID)
+			}
+			result.Removed = toRemove
+			pending = nil
+		}
+	}
+	if len(toAdd) > 0 && opErr == nil {
+		if err := s.dingtalkClient.AddGroupMembers(ctx, group.ID, toAdd); err != nil {
+			opErr = fmt.Errorf("添加成员失败: %s", errorText(err))
+		} else {
+			for _, userID := range toAdd {
+				group.AddMember(userID)
+			}
+			result.Added = toAdd
+		}
+	}
+
+	// 记录当前群中来自部门的成员；移出失败的成员保留，下次同步时重试
+	var linkedMembers []string
+	for _, member := range group.Members {
+		if contains(desired, member) || contains(pending, member) {
+			linkedMembers = append(linkedMembers, member)
+		}
+	}
+	group.LinkedMembers = linkedMembers
+	group.UpdatedAt = time.Now()
+
+	if err := s.storage.UpdateGroup(group); err != nil {
+		return fmt.Errorf("更新群组信息失败: %w", err)
+	}
+	return opErr
+}
+
+// departmentResolver 查询部门成员，同一次同步中缓存查询结果
+type departmentResolver struct {
+	directory   dingtalk.DirectoryAPI
+	departments []models.Department
+	users       map[int64][]string
+}
+
+// members 返回部门（recursive 时包含全部下级部门）的成员用户ID，已排序去重
+func (r *departmentResolver) members(ctx context.Context, deptIDs []int64, recursive bool) ([]string, error) {
+	ids := deptIDs
+	if recursive {
+		if r.departments == nil {
+			departments, err := r.directory.ListDepartments(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("获取部门列表失败: %s", errorText(err))
+			}
+			r.departments = departments
+		}
+		ids = descendants(r.departments, deptIDs)
+	}
+
+	seen := make(map[string]bool)
+	var members []string
+	for _, id := range ids {
+		users, ok := r.users[id]
+		if !ok {
+			list, err := r.directory.ListDepartmentUsers(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("获取部门 %d 成员失败: %s", id, errorText(err))
+			}
+			for _, user := range list {
+				users = append(users, user.UserID)
+			}
+			r.users[id] = users
+		}
+		for _, userID := range users {
+			if !seen[userID] {
+				seen[userID] = true
+				members = append(members, userID)
+			}
+		}
+	}
+
+	sort.Strings(members)
+	return members, nil
+}
+
+// descendants 返回部门及其全部下级部门的ID
+func descendants(all []models.Department, roots []int64) []int64 {
+	children := make(map[int64][]int64)
+	for _, dept := range all {
+		if dept.ID != dept.ParentID {
+			children[dept.ParentID] = append(children[dept.ParentID], dept.ID)
+		}
+	}
+
+	seen := make(map[int64]bool)
+	var result []int64
+	queue := append([]int64(nil), roots...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+		queue = append(queue, children[id]...)
+	}
+	return result
+}
+
+// contains 检查列表中是否包含该值
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+// reconcileDirectory 研发部(2) > 后端组(3)，市场部(4)
+func reconcileDirectory() *testDirectory {
+	return &testDirectory{
+		departments: []models.Department{
+			{ID: 1, Name: "总部"},
+			{ID: 2, Name: "研发部", ParentID: 1},
+			{ID: 3, Name: "后端组", ParentID: 2},
+			{ID: 4, Name: "市场部", ParentID: 1},
+		},
+		users: []models.User{
+			{UserID: "dev1", DepartmentIDs: []int64{2}},
+			{UserID: "dev2", DepartmentIDs: []int64{2}},
+			{UserID: "be1", DepartmentIDs: []int64{3}},
+			{UserID: "mkt1", DepartmentIDs: []int64{4}},
+		},
+	}
+}
+
+func TestReconcileGroups(t *testing.T) {
+	directory := reconcileDirectory()
+	service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+	ctx := context.Background()
+	chatID := addLocalGroup(t, directory.Fake, store, "研发群", "owner", "owner", "guest")
+
+	if _, err := service.LinkDepartments(ctx, chatID, []int64{2}, false); err != nil {
+		t.Fatalf("LinkDepartments: %v", err)
+	}
+
+	// 首次同步：加入部门员工，手动加入的成员保留
+	resp, err := service.ReconcileGroups(ctx, "")
+	if err != nil {
+		t.Fatalf("ReconcileGroups: %v", err)
+	}
+	want := []models.ReconcileResult{{GroupID: chatID, Name: "研发群", Added: []string{"dev1", "dev2"}}}
+	if !reflect.DeepEqual(resp.Results, want) {
+		t.Fatalf("results = %+v, want %+v", resp.Results, want)
+	}
+	if chat, _ := directory.Chat(chatID); strings.Join(sorted(chat.Members), ",") != "dev1,dev2,guest,owner" {
+		t.Errorf("members = %v", chat.Members)
+	}
+
+	// dev2 离开部门，be1 调入研发部
+	directory.users[1].DepartmentIDs = []int64{4}
+	directory.users[2].DepartmentIDs = []int64{2}
+
+	resp, err = service.ReconcileGroups(ctx, chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []models.ReconcileResult{{GroupID: chatID, Name: "研发群", Added: []string{"be1"}, Removed: []string{"dev2"}}}
+	if !reflect.DeepEqual(resp.Results, want) {
+		t.Fatalf("results = %+v, want %+v", resp.Results, want)
+	}
+	if chat, _ := directory.Chat(chatID); strings.Join(sorted(chat.Members), ",") != "be1,dev1,guest,owner" {
+		t.Errorf("members = %v", chat.Members)
+	}
+	group, _ := store.GetGroupByID(chatID)
+	if strings.Join(sorted(group.LinkedMembers), ",") != "be1,dev1" || group.MemberCount != 4 {
+		t.Errorf("stored group = %+v", group)
+	}
+
+	// 没有变化时不调用成员接口
+	calls := len(directory.Calls())
+	if resp, err = service.ReconcileGroups(ctx, ""); err != nil || resp.Results[0].Added != nil || resp.Results[0].Removed != nil {
+		t.Errorf("third run = %+v, %v", resp, err)
+	}
+	for _, call := range directory.Calls()[calls:] {
+		if call.Method != dingtalktest.MethodGetGroup {
+			t.Errorf("unchanged group called %s", call.Method)
+		}
+	}
+}
+
+func TestReconcileKeepsOwnerAndManualMembers(t *testing.T) {
+	directory := reconcileDirectory()
+	service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+	ctx := context.Background()
+	// 群主本身也是部门员工
+	chatID := addLocalGroup(t, directory.Fake, store, "研发群", "dev1", "dev1", "guest")
+	if _, err := service.LinkDepartments(ctx, chatID, []int64{2}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ReconcileGroups(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// 群主和 dev2 都离开部门：群主不移出，手动加入的 guest 不受影响
+	directory.users[0].DepartmentIDs = []int64{4}
+	directory.users[1].DepartmentIDs = []int64{4}
+	resp, err := service.ReconcileGroups(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Results[0]; !reflect.DeepEqual(got.Removed, []string{"dev2"}) || got.Error != "" {
+		t.Errorf("result = %+v", got)
+	}
+	if chat, _ := directory.Chat(chatID); strings.Join(sorted(chat.Members), ",") != "dev1,guest" {
+		t.Errorf("members = %v", chat.Members)
+	}
+}
+
+func TestReconcileRecursive(t *testing.T) {
+	directory := reconcileDirectory()
+	service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+	ctx := context.Background()
+	chatID := addLocalGroup(t, directory.Fake, store, "研发群", "owner", "owner")
+	if _, err := service.LinkDepartments(ctx, chatID, []int64{2}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := service.ReconcileGroups(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Results[0].Added; !reflect.DeepEqual(got, []string{"be1", "dev1", "dev2"}) {
+		t.Errorf("added = %v, want members of sub-departments too", got)
+	}
+}
+
+func TestReconcileRemoveFailureRetried(t *testing.T) {
+	directory := reconcileDirectory()
+	service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+	ctx := context.Background()
+	chatID := addLocalGroup(t, directory.Fake, store, "研发群", "owner", "owner")
+	if _, err := service.LinkDepartments(ctx, chatID, []int64{2}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.ReconcileGroups(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	directory.users[1].DepartmentIDs = []int64{4}
+	directory.FailNext(dingtalktest.MethodRemoveGroupMembers, dingtalktest.NewAPIError(dingtalktest.MethodRemoveGroupMembers, 60011, "权限不足"))
+	resp, err := service.ReconcileGroups(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Results[0].Error, "移出成员失败") {
+		t.Fatalf("result = %+v", resp.Results[0])
+	}
+
+	// 移出失败的成员仍记为来自部门，下次同步时重试
+	resp, err = service.ReconcileGroups(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Results[0]; !reflect.DeepEqual(got.Removed, []string{"dev2"}) || got.Error != "" {
+		t.Errorf("retry result = %+v", got)
+	}
+}
+
+func TestLinkDepartments(t *testing.T) {
+	directory := reconcileDirectory()
+	service, store := newDirectoryService(t, directory, &config.GroupConfig{})
+	ctx := context.Background()
+	chatID := addLocalGroup(t, directory.Fake, store, "研发群", "owner", "owner")
+
+	if _, err := service.LinkDepartments(ctx, chatID, []int64{99}, false); err == nil || !strings.Contains(err.Error(), "部门 99") {
+		t.Errorf("unknown department: err = %v", err)
+	}
+	if _, err := service.ReconcileGroups(ctx, chatID); err == nil || !strings.Contains(err.Error(), "未关联部门") {
+		t.Errorf("unlinked group: err = %v", err)
+	}
+
+	group, err := service.LinkDepartments(ctx, chatID, []int64{2, 4}, true)
+	if err != nil || !reflect.DeepEqual(group.LinkedDepartments, []int64{2, 4}) || !group.LinkRecursive {
+		t.Fatalf("link = %+v, %v", group, err)
+	}
+
+	// 取消关联
+	group, err = service.LinkDepartments(ctx, chatID, nil, true)
+	if err != nil || group.LinkedDepartments != nil || group.LinkRecursive || group.LinkedMembers != nil {
+		t.Errorf("unlink = %+v, %v", group, err)
+	}
+}
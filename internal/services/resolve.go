@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+	"ti-dding/internal/storage"
+)
+
+// 成员标识的前缀，没有前缀时按用户ID处理
+const (
+	identifierUserID = "userid"
+	identifierMobile = "mobile"
+	identifierEmail  = "email"
+	identifierName   = "name"
+)
+
+// unresolvedError 成员标识无法对应到唯一的员工：找不到或对应多名员工
+type unresolvedError struct {
+	message string
+}
+
+func (e *unresolvedError) Error() string {
+	return e.message
+}
+
+// userDirectory 解析成员标识时使用的企业通讯录，同一次运行中只加载一次
+type userDirectory struct {
+	mu     sync.Mutex
+	users  []models.User
+	loaded bool // users 已从缓存或钉钉加载
+	fresh  bool // users 是本次运行从钉钉获取的
+}
+
+// SetDirectoryCache 设置本地通讯录缓存，按手机号查询的接口不可用时在缓存中查找
+//
+// 缓存在 group.directory_cache_ttl 内有效，过期或找不到要查找的员工时重新从钉钉获取
+// 整个通讯录并写回缓存（演练模式下不写回）。按邮箱、姓名查找时无法从缓存判断是否
+// 唯一，总是使用本次运行从钉钉获取的通讯录，获取后同样写回缓存。
+func (s *GroupService) SetDirectoryCache(cache *storage.DirectoryCache) {
+	s.directoryCache = cache
+}
+
+// ResolveUserID 将成员标识解析为用户ID
+//
+// 支持的标识：用户ID（或 userid:用户ID）、mobile:手机号、email:邮箱、name:姓名。
+// 手机号通过钉钉接口查询，邮箱和姓名在通讯录中查找；找不到或对应多名员工时
+// 返回错误，不做猜测。
+func (s *GroupService) ResolveUserID(ctx context.Context, identifier string) (string, error) {
+	kind, value := splitIdentifier(identifier)
+	if value == "" {
+		return "", &unresolvedError{fmt.Sprintf("成员标识 %s 缺少内容", identifier)}
+	}
+
+	switch kind {
+	case identifierMobile:
+		return s.resolveMobile(ctx, value)
+	case identifierEmail:
+		return s.findUser(ctx, "邮箱", value, true, func(user models.User) bool {
+			return strings.EqualFold(user.Email, value)
+		})
+	case identifierName:
+		return s.findUser(ctx, "姓名", value, true, func(user models.User) bool {
+			return user.Name == value
+		})
+	}
+	return value, nil
+}
+
+// resolveUserIDs 解析一组成员标识，忽略空项，结果去重并保持顺序；所有无法解析的标识合并在一个错误中返回
+func (s *GroupService) resolveUserIDs(ctx context.Context, identifiers []string) ([]string, error) {
+	var userIDs, problems []string
+	for _, identifier := range identifiers {
+		if strings.TrimSpace(identifier) == "" {
+			continue
+		}
+		userID, err := s.ResolveUserID(ctx, identifier)
+		if err != nil {
+			if !isUnresolved(err) {
+				return nil, err
+			}
+			problems = append(problems, err.Error())
+			continue
+		}
+		if !contains(userIDs, userID) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &unresolvedError{strings.Join(problems, "; ")}
+	}
+	return userIDs, nil
+}
+
+// isUnresolved 错误是否为成员标识无法解析（而不是接口调用失败）
+func isUnresolved(err error) bool {
+	var unresolved *unresolvedError
+	return errors.As(err, &unresolved)
+}
+
+// resolveRequestUsers 解析创建群组请求中的群主和成员标识
+func (s *GroupService) resolveRequestUsers(ctx context.Context, req *models.GroupCreateRequest) error {
+	ownerID, err := s.ResolveUserID(ctx, req.OwnerID)
+	if err != nil {
+		return fmt.Errorf("解析群主失败: %w", err)
+	}
+	memberIDs, err := s.resolveUserIDs(ctx, req.MemberIDs)
+	if err != nil {
+		return fmt.Errorf("解析成员失败: %w", err)
+	}
+	req.OwnerID, req.MemberIDs = ownerID, memberIDs
+	return nil
+}
+
+// splitIdentifier 拆分成员标识的前缀和内容，没有可识别的前缀时整体作为用户ID
+func splitIdentifier(identifier string) (kind, value string) {
+	identifier = strings.TrimSpace(identifier)
+	if i := strings.Index(identifier, ":"); i > 0 {
+		switch prefix := strings.ToLower(identifier[:i]); prefix {
+		case identifierUserID, identifierMobile, identifierEmail, identifierName:
+			return prefix, strings.TrimSpace(identifier[i+1:])
+		}
+	}
+	return identifierUserID, identifier
+}
+
+// resolveMobile 通过钉钉接口按手机号查询用户ID，接口不可用（如没有权限）时在通讯录中查找
+func (s *GroupService) resolveMobile(ctx context.Context, mobile string) (string, error) {
+	mobile = strings.NewReplacer(" ", "", "-", "").Replace(mobile)
+	mobile = strings.TrimPrefix(mobile, "+86")
+	if mobile == "" {
+		return "", &unresolvedError{"手机号不能为空"}
+	}
+	if s.directory == nil {
+		return "", fmt.Errorf("无法查询通讯录")
+	}
+
+	userID, err := s.directory.GetUserIDByMobile(ctx, mobile)
+	if err == nil && userID != "" {
+		return userID, nil
+	}
+	if err == nil || dingtalk.HasErrcode(err, dingtalk.ErrcodeUserNotFound) {
+		return "", &unresolvedError{fmt.Sprintf("手机号 %s 不属于企业成员", mobile)}
+	}
+	if ctx.Err() != nil {
+		return "", err
+	}
+
+	userID, findErr := s.findUser(ctx, "手机号", mobile, false, func(user models.User) bool {
+		return user.Mobile == mobile
+	})
+	if findErr != nil {
+		return "", wrapError(fmt.Sprintf("根据手机号 %s 查询用户失败: ", mobile), err)
+	}
+	return userID, nil
+}
+
+// findUser 在通讯录中查找唯一满足 match 的员工
+//
+// fresh 为 true 时只按本次运行从钉钉获取的通讯录判断：缓存中只有一人匹配，不代表现在
+// 仍然唯一（如之后入职了同名员工）。fresh 为 false 时优先使用缓存，缓存中找不到时
+// 重新从钉钉获取一次通讯录。
+func (s *GroupService) findUser(ctx context.Context, field, value string, fresh bool, match func(user models.User) bool) (string, error) {
+	s.users.mu.Lock()
+	defer s.users.mu.Unlock()
+
+	if err := s.loadDirectory(ctx, fresh && !s.users.fresh); err != nil {
+		return "", err
+	}
+	matches := filterUsers(s.users.users, match)
+	if len(matches) == 0 && !s.users.fresh {
+		if err := s.loadDirectory(ctx, true); err != nil {
+			return "", err
+		}
+		matches = filterUsers(s.users.users, match)
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", &unresolvedError{fmt.Sprintf("通讯录中找不到%s为 %s 的员工", field, value)}
+	case 1:
+		return matches[0].UserID, nil
+	}
+
+	var candidates []string
+	for _, user := range matches {
+		candidate := user.UserID
+		if user.Position != "" {
+			candidate += " (" + user.Position + ")"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return "", &unresolvedError{fmt.Sprintf("%s %s 对应多名员工: %s，请改用用户ID或 mobile: 指定", field, value, strings.Join(candidates, ", "))}
+}
+
+// loadDirectory 加载通讯录，refresh 为 false 时优先使用已加载的数据和未过期的缓存。调用方需持有 s.users.mu
+func (s *GroupService) loadDirectory(ctx context.Context, refresh bool) error {
+	if !refresh && s.users.loaded {
+		return nil
+	}
+
+	if !refresh && s.directoryCache != nil && s.config != nil && s.config.DirectoryCacheTTL > 0 {
+		users, updatedAt, err := s.directoryCache.Load()
+		if err == nil && users != nil && time.Since(updatedAt) < s.config.DirectoryCacheTTL {
+			s.users.users, s.users.loaded = users, true
+			return nil
+		}
+	}
+
+	if s.directory == nil {
+		return fmt.Errorf("无法查询通讯录")
+	}
+	departments, err := s.directory.ListDepartments(ctx)
+	if err != nil {
+		return wrapError("获取部门列表失败: ", err)
+	}
+
+	seen := make(map[string]bool)
+	var users []models.User
+	for _, dept := range departments {
+		list, err := s.directory.ListDepartmentUserDetails(ctx, dept.ID)
+		if err != nil {
+			return wrapError(fmt.Sprintf("获取部门 %s 成员失败: ", dept.Name), err)
+		}
+		for _, user := range list {
+			if !seen[user.UserID] {
+				seen[user.UserID] = true
+				users = append(users, user)
+			}
+		}
+	}
+
+	s.users.users, s.users.loaded, s.users.fresh = users, true, true
+	if s.directoryCache != nil && !s.dryRun {
+		// 缓存写入失败只影响下次运行的速度，不影响本次解析
+		s.directoryCache.Save(users)
+	}
+	return nil
+}
+
+// filterUsers 返回满足 match 的员工
+func filterUsers(users []models.User, match func(user models.User) bool) []models.User {
+	var matches []models.User
+	for _, user := range users {
+		if match(user) {
+			matches = append(matches, user)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+	"ti-dding/internal/storage"
+)
+
+// newResolveService 创建使用 users 作为企业通讯录的群组服务
+func newResolveService(t *testing.T, users ...models.User) (*GroupService, *testDirectory) {
+	t.Helper()
+	directory := &testDirectory{users: users}
+	service, _ := newDirectoryService(t, directory, &config.GroupConfig{DirectoryCacheTTL: 24 * time.Hour})
+	return service, directory
+}
+
+var testUsers = []models.User{
+	{UserID: "zhangsan", Name: "张三", Mobile: "13800000001", Email: "zhangsan@example.com", Position: "工程师"},
+	{UserID: "lisi", Name: "李四", Mobile: "13800000002", Email: "LiSi@example.com"},
+}
+
+func TestSplitIdentifier(t *testing.T) {
+	tests := []struct {
+		identifier, kind, value string
+	}{
+		{"user123", identifierUserID, "user123"},
+		{" userid:user123 ", identifierUserID, "user123"},
+		{"Mobile: 13800000001", identifierMobile, "13800000001"},
+		{"email:a@b.com", identifierEmail, "a@b.com"},
+		{"name:张三", identifierName, "张三"},
+		{"dept:user123", identifierUserID, "dept:user123"},
+		{":user123", identifierUserID, ":user123"},
+	}
+	for _, tt := range tests {
+		kind, value := splitIdentifier(tt.identifier)
+		if kind != tt.kind || value != tt.value {
+			t.Errorf("splitIdentifier(%q) = %q, %q, want %q, %q", tt.identifier, kind, value, tt.kind, tt.value)
+		}
+	}
+}
+
+func TestResolveUserID(t *testing.T) {
+	service, _ := newResolveService(t, testUsers...)
+	ctx := context.Background()
+
+	tests := []struct {
+		identifier, want string
+	}{
+		{"raw-id", "raw-id"},
+		{"userid:raw-id", "raw-id"},
+		{"mobile:+86 138-0000-0001", "zhangsan"},
+		{"email:lisi@EXAMPLE.com", "lisi"},
+		{"name:李四", "lisi"},
+	}
+	for _, tt := range tests {
+		got, err := service.ResolveUserID(ctx, tt.identifier)
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveUserID(%q) = %q, %v, want %q", tt.identifier, got, err, tt.want)
+		}
+	}
+
+	for _, identifier := range []string{"name:王五", "email:nobody@example.com", "mobile:13900000000", "name:", "mobile:-"} {
+		if _, err := service.ResolveUserID(ctx, identifier); !isUnresolved(err) {
+			t.Errorf("ResolveUserID(%q) error = %v, want unresolved", identifier, err)
+		}
+	}
+}
+
+func TestResolveUserIDAmbiguousName(t *testing.T) {
+	users := append(append([]models.User(nil), testUsers...), models.User{UserID: "zhangsan2", Name: "张三", Position: "产品经理"})
+	service, _ := newResolveService(t, users...)
+
+	_, err := service.ResolveUserID(context.Background(), "name:张三")
+	if !isUnresolved(err) {
+		t.Fatalf("err = %v, want unresolved", err)
+	}
+	for _, candidate := range []string{"zhangsan (工程师)", "zhangsan2 (产品经理)"} {
+		if !strings.Contains(err.Error(), candidate) {
+			t.Errorf("error %q does not list %s", err, candidate)
+		}
+	}
+}
+
+func TestResolveNameIgnoresStaleCache(t *testing.T) {
+	// 缓存中只有一个张三，之后又入职了一个
+	cache := storage.NewDirectoryCache(filepath.Join(t.TempDir(), "directory.json"))
+	if err := cache.Save(testUsers); err != nil {
+		t.Fatal(err)
+	}
+	users := append(append([]models.User(nil), testUsers...), models.User{UserID: "zhangsan2", Name: "张三"})
+	service, directory := newResolveService(t, users...)
+	service.SetDirectoryCache(cache)
+	ctx := context.Background()
+
+	if _, err := service.ResolveUserID(ctx, "name:张三"); !isUnresolved(err) {
+		t.Fatalf("err = %v, want ambiguous name error", err)
+	}
+	if _, err := service.ResolveUserID(ctx, "email:lisi@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if directory.listCalls != 1 {
+		t.Errorf("directory fetched %d times in one run, want 1", directory.listCalls)
+	}
+
+	// 获取的通讯录写回缓存
+	cached, _, err := cache.Load()
+	if err != nil || len(cached) != len(users) {
+		t.Fatalf("cache has %d users, %v", len(cached), err)
+	}
+}
+
+func TestResolveMobileFallsBackToCache(t *testing.T) {
+	cache := storage.NewDirectoryCache(filepath.Join(t.TempDir(), "directory.json"))
+	if err := cache.Save(testUsers); err != nil {
+		t.Fatal(err)
+	}
+	service, directory := newResolveService(t, testUsers...)
+	directory.mobileErr = dingtalktest.NewAPIError(dingtalktest.MethodGetGroup, dingtalk.ErrcodeNoPermission, "权限不足")
+	service.SetDirectoryCache(cache)
+
+	got, err := service.ResolveUserID(context.Background(), "mobile:13800000002")
+	if err != nil || got != "lisi" {
+		t.Fatalf("ResolveUserID = %q, %v", got, err)
+	}
+	if directory.listCalls != 0 {
+		t.Errorf("directory fetched %d times, want cache hit", directory.listCalls)
+	}
+}
+
+func TestResolveUserIDs(t *testing.T) {
+	service, _ := newResolveService(t, testUsers...)
+	ctx := context.Background()
+
+	got, err := service.resolveUserIDs(ctx, []string{"zhangsan", "name:张三", " ", "email:lisi@example.com"})
+	if err != nil || strings.Join(got, ",") != "zhangsan,lisi" {
+		t.Fatalf("resolveUserIDs = %v, %v", got, err)
+	}
+
+	_, err = service.resolveUserIDs(ctx, []string{"name:王五", "lisi", "email:nobody@example.com"})
+	if !isUnresolved(err) || !strings.Contains(err.Error(), "王五") || !strings.Contains(err.Error(), "nobody@example.com") {
+		t.Fatalf("err = %v, want both problems reported", err)
+	}
+}
+
+func TestAddMembersByName(t *testing.T) {
+	service, directory := newResolveService(t, testUsers...)
+	store := service.storage.(*storage.FileStorage)
+	chatID := addLocalGroup(t, directory.Fake, store, "研发群", "zhangsan")
+
+	resp, err := service.AddMembers(context.Background(), &models.GroupMemberRequest{GroupID: chatID, UserIDs: []string{"name:李四"}})
+	if err != nil || !resp.Success {
+		t.Fatalf("AddMembers: %+v, %v", resp, err)
+	}
+	chat, _ := directory.Chat(chatID)
+	if !contains(chat.Members, "lisi") {
+		t.Fatalf("members = %v", chat.Members)
+	}
+
+	if _, err := service.AddMembers(context.Background(), &models.GroupMemberRequest{GroupID: chatID, UserIDs: []string{"name:王五"}}); !isUnresolved(err) {
+		t.Fatalf("err = %v, want unresolved", err)
+	}
+}
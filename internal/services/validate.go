@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"ti-dding/internal/dingtalk"
+	"ti-dding/internal/models"
+)
+
+// 钉钉创建群组接口的限制
+const (
+	maxGroupNameLength   = 20 // 群名称最多20个字符
+	maxCreateMemberCount = 40 // 创建群组时最多指定40名成员（含群主）
+)
+
+// ValidateGroupsCSV 在创建群组前检查导入文件，不调用任何修改类接口
+//
+// 检查各行的必填字段、群名称长度、群组类型、文件内和本地存储中的重名，以及成员数量；
+// checkDirectory 为 true 时还解析 mobile:、email:、name: 形式的成员标识，并在企业
+// 通讯录中核实群主和成员：内部群只能包含通讯录中的用户，外部群中不在通讯录的用户
+// 视为外部联系人，只给出警告。文件格式错误（如缺少必需的列）直接返回错误。
+func (s *GroupService) ValidateGroupsCSV(ctx context.Context, csvFile string, checkDirectory bool) (*models.ValidationResponse, error) {
+	csvGroups, warnings, err := s.storage.LoadGroupsFromCSV(csvFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载群组文件失败: %w", err)
+	}
+	if checkDirectory && s.directory == nil {
+		return nil, fmt.Errorf("无法查询通讯录")
+	}
+
+	resp := &models.ValidationResponse{Rows: len(csvGroups)}
+	issue := func(line int, level, format string, args ...interface{}) {
+		resp.Issues = append(resp.Issues, models.ValidationIssue{Line: line, Level: level, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, warning := range warnings {
+		issue(1, models.IssueWarning, "%s", warning)
+	}
+
+	firstLines := make(map[string]int)
+	users := make(map[string]*models.User)
+	for _, csvGroup := range csvGroups {
+		line := csvGroup.Line
+
+		if missing := missingFields(csvGroup); missing != "" {
+			issue(line, models.IssueError, "%s", missing)
+		}
+
+		if name := csvGroup.Name; name != "" {
+			if length := utf8.RuneCountInString(name); length > maxGroupNameLength {
+				issue(line, models.IssueError, "群名称 %s 有 %d 个字符，超过钉钉限制的 %d 个", name, length, maxGroupNameLength)
+			}
+			if first, ok := firstLines[name]; ok {
+				issue(line, models.IssueError, "群名称 %s 与第%d行重复", name, first)
+			} else {
+				firstLines[name] = line
+				check, err := s.CheckGroupExists(ctx, name, false)
+				if err != nil {
+					return nil, fmt.Errorf("重复检查失败: %w", err)
+				}
+				if check.Exists {
+					issue(line, models.IssueError, "群名已存在: %s", describeMatches(check))
+				}
+			}
+		}
+
+		if !knownGroupType(csvGroup.GroupType) {
+			issue(line, models.IssueWarning, "无法识别的群组类型 %s，将按内部群创建", csvGroup.GroupType)
+		}
+		external := ParseGroupType(csvGroup.GroupType) == "external"
+
+		members, duplicates := parseMemberIDs(csvGroup.MemberIDs)
+		if len(duplicates) > 0 {
+			issue(line, models.IssueWarning, "成员重复: %s", strings.Join(duplicates, ", "))
+		}
+
+		// 解析 mobile:、email:、name: 形式的群主和成员，无法解析的不再参与后续检查
+		ownerID := csvGroup.OwnerID
+		if checkDirectory {
+			if ownerID != "" {
+				userID, err := s.ResolveUserID(ctx, ownerID)
+				if err != nil && !isUnresolved(err) {
+					return nil, err
+				}
+				if err != nil {
+					issue(line, models.IssueError, "群主: %s", err.Error())
+				}
+				ownerID = userID
+			}
+
+			var resolved []string
+			for _, member := range members {
+				userID, err := s.ResolveUserID(ctx, member)
+				if err != nil && !isUnresolved(err) {
+					return nil, err
+				}
+				if err != nil {
+					issue(line, models.IssueError, "成员: %s", err.Error())
+					continue
+				}
+				if !contains(resolved, userID) {
+					resolved = append(resolved, userID)
+				}
+			}
+			members = resolved
+		}
+
+		count := len(members)
+		if ownerID != "" && !contains(members, ownerID) {
+			count++
+		}
+		if count > maxCreateMemberCount {
+			issue(line, models.IssueError, "成员数 %d（含群主）超过钉钉创建群组时的上限 %d", count, maxCreateMemberCount)
+		}
+
+		if !checkDirectory {
+			continue
+		}
+
+		if ownerID != "" {
+			user, err := s.lookupUser(ctx, users, ownerID)
+			if err != nil {
+				return nil, err
+			}
+			if user == nil {
+				issue(line, models.IssueError, "群主 %s 不在企业通讯录中", ownerID)
+			} else if !user.Active {
+				issue(line, models.IssueWarning, "群主 %s (%s) 尚未激活钉钉", user.Name, user.UserID)
+			}
+		}
+
+		var missing, inactive []string
+		for _, member := range members {
+			if member == ownerID {
+				continue
+			}
+			user, err := s.lookupUser(ctx, users, member)
+			if err != nil {
+				return nil, err
+			}
+			if user == nil {
+				missing = append(missing, member)
+			} else if !user.Active {
+				inactive = append(inactive, fmt.Sprintf("%s (%s)", user.Name, user.UserID))
+			}
+		}
+		if len(missing) > 0 && external {
+			issue(line, models.IssueWarning, "成员 %s 不在企业通讯录中，请确认是外部联系人", strings.Join(missing, ", "))
+		} else if len(missing) > 0 {
+			issue(line, models.IssueError, "成员 %s 不在企业通讯录中，内部群只能添加企业内部成员", strings.Join(missing, ", "))
+		}
+		if len(inactive) > 0 {
+			issue(line, models.IssueWarning, "成员 %s 尚未激活钉钉", strings.Join(inactive, ", "))
+		}
+	}
+
+	sort.SliceStable(resp.Issues, func(i, j int) bool {
+		return resp.Issues[i].Line < resp.Issues[j].Line
+	})
+	for _, issue := range resp.Issues {
+		if issue.Level == models.IssueError {
+			resp.Errors++
+		} else {
+			resp.Warnings++
+		}
+	}
+	return resp, nil
+}
+
+// lookupUser 在通讯录中查询用户，结果缓存在 users 中；用户不存在时返回 nil
+func (s *GroupService) lookupUser(ctx context.Context, users map[string]*models.User, userID string) (*models.User, error) {
+	if user, ok := users[userID]; ok {
+		return user, nil
+	}
+
+	user, err := s.directory.GetUser(ctx, userID)
+	if err != nil {
+		if !dingtalk.HasErrcode(err, dingtalk.ErrcodeUserNotFound, dingtalk.ErrcodeInvalidUserID) {
+			return nil, wrapError(fmt.Sprintf("查询用户 %s 失败: ", userID), err)
+		}
+		user = nil
+	}
+	users[userID] = user
+	return user, nil
+}
+
+// missingFields 检查CSV中一行群组数据的必填字段，缺少时返回说明
+func missingFields(csvGroup models.CSVGroupData) string {
+	switch {
+	case csvGroup.Name == "":
+		return "群名称不能为空"
+	case csvGroup.OwnerID == "":
+		return "群主用户ID不能为空"
+	}
+	return ""
+}
+
+// knownGroupType 群组类型是否为可识别的值，留空视为内部群
+func knownGroupType(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "internal", "内部群", "内部", "external", "外部群", "外部":
+		return true
+	}
+	return false
+}
+
+// parseMemberIDs 解析逗号分隔的成员ID列表，去除空白和空项，返回去重后的成员和重复出现的成员
+func parseMemberIDs(value string) (members, duplicates []string) {
+	for _, id := range strings.Split(value, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if contains(members, id) {
+			if !contains(duplicates, id) {
+				duplicates = append(duplicates, id)
+			}
+			continue
+		}
+		members = append(members, id)
+	}
+	return members, duplicates
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/config"
+	"ti-dding/internal/dingtalk/dingtalktest"
+	"ti-dding/internal/models"
+)
+
+// issueLines 按行号汇总检查结果，每条为 "级别: 说明"
+func issueLines(resp *models.ValidationResponse) map[int][]string {
+	lines := make(map[int][]string)
+	for _, issue := range resp.Issues {
+		lines[issue.Line] = append(lines[issue.Line], issue.Level+": "+issue.Message)
+	}
+	return lines
+}
+
+// hasIssue 检查第 line 行是否有指定级别且包含 substr 的结果
+func hasIssue(lines map[int][]string, line int, level, substr string) bool {
+	for _, issue := range lines[line] {
+		if strings.HasPrefix(issue, level+": ") && strings.Contains(issue, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateGroupsCSV(t *testing.T) {
+	fake := dingtalktest.NewFake()
+	service, store := newTestService(t, fake)
+	addLocalGroup(t, fake, store, "已有群", "u1", "u1")
+
+	many := make([]string, maxCreateMemberCount)
+	for i := range many {
+		many[i] = fmt.Sprintf("m%d", i)
+	}
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表,群组类型,备注\n"+
+		"研发群,u1,\"u2,u3\",内部群,\n"+ // 第2行没有问题
+		"研发群,u4,,,\n"+
+		"已有群,u1,,,\n"+
+		"一二三四五六七八九十一二三四五六七八九十一,u1,,,\n"+
+		"市场群,,\"u2,u2, ,u3\",客户群,\n"+
+		"大群,u1,\""+strings.Join(many, ",")+"\",,\n")
+
+	resp, err := service.ValidateGroupsCSV(context.Background(), file, false)
+	if err != nil {
+		t.Fatalf("ValidateGroupsCSV: %v", err)
+	}
+	lines := issueLines(resp)
+
+	checks := []struct {
+		line   int
+		level  string
+		substr string
+	}{
+		{1, models.IssueWarning, "备注"},
+		{3, models.IssueError, "与第2行重复"},
+		{4, models.IssueError, "群名已存在"},
+		{5, models.IssueError, "超过钉钉限制的 20 个"},
+		{6, models.IssueError, "群主用户ID不能为空"},
+		{6, models.IssueWarning, "无法识别的群组类型 客户群"},
+		{6, models.IssueWarning, "成员重复: u2"},
+		{7, models.IssueError, "成员数 41（含群主）"},
+	}
+	for _, check := range checks {
+		if !hasIssue(lines, check.line, check.level, check.substr) {
+			t.Errorf("line %d: missing %s %q, got %v", check.line, check.level, check.substr, lines[check.line])
+		}
+	}
+	if len(lines[2]) != 0 {
+		t.Errorf("line 2: unexpected issues %v", lines[2])
+	}
+	if resp.Rows != 6 || resp.Errors != 5 || resp.Warnings != 3 {
+		t.Errorf("rows = %d, errors = %d, warnings = %d", resp.Rows, resp.Errors, resp.Warnings)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Errorf("validate called DingTalk: %+v", fake.Calls())
+	}
+
+	// 缺少必需的列是文件格式错误
+	file = writeFile(t, "groups.csv", "群名称,群成员用户ID列表\n研发群,u2\n")
+	if _, err := service.ValidateGroupsCSV(context.Background(), file, false); err == nil || !strings.Contains(err.Error(), "群主用户ID") {
+		t.Errorf("missing column: err = %v", err)
+	}
+}
+
+func TestValidateGroupsCSVDirectory(t *testing.T) {
+	directory := &testDirectory{users: []models.User{
+		{UserID: "u1", Name: "张三", Active: true},
+		{UserID: "u2", Name: "李四", Active: true},
+		{UserID: "u3", Name: "王五"},
+	}}
+	service, _ := newDirectoryService(t, directory, &config.GroupConfig{})
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表,群组类型\n"+
+		"研发群,u1,\"u2,u3\",内部群\n"+
+		"内部群,u1,\"u2,ext1\",内部群\n"+
+		"客户群,u1,\"u2,ext1\",外部群\n"+
+		"无主群,nobody,u2,内部群\n")
+
+	resp, err := service.ValidateGroupsCSV(context.Background(), file, true)
+	if err != nil {
+		t.Fatalf("ValidateGroupsCSV: %v", err)
+	}
+	lines := issueLines(resp)
+
+	checks := []struct {
+		line   int
+		level  string
+		substr string
+	}{
+		{2, models.IssueWarning, "王五 (u3) 尚未激活钉钉"},
+		{3, models.IssueError, "成员 ext1 不在企业通讯录中，内部群只能添加企业内部成员"},
+		{4, models.IssueWarning, "成员 ext1 不在企业通讯录中，请确认是外部联系人"},
+		{5, models.IssueError, "群主 nobody 不在企业通讯录中"},
+	}
+	for _, check := range checks {
+		if !hasIssue(lines, check.line, check.level, check.substr) {
+			t.Errorf("line %d: missing %s %q, got %v", check.line, check.level, check.substr, lines[check.line])
+		}
+	}
+	if resp.Errors != 2 || resp.Warnings != 2 {
+		t.Errorf("errors = %d, warnings = %d, issues %+v", resp.Errors, resp.Warnings, resp.Issues)
+	}
+
+	// 没有通讯录接口时无法核实成员
+	plain, _ := newTestService(t, dingtalktest.NewFake())
+	if _, err := plain.ValidateGroupsCSV(context.Background(), file, true); err == nil {
+		t.Error("directory check without directory API should fail")
+	}
+}
+
+func TestValidateGroupsCSVResolvesIdentifiers(t *testing.T) {
+	directory := &testDirectory{users: []models.User{
+		{UserID: "zhangsan", Name: "张三", Mobile: "13800000001", Active: true},
+		{UserID: "lisi", Name: "李四", Email: "lisi@example.com", Active: true},
+	}}
+	service, _ := newDirectoryService(t, directory, &config.GroupConfig{})
+
+	file := writeFile(t, "groups.csv", "群名称,群主用户ID,群成员用户ID列表\n"+
+		"研发群,mobile:13800000001,\"email:lisi@example.com,name:张三\"\n"+
+		"市场群,name:赵六,mobile:13900000000\n")
+
+	resp, err := service.ValidateGroupsCSV(context.Background(), file, true)
+	if err != nil {
+		t.Fatalf("ValidateGroupsCSV: %v", err)
+	}
+	lines := issueLines(resp)
+	if len(lines[2]) != 0 {
+		t.Errorf("line 2: unexpected issues %v", lines[2])
+	}
+	if !hasIssue(lines, 3, models.IssueError, "群主: ") || !hasIssue(lines, 3, models.IssueError, "成员: ") {
+		t.Errorf("line 3 = %v, want unresolved owner and member", lines[3])
+	}
+}
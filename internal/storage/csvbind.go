@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// csvColumn 结构体字段与表格列的对应关系，由字段的 csv 和 alias 标签声明
+//
+//	Name string `csv:"群名称,required" alias:"name,group_name"`
+type csvColumn struct {
+	header   string   // 标准列名
+	aliases  []string // 其他可接受的列名，如英文列名
+	required bool     // 文件中必须包含该列
+	field    int      // 结构体字段下标
+}
+
+// csvColumns 读取结构体类型中带 csv 标签的字段
+func csvColumns(t reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		parts := strings.Split(tag.Get("csv"), ",")
+		if parts[0] == "" {
+			continue
+		}
+
+		column := csvColumn{header: parts[0], field: i}
+		for _, option := range parts[1:] {
+			if option == "required" {
+				column.required = true
+			}
+		}
+		if alias := tag.Get("alias"); alias != "" {
+			column.aliases = strings.Split(alias, ",")
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// normalizeHeader 规范化列名：去除BOM和首尾空白，转为小写并忽略空格、下划线和连字符
+func normalizeHeader(header string) string {
+	header = strings.TrimPrefix(header, "\ufeff")
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(header)
+}
+
+// bindCSVRecords 按标题行将表格记录绑定到 out 指向的结构体切片，records 的第一条为标题行
+//
+// 列可以任意顺序排列，按 csv 标签中的中文列名或 alias 标签中的别名匹配。缺少必需列、
+// 同一字段出现多列时返回错误；未识别的列被忽略，并在返回的警告中列出。单元格去除
+// 首尾空白，所有单元格都为空的记录被跳过。lines[i] 为 records[i] 在文件中的行号，
+// 写入结构体的 Line 字段；lines 为 nil 时按记录顺序从1编号。
+func bindCSVRecords(records [][]string, lines []int, out interface{}) ([]string, error) {
+	slice := reflect.ValueOf(out).Elem()
+	elemType := slice.Type().Elem()
+	columns := csvColumns(elemType)
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("文件格式错误：缺少标题行")
+	}
+
+	lookup := make(map[string]int)
+	for i, column := range columns {
+		lookup[normalizeHeader(column.header)] = i
+		for _, alias := range column.aliases {
+			lookup[normalizeHeader(alias)] = i
+		}
+	}
+
+	// 标题行：确定每一列对应的字段
+	header := records[0]
+	bound := make([]int, len(header))
+	seen := make(map[int]string)
+	var warnings, unknown []string
+	for i, name := range header {
+		bound[i] = -1
+		if normalizeHeader(name) == "" {
+			continue
+		}
+
+		index, ok := lookup[normalizeHeader(name)]
+		if !ok {
+			unknown = append(unknown, strings.TrimSpace(name))
+			continue
+		}
+		if previous, dup := seen[index]; dup {
+			return nil, fmt.Errorf("列 \"%s\" 与 \"%s\" 都对应 %s，请删除其中一列", previous, strings.TrimSpace(name), columns[index].header)
+		}
+		seen[index] = strings.TrimSpace(name)
+		bound[i] = index
+	}
+
+	var missing []string
+	for i, column := range columns {
+		if _, ok := seen[i]; !ok && column.required {
+			missing = append(missing, column.header)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("缺少必需的列: %s（标题行: %s）", strings.Join(missing, ", "), strings.Join(header, ","))
+	}
+	if len(unknown) > 0 {
+		warnings = append(warnings, fmt.Sprintf("忽略未识别的列: %s", strings.Join(unknown, ", ")))
+	}
+
+	// 数据行
+	lineField, hasLine := elemType.FieldByName("Line")
+	for r := 1; r < len(records); r++ {
+		if blankRecord(records[r]) {
+			continue
+		}
+		elem := reflect.New(elemType).Elem()
+		for i, cell := range records[r] {
+			if i < len(bound) && bound[i] >= 0 {
+				elem.Field(columns[bound[i]].field).SetString(strings.TrimSpace(cell))
+			}
+		}
+		if hasLine {
+			line := r + 1
+			if lines != nil {
+				line = lines[r]
+			}
+			elem.FieldByIndex(lineField.Index).SetInt(int64(line))
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+
+	return warnings, nil
+}
+
+// blankRecord 记录中的单元格是否都为空
+func blankRecord(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
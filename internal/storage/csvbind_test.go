@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/models"
+)
+
+func TestBindCSVRecordsShuffledHeaders(t *testing.T) {
+	records := [][]string{
+		{"\ufeff群组类型", " 群主用户ID ", "群名称", "群成员用户ID列表"},
+		{"外部群", "u1", " 客户群 ", "u2,u3"},
+		{"", "u4", "研发群"}, // 行尾省略的列为空
+	}
+
+	var groups []models.CSVGroupData
+	warnings, err := bindCSVRecords(records, nil, &groups)
+	if err != nil {
+		t.Fatalf("bindCSVRecords: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("warnings = %v", warnings)
+	}
+
+	want := []models.CSVGroupData{
+		{Name: "客户群", OwnerID: "u1", MemberIDs: "u2,u3", GroupType: "外部群", Line: 2},
+		{Name: "研发群", OwnerID: "u4", Line: 3},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(groups), len(want))
+	}
+	for i := range want {
+		if groups[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, groups[i], want[i])
+		}
+	}
+}
+
+func TestBindCSVRecordsEnglishAliases(t *testing.T) {
+	records := [][]string{
+		{"Group Name", "OWNER-ID", "member_ids", "desc"},
+		{"研发群", "u1", "u2", "研发部门"},
+	}
+
+	var groups []models.CSVGroupData
+	if _, err := bindCSVRecords(records, nil, &groups); err != nil {
+		t.Fatalf("bindCSVRecords: %v", err)
+	}
+	want := models.CSVGroupData{Name: "研发群", OwnerID: "u1", MemberIDs: "u2", Description: "研发部门", Line: 2}
+	if len(groups) != 1 || groups[0] != want {
+		t.Fatalf("groups = %+v, want %+v", groups, want)
+	}
+}
+
+func TestBindCSVRecordsUnknownColumns(t *testing.T) {
+	records := [][]string{
+		{"群名称", "备注", "群主用户ID", "", "部门"},
+		{"研发群", "随便写", "u1", "x", "研发"},
+	}
+
+	var groups []models.CSVGroupData
+	warnings, err := bindCSVRecords(records, nil, &groups)
+	if err != nil {
+		t.Fatalf("bindCSVRecords: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "备注, 部门") {
+		t.Fatalf("warnings = %v", warnings)
+	}
+	if groups[0].Name != "研发群" || groups[0].OwnerID != "u1" {
+		t.Fatalf("groups = %+v", groups)
+	}
+}
+
+func TestBindCSVRecordsHeaderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		records [][]string
+		errSub  string
+	}{
+		{"empty", nil, "缺少标题行"},
+		{"missing required", [][]string{{"群名称", "群描述"}}, "缺少必需的列: 群主用户ID"},
+		{"missing all required", [][]string{{"群描述"}}, "群名称, 群主用户ID"},
+		{"duplicate field", [][]string{{"群名称", "name", "群主用户ID"}}, "都对应 群名称"},
+	}
+	for _, tt := range tests {
+		var groups []models.CSVGroupData
+		_, err := bindCSVRecords(tt.records, nil, &groups)
+		if err == nil || !strings.Contains(err.Error(), tt.errSub) {
+			t.Errorf("%s: err = %v, want containing %q", tt.name, err, tt.errSub)
+		}
+	}
+}
+
+func TestLoadGroupUpdatesFromCSV(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStorage(dir)
+	file := filepath.Join(dir, "updates.csv")
+
+	if err := os.WriteFile(file, []byte("全员禁言,group,new_name\n是,研发群,研发一群\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	updates, _, err := store.LoadGroupUpdatesFromCSV(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.CSVGroupUpdateData{Group: "研发群", Name: "研发一群", ChatBannedType: "是", Line: 2}
+	if len(updates) != 1 || updates[0] != want {
+		t.Fatalf("updates = %+v, want %+v", updates, want)
+	}
+
+	if err := os.WriteFile(file, []byte("全员禁言,group,new_name\n是,研发群,研发一群\n否,,客户群\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.LoadGroupUpdatesFromCSV(file); err == nil || !strings.Contains(err.Error(), "第3行群组不能为空") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLoadGroupsFromCSVLineNumbers(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "groups.csv")
+	// 空行被跳过，引号中的成员列表跨越两行
+	content := "群名称,群主用户ID,群成员用户ID列表\n" +
+		"研发群,u1,\"u2,\nu3\"\n" +
+		"\n" +
+		"客户群,u4,u5\n" +
+		",,\n" +
+		"测试群,u6,\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, _, err := NewFileStorage(dir).LoadGroupsFromCSV(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"研发群": 2, "客户群": 5, "测试群": 7}
+	if len(groups) != len(want) {
+		t.Fatalf("groups = %+v", groups)
+	}
+	for _, group := range groups {
+		if group.Line != want[group.Name] {
+			t.Errorf("%s on line %d, want %d", group.Name, group.Line, want[group.Name])
+		}
+	}
+}
+
+func TestLoadGroupsFromXLSXLineNumbers(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "groups.xlsx")
+	err := WriteXLSX(file,
+		Sheet{Name: groupsSheetName, Rows: [][]string{{"群名称", "群主用户ID"}, {"研发群", "u1"}, {}, {"客户群", "u2"}}},
+		Sheet{Name: membersSheetName, Rows: [][]string{{"群名称", "成员用户ID"}, {"研发群", "u3"}, {}, {"运维群", "u4"}}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = NewFileStorage(dir).LoadGroupsFromCSV(file)
+	if err == nil || !strings.Contains(err.Error(), "成员工作表第4行的群组 运维群") {
+		t.Fatalf("err = %v", err)
+	}
+
+	err = WriteXLSX(file, Sheet{Name: groupsSheetName, Rows: [][]string{{"群名称", "群主用户ID"}, {"研发群", "u1"}, {}, {"客户群", "u2"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, _, err := NewFileStorage(dir).LoadGroupsFromCSV(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Line != 2 || groups[1].Line != 4 {
+		t.Fatalf("groups = %+v", groups)
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ti-dding/internal/models"
+)
+
+// DirectoryCache 本地通讯录缓存，保存全部员工的用户ID、姓名、手机号和邮箱，
+// 用于按邮箱或姓名查找成员时避免每次都遍历整个企业通讯录
+type DirectoryCache struct {
+	file string
+}
+
+// NewDirectoryCache 创建通讯录缓存实例
+func NewDirectoryCache(file string) *DirectoryCache {
+	return &DirectoryCache{file: file}
+}
+
+// Load 读取缓存的员工列表和缓存时间，缓存文件不存在时返回 nil 列表和零时间
+func (c *DirectoryCache) Load() ([]models.User, time.Time, error) {
+	data, err := os.ReadFile(c.file)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("读取通讯录缓存失败: %w", err)
+	}
+
+	var content struct {
+		Users     []models.User `json:"users"`
+		UpdatedAt time.Time     `json:"updated_at"`
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, time.Time{}, fmt.Errorf("解析通讯录缓存失败: %w", err)
+	}
+
+	return content.Users, content.UpdatedAt, nil
+}
+
+// Save 保存员工列表，缓存时间为当前时间
+func (c *DirectoryCache) Save(users []models.User) error {
+	content := struct {
+		Users     []models.User `json:"users"`
+		UpdatedAt time.Time     `json:"updated_at"`
+	}{
+		Users:     users,
+		UpdatedAt: time.Now(),
+	}
+
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化通讯录缓存失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.file), 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免进程中断时留下写了一半的缓存文件
+	tmpFile := c.file + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("写入通讯录缓存失败: %w", err)
+	}
+	if err := os.Rename(tmpFile, c.file); err != nil {
+		return fmt.Errorf("写入通讯录缓存失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"ti-dding/internal/models"
+)
+
+var _ Storage = (*DryRunStorage)(nil)
+
+// DryRunStorage 演练模式下的存储
+//
+// 首次访问时从 base 读取群组列表，之后的修改只作用于内存中的副本并通过 record
+// 记录，不写入文件，使同一次演练中后续的读取能看到之前的修改。CSV 的读取和
+// 导出直接交给 base。
+type DryRunStorage struct {
+	base   Storage
+	record func(change string)
+
+	mu     sync.Mutex
+	groups []models.Group
+	loaded bool
+}
+
+// NewDryRunStorage 创建演练模式的存储
+func NewDryRunStorage(base Storage, record func(change string)) *DryRunStorage {
+	return &DryRunStorage{base: base, record: record}
+}
+
+// load 返回内存中的群组列表，调用方需持有 mu
+func (ds *DryRunStorage) load() ([]models.Group, error) {
+	if !ds.loaded {
+		groups, err := ds.base.LoadGroups()
+		if err != nil {
+			return nil, err
+		}
+		ds.groups = groups
+		ds.loaded = true
+	}
+	return ds.groups, nil
+}
+
+// SaveGroups 记录保存整个群组列表
+func (ds *DryRunStorage) SaveGroups(groups []models.Group) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.groups = make([]models.Group, len(groups))
+	for i, group := range groups {
+		ds.groups[i] = cloneGroup(group)
+	}
+	ds.loaded = true
+	ds.record(fmt.Sprintf("保存群组列表 (%d 个群组)", len(groups)))
+	return nil
+}
+
+// LoadGroups 返回内存中的群组列表副本
+func (ds *DryRunStorage) LoadGroups() ([]models.Group, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	groups, err := ds.load()
+	if err != nil {
+		return nil, err
+	}
+	copied := make([]models.Group, len(groups))
+	for i, group := range groups {
+		copied[i] = cloneGroup(group)
+	}
+	return copied, nil
+}
+
+// cloneGroup 复制群组记录，调用方修改成员等切片时不影响内存中的副本
+func cloneGroup(group models.Group) models.Group {
+	group.Members = append([]string(nil), group.Members...)
+	group.Tags = append([]string(nil), group.Tags...)
+	group.LinkedDepartments = append([]int64(nil), group.LinkedDepartments...)
+	group.LinkedMembers = append([]string(nil), group.LinkedMembers...)
+	if group.Settings != nil {
+		settings := *group.Settings
+		group.Settings = &settings
+	}
+	return group
+}
+
+// AddGroup 记录新增群组，重复检查与 FileStorage 一致
+func (ds *DryRunStorage) AddGroup(group models.Group) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	groups, err := ds.load()
+	if err != nil {
+		return err
+	}
+
+	replaced := -1
+	for i, existingGroup := range groups {
+		if existingGroup.ID == group.ID && existingGroup.Status == "deleted" {
+			replaced = i
+			continue
+		}
+		if existingGroup.ID == group.ID || (existingGroup.Name == group.Name && existingGroup.Status != "deleted") {
+			return fmt.Errorf("群组已存在: ID=%s, Name=%s", group.ID, group.Name)
+		}
+	}
+
+	if replaced >= 0 {
+		groups[replaced] = cloneGroup(group)
+	} else {
+		ds.groups = append(groups, cloneGroup(group))
+	}
+
+	ds.record(fmt.Sprintf("新增群组 %s (%s)，群主 %s，成员 %s", group.Name, group.ID, group.OwnerID, strings.Join(group.Members, ",")))
+	return nil
+}
+
+// UpdateGroup 记录群组的修改
+func (ds *DryRunStorage) UpdateGroup(group models.Group) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	groups, err := ds.load()
+	if err != nil {
+		return err
+	}
+
+	for i, existingGroup := range groups {
+		if existingGroup.ID == group.ID {
+			groups[i] = cloneGroup(group)
+			change := fmt.Sprintf("更新群组 %s (%s)", group.Name, group.ID)
+			if changes := groupChanges(existingGroup, group); len(changes) > 0 {
+				change += ": " + strings.Join(changes, "; ")
+			}
+			ds.record(change)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("群组不存在: ID=%s", group.ID)
+}
+
+// DeleteGroup 记录将群组标记为已删除
+func (ds *DryRunStorage) DeleteGroup(groupID string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	groups, err := ds.load()
+	if err != nil {
+		return err
+	}
+
+	for i, group := range groups {
+		if group.ID == groupID {
+			groups[i].Status = "deleted"
+			groups[i].UpdatedAt = time.Now()
+			ds.record(fmt.Sprintf("标记群组 %s (%s) 为已删除", group.Name, group.ID))
+			return nil
+		}
+	}
+
+	return fmt.Errorf("群组不存在: ID=%s", groupID)
+}
+
+// GetGroupByID 根据ID获取群组
+func (ds *DryRunStorage) GetGroupByID(groupID string) (*models.Group, error) {
+	groups, err := ds.LoadGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if group.ID == groupID && group.Status != "deleted" {
+			return &group, nil
+		}
+	}
+
+	return nil, fmt.Errorf("群组不存在: ID=%s", groupID)
+}
+
+// GetGroupByName 根据名称获取群组
+func (ds *DryRunStorage) GetGroupByName(name string) (*models.Group, error) {
+	groups, err := ds.LoadGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if group.Name == name && group.Status != "deleted" {
+			return &group, nil
+		}
+	}
+
+	return nil, fmt.Errorf("群组不存在: Name=%s", name)
+}
+
+// GroupExists 检查群组是否存在
+func (ds *DryRunStorage) GroupExists(name string) bool {
+	_, err := ds.GetGroupByName(name)
+	return err == nil
+}
+
+// LoadGroupsFromCSV 从CSV文件或Excel文件加载群组数据
+func (ds *DryRunStorage) LoadGroupsFromCSV(csvFile string) ([]models.CSVGroupData, []string, error) {
+	return ds.base.LoadGroupsFromCSV(csvFile)
+}
+
+// LoadGroupUpdatesFromCSV 从CSV文件或Excel文件加载群组修改数据
+func (ds *DryRunStorage) LoadGroupUpdatesFromCSV(csvFile string) ([]models.CSVGroupUpdateData, []string, error) {
+	return ds.base.LoadGroupUpdatesFromCSV(csvFile)
+}
+
+// ExportGroupsToCSV 导出群组数据到CSV文件
+func (ds *DryRunStorage) ExportGroupsToCSV(outputFile string) error {
+	return ds.base.ExportGroupsToCSV(outputFile)
+}
+
+// groupChanges 列出群组记录中发生变化的字段，不含更新时间等自动维护的字段
+func groupChanges(old, new models.Group) []string {
+	var changes []string
+	text := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", field, from, to))
+		}
+	}
+
+	text("名称", old.Name, new.Name)
+	text("描述", old.Description, new.Description)
+	text("群主", old.OwnerID, new.OwnerID)
+	text("状态", old.Status, new.Status)
+
+	var added, removed []string
+	for _, member := range new.Members {
+		if !old.IsMember(member) {
+			added = append(added, member)
+		}
+	}
+	for _, member := range old.Members {
+		if !new.IsMember(member) {
+			removed = append(removed, member)
+		}
+	}
+	if len(added) > 0 {
+		changes = append(changes, "添加成员 "+strings.Join(added, ","))
+	}
+	if len(removed) > 0 {
+		changes = append(changes, "移除成员 "+strings.Join(removed, ","))
+	}
+
+	text("标签", strings.Join(old.Tags, ","), strings.Join(new.Tags, ","))
+	if !reflect.DeepEqual(old.LinkedDepartments, new.LinkedDepartments) || old.LinkRecursive != new.LinkRecursive {
+		changes = append(changes, fmt.Sprintf("关联部门 %v -> %v", old.LinkedDepartments, new.LinkedDepartments))
+	}
+	if !reflect.DeepEqual(old.Settings, new.Settings) {
+		changes = append(changes, "群设置")
+	}
+	return changes
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/models"
+)
+
+// newDryRunStore 创建已有一个群组的文件存储及其演练包装，返回记录的修改
+func newDryRunStore(t *testing.T) (*DryRunStorage, *FileStorage, *[]string) {
+	t.Helper()
+	base := NewFileStorage(t.TempDir())
+	group := models.NewGroupWithType("研发群", "", "u1", "internal", false)
+	group.ID = "chat1"
+	group.Members = []string{"u1", "u2"}
+	if err := base.AddGroup(*group); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []string
+	return NewDryRunStorage(base, func(change string) { changes = append(changes, change) }), base, &changes
+}
+
+func TestDryRunStorageLeavesFileUntouched(t *testing.T) {
+	ds, base, changes := newDryRunStore(t)
+	before, err := os.ReadFile(filepath.Join(base.dataDir, "groups.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := models.NewGroupWithType("市场群", "", "u3", "internal", false)
+	added.ID = "chat2"
+	if err := ds.AddGroup(*added); err != nil {
+		t.Fatal(err)
+	}
+	group, err := ds.GetGroupByID("chat1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	group.Name = "研发一群"
+	group.AddMember("u3")
+	group.RemoveMember("u2")
+	if err := ds.UpdateGroup(*group); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DeleteGroup("chat2"); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.ReadFile(filepath.Join(base.dataDir, "groups.json"))
+	if err != nil || string(after) != string(before) {
+		t.Fatalf("groups.json changed in dry-run: %v", err)
+	}
+
+	want := []string{
+		"新增群组 市场群 (chat2)",
+		"更新群组 研发一群 (chat1): 名称 研发群 -> 研发一群; 添加成员 u3; 移除成员 u2",
+		"标记群组 市场群 (chat2) 为已删除",
+	}
+	if len(*changes) != len(want) {
+		t.Fatalf("changes = %q", *changes)
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix((*changes)[i], prefix) {
+			t.Errorf("change %d = %q, want prefix %q", i, (*changes)[i], prefix)
+		}
+	}
+}
+
+func TestDryRunStorageReadsOwnChanges(t *testing.T) {
+	ds, base, _ := newDryRunStore(t)
+
+	added := models.NewGroupWithType("市场群", "", "u3", "internal", false)
+	added.ID = "chat2"
+	if err := ds.AddGroup(*added); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一次演练中后续的读取和重复检查能看到之前的修改
+	if !ds.GroupExists("市场群") {
+		t.Error("added group not visible")
+	}
+	if err := ds.AddGroup(*added); err == nil {
+		t.Error("duplicate group accepted")
+	}
+	if base.GroupExists("市场群") {
+		t.Error("added group written to the base storage")
+	}
+
+	// 修改返回的副本不影响内存中的记录
+	group, _ := ds.GetGroupByID("chat1")
+	group.Members[0] = "changed"
+	if again, _ := ds.GetGroupByID("chat1"); again.Members[0] != "u1" {
+		t.Errorf("stored members changed through a returned copy: %v", again.Members)
+	}
+
+	if err := ds.DeleteGroup("chat1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.GetGroupByID("chat1"); err == nil {
+		t.Error("deleted group still returned")
+	}
+	if err := ds.UpdateGroup(models.Group{ID: "missing"}); err == nil {
+		t.Error("update of missing group accepted")
+	}
+}
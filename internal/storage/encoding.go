@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// CSV文件编码名称
+const (
+	EncodingAuto    = "auto"      // 读取时自动识别，写入时为 UTF-8
+	EncodingUTF8    = "utf-8"     // UTF-8，不带BOM
+	EncodingUTF8BOM = "utf-8-bom" // UTF-8 带BOM，Excel 打开时能正确识别中文
+	EncodingGBK     = "gbk"       // 中文 Windows 下 Excel 保存的CSV
+	EncodingGB18030 = "gb18030"   // GBK 的超集
+	EncodingUTF16   = "utf-16"    // 读取时按BOM判断字节序，写入时为带BOM的小端序
+	EncodingUTF16LE = "utf-16le"  // UTF-16 小端序
+	EncodingUTF16BE = "utf-16be"  // UTF-16 大端序
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// ParseEncoding 规范化编码名称，空字符串视为 auto，不支持的编码返回错误
+func ParseEncoding(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "":
+		return EncodingAuto, nil
+	case "utf8":
+		return EncodingUTF8, nil
+	case "utf8-bom", "utf-8-sig":
+		return EncodingUTF8BOM, nil
+	case "utf16":
+		return EncodingUTF16, nil
+	case EncodingAuto, EncodingUTF8, EncodingUTF8BOM, EncodingGBK, EncodingGB18030,
+		EncodingUTF16, EncodingUTF16LE, EncodingUTF16BE:
+		return name, nil
+	}
+	return "", fmt.Errorf("不支持的文件编码: %s（可选 auto、utf-8、utf-8-bom、gbk、gb18030、utf-16、utf-16le、utf-16be）", name)
+}
+
+// decodeText 将文件内容按指定编码转换为 UTF-8，并去除开头的BOM
+//
+// auto 时先按BOM判断（UTF-8、UTF-16），没有BOM时依次尝试 UTF-8、无BOM的 UTF-16
+// 和 GB18030（兼容 GBK），都不合适时返回错误，避免乱码被当作群名称发送到钉钉。
+func decodeText(data []byte, name string) ([]byte, error) {
+	switch name {
+	case EncodingAuto:
+		return detectAndDecode(data)
+	case EncodingUTF8, EncodingUTF8BOM:
+		data = bytes.TrimPrefix(data, utf8BOM)
+		if !utf8.Valid(data) {
+			return nil, fmt.Errorf("文件不是有效的 UTF-8 编码，请使用 --encoding 指定正确的编码")
+		}
+		return data, nil
+	case EncodingGBK:
+		return decodeWith(data, simplifiedchinese.GBK, name)
+	case EncodingGB18030:
+		return decodeWith(data, simplifiedchinese.GB18030, name)
+	case EncodingUTF16:
+		if bytes.HasPrefix(data, utf16BEBOM) {
+			return decodeWith(data, unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), name)
+		}
+		return decodeWith(data, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), name)
+	case EncodingUTF16LE:
+		return decodeWith(data, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), name)
+	case EncodingUTF16BE:
+		return decodeWith(data, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), name)
+	}
+	return nil, fmt.Errorf("不支持的文件编码: %s", name)
+}
+
+// detectAndDecode 自动识别编码并转换为 UTF-8
+func detectAndDecode(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return decodeText(data, EncodingUTF8)
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return decodeText(data, EncodingUTF16LE)
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return decodeText(data, EncodingUTF16BE)
+	}
+
+	if order := guessUTF16(data); order != "" {
+		return decodeText(data, order)
+	}
+	if utf8.Valid(data) {
+		return data, nil
+	}
+
+	decoded, err := decodeWith(data, simplifiedchinese.GB18030, EncodingGB18030)
+	if err != nil || bytes.ContainsRune(decoded, utf8.RuneError) {
+		return nil, fmt.Errorf("无法识别文件编码，请使用 --encoding 指定（如 gbk、utf-8）")
+	}
+	return decoded, nil
+}
+
+// guessUTF16 识别没有BOM的 UTF-16 文本：CSV 中大部分是ASCII字符，其 UTF-16 编码的
+// 高位字节为 0，奇数（小端序）或偶数（大端序）位置上大量出现 0 字节即可判断字节序
+func guessUTF16(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+
+	var even, odd int
+	for i, b := range data {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+
+	switch {
+	case odd > len(data)/4 && even*8 < odd:
+		return EncodingUTF16LE
+	case even > len(data)/4 && odd*8 < even:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+// decodeWith 用指定编码解码
+func decodeWith(data []byte, enc encoding.Encoding, name string) ([]byte, error) {
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), data)
+	if err != nil {
+		return nil, fmt.Errorf("按 %s 编码读取文件失败: %w", name, err)
+	}
+	return bytes.TrimPrefix(decoded, utf8BOM), nil
+}
+
+// encodingWriter 返回按指定编码写入 w 的 Writer，需要写BOM的编码会先写入BOM；
+// 写入完成后必须调用 Close 输出缓冲的内容
+func encodingWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	var enc encoding.Encoding
+	switch name {
+	case EncodingAuto, EncodingUTF8:
+		return nopCloser{w}, nil
+	case EncodingUTF8BOM:
+		if _, err := w.Write(utf8BOM); err != nil {
+			return nil, err
+		}
+		return nopCloser{w}, nil
+	case EncodingGBK:
+		enc = simplifiedchinese.GBK
+	case EncodingGB18030:
+		enc = simplifiedchinese.GB18030
+	case EncodingUTF16, EncodingUTF16LE:
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case EncodingUTF16BE:
+		enc = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	default:
+		return nil, fmt.Errorf("不支持的文件编码: %s", name)
+	}
+	return transform.NewWriter(w, enc.NewEncoder()), nil
+}
+
+// nopCloser 不需要转换编码时包装原始 Writer
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const sampleCSV = "群名称,群主用户ID\n研发群,u1\n客户服务群,u2\n"
+
+// asciiCSV 以用户ID为主的表格，没有BOM的 UTF-16 按其中的 0 字节识别字节序
+const asciiCSV = "群名称,群主用户ID,群成员用户ID列表\n研发群,manager01,\"user1001,user1002,user1003\"\n"
+
+// encode 将 UTF-8 文本转换为指定编码，不写BOM
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	data, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectAndDecode(t *testing.T) {
+	le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	be := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"utf-8", []byte(sampleCSV)},
+		{"utf-8 bom", append(append([]byte(nil), utf8BOM...), sampleCSV...)},
+		{"gbk", encode(t, simplifiedchinese.GBK, sampleCSV)},
+		{"utf-16le bom", append(append([]byte(nil), utf16LEBOM...), encode(t, le, sampleCSV)...)},
+		{"utf-16be bom", append(append([]byte(nil), utf16BEBOM...), encode(t, be, sampleCSV)...)},
+	}
+	for _, tt := range tests {
+		got, err := decodeText(tt.data, EncodingAuto)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != sampleCSV {
+			t.Errorf("%s: decoded %q", tt.name, got)
+		}
+	}
+
+	for name, data := range map[string][]byte{"utf-16le": encode(t, le, asciiCSV), "utf-16be": encode(t, be, asciiCSV)} {
+		got, err := decodeText(data, EncodingAuto)
+		if err != nil || string(got) != asciiCSV {
+			t.Errorf("%s without bom: %q, %v", name, got, err)
+		}
+	}
+}
+
+func TestDecodeTextInvalid(t *testing.T) {
+	// 0xFF 在 UTF-8 和 GB18030 中都不能出现在这个位置
+	garbage := []byte("群名称\n\xff\xff\xff\n")
+	if _, err := decodeText(garbage, EncodingAuto); err == nil || !strings.Contains(err.Error(), "--encoding") {
+		t.Errorf("auto: err = %v", err)
+	}
+
+	gbk := encode(t, simplifiedchinese.GBK, sampleCSV)
+	if _, err := decodeText(gbk, EncodingUTF8); err == nil || !strings.Contains(err.Error(), "UTF-8") {
+		t.Errorf("gbk read as utf-8: err = %v", err)
+	}
+
+	if _, err := decodeText([]byte(sampleCSV), "latin1"); err == nil {
+		t.Error("unsupported encoding accepted")
+	}
+}
+
+func TestDecodeTextExplicit(t *testing.T) {
+	got, err := decodeText(encode(t, simplifiedchinese.GBK, sampleCSV), EncodingGBK)
+	if err != nil || string(got) != sampleCSV {
+		t.Fatalf("gbk: %q, %v", got, err)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":          EncodingAuto,
+		" AUTO ":    EncodingAuto,
+		"utf8":      EncodingUTF8,
+		"UTF-8-SIG": EncodingUTF8BOM,
+		"utf16":     EncodingUTF16,
+		"GBK":       EncodingGBK,
+		"utf-16be":  EncodingUTF16BE,
+	}
+	for name, want := range tests {
+		got, err := ParseEncoding(name)
+		if err != nil || got != want {
+			t.Errorf("ParseEncoding(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseEncoding("big5"); err == nil {
+		t.Error("ParseEncoding(big5) should fail")
+	}
+}
+
+func TestEncodingWriterRoundTrip(t *testing.T) {
+	for _, name := range []string{EncodingUTF8, EncodingUTF8BOM, EncodingGBK, EncodingGB18030, EncodingUTF16, EncodingUTF16LE, EncodingUTF16BE} {
+		var buf bytes.Buffer
+		w, err := encodingWriter(&buf, name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := w.Write([]byte(sampleCSV)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		for _, read := range []string{name, EncodingAuto} {
+			got, err := decodeText(buf.Bytes(), read)
+			if err != nil || string(got) != sampleCSV {
+				t.Errorf("write %s, read %s: %q, %v", name, read, got, err)
+			}
+		}
+	}
+
+	if _, err := encodingWriter(&bytes.Buffer{}, "latin1"); err == nil {
+		t.Error("unsupported encoding accepted")
+	}
+}
+
+func TestLoadGroupsFromGBKFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "groups.csv")
+	if err := os.WriteFile(file, encode(t, simplifiedchinese.GBK, sampleCSV), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, _, err := NewFileStorage(dir).LoadGroupsFromCSV(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[1].Name != "客户服务群" || groups[1].OwnerID != "u2" {
+		t.Fatalf("groups = %+v", groups)
+	}
+}
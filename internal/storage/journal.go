@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ti-dding/internal/models"
+)
+
+// Journal 批量操作的断点续传日志
+//
+// 每行一条 JSON 记录，只追加不修改：调用钉钉接口前写入 pending，接口成功后写入
+// created（含群组ID），保存到本地后写入 done，确定失败时写入 failed。进程在任意
+// 位置退出后，重新运行时可根据每行最后的状态决定跳过、补救还是重做。
+type Journal struct {
+	file string
+	mu   sync.Mutex
+}
+
+// NewJournal 创建批量操作日志，日志文件名由操作名称和输入文件的绝对路径决定，
+// 同一输入文件的多次运行使用同一个日志
+func NewJournal(dir, operation, inputFile string) *Journal {
+	absPath, err := filepath.Abs(inputFile)
+	if err != nil {
+		absPath = inputFile
+	}
+	sum := sha1.Sum([]byte(absPath))
+
+	base := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	name := fmt.Sprintf("%s-%s-%s.jsonl", operation, base, hex.EncodeToString(sum[:4]))
+	return &Journal{file: filepath.Join(dir, name)}
+}
+
+// Path 返回日志文件路径
+func (j *Journal) Path() string {
+	return j.file
+}
+
+// Exists 日志文件是否存在
+func (j *Journal) Exists() bool {
+	_, err := os.Stat(j.file)
+	return err == nil
+}
+
+// Load 读取日志，返回每个键最后的记录
+//
+// 日志文件不存在时返回空结果；写了一半的行（进程在写入时退出）被忽略。
+func (j *Journal) Load() (map[string]models.JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make(map[string]models.JournalEntry)
+
+	file, err := os.Open(j.file)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取操作日志失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry models.JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries[entry.Key] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取操作日志失败: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Append 追加一条记录并同步到磁盘，上次写了一半的行会先补上换行，不影响本条记录
+func (j *Journal) Append(entry models.JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化操作日志失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.file), 0755); err != nil {
+		return fmt.Errorf("创建操作日志目录失败: %w", err)
+	}
+	file, err := os.OpenFile(j.file, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("写入操作日志失败: %w", err)
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入操作日志失败: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("写入操作日志失败: %w", err)
+	}
+	return nil
+}
+
+// Remove 删除日志文件，文件不存在时不报错
+func (j *Journal) Remove() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.Remove(j.file); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除操作日志失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"ti-dding/internal/models"
+)
+
+func TestJournalLastStateWins(t *testing.T) {
+	journal := NewJournal(t.TempDir(), "create", "groups.csv")
+	if journal.Exists() {
+		t.Fatal("new journal exists")
+	}
+	entries, err := journal.Load()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Load missing journal = %v, %v", entries, err)
+	}
+
+	for _, entry := range []models.JournalEntry{
+		{Row: 2, Key: "研发群", State: models.JournalPending},
+		{Row: 3, Key: "客户群", State: models.JournalPending},
+		{Row: 2, Key: "研发群", State: models.JournalCreated, ChatID: "chat1"},
+	} {
+		if err := journal.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err = journal.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entries["研发群"]; got.State != models.JournalCreated || got.ChatID != "chat1" {
+		t.Errorf("研发群 = %+v", got)
+	}
+	if got := entries["客户群"]; got.State != models.JournalPending || got.Row != 3 {
+		t.Errorf("客户群 = %+v", got)
+	}
+}
+
+func TestJournalIgnoresPartialLine(t *testing.T) {
+	journal := NewJournal(t.TempDir(), "create", "groups.csv")
+	if err := journal.Append(models.JournalEntry{Row: 2, Key: "研发群", State: models.JournalPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 进程在写入下一行时退出
+	file, err := os.OpenFile(journal.Path(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"row":2,"key":"研发群","sta`)
+	file.Close()
+
+	entries, err := journal.Load()
+	if err != nil || entries["研发群"].State != models.JournalPending {
+		t.Fatalf("Load after partial line = %v, %v", entries, err)
+	}
+
+	// 之后追加的记录不受半行影响
+	if err := journal.Append(models.JournalEntry{Row: 2, Key: "研发群", State: models.JournalDone, ChatID: "chat1"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = journal.Load()
+	if err != nil || entries["研发群"].State != models.JournalDone {
+		t.Fatalf("Load after append = %v, %v", entries, err)
+	}
+
+	if err := journal.Remove(); err != nil || journal.Exists() {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := journal.Remove(); err != nil {
+		t.Fatalf("Remove missing journal: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"ti-dding/internal/models"
+)
+
+// LoadManifest 读取群组清单，按扩展名识别格式：.json 为JSON，其余为YAML
+func LoadManifest(file string) (*models.Manifest, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取清单文件失败: %w", err)
+	}
+
+	var manifest models.Manifest
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		err = json.Unmarshal(data, &manifest)
+	} else {
+		err = yaml.Unmarshal(data, &manifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析清单文件失败: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// SavePlan 将执行计划保存为JSON文件
+func SavePlan(file string, plan *models.Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化执行计划失败: %w", err)
+	}
+
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("写入执行计划失败: %w", err)
+	}
+	return nil
+}
+
+// LoadPlan 读取 SavePlan 保存的执行计划
+func LoadPlan(file string) (*models.Plan, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取执行计划失败: %w", err)
+	}
+
+	var plan models.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("解析执行计划失败: %w", err)
+	}
+	return &plan, nil
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ti-dding/internal/models"
+)
+
+// 登记表锁文件的等待时间和失效时间
+const (
+	registryLockTimeout = 15 * time.Second
+	registryLockStale   = 2 * time.Minute
+)
+
+// Registry 团队共享的群组登记表
+//
+// 登记表通常放在共享目录中，记录团队成员通过本工具创建或纳管的群组，
+// 使每个人的重复检查都能看到其他人创建的群组。每次读写都直接访问文件，
+// 不在内存中缓存；修改时持有锁文件，多人同时写入不会互相覆盖。
+type Registry struct {
+	file   string
+	record func(change string) // 演练模式下记录写入操作，不为 nil 时不写文件
+	mu     sync.Mutex          // 串行化本进程内的写入
+}
+
+// NewRegistry 创建登记表实例
+func NewRegistry(file string) *Registry {
+	return &Registry{file: file}
+}
+
+// SetDryRun 开启演练模式，之后的写入操作只交给 record 记录，不写入文件
+func (r *Registry) SetDryRun(record func(change string)) {
+	r.record = record
+}
+
+// Load 读取全部登记项，文件不存在时返回空列表
+func (r *Registry) Load() ([]models.RegistryEntry, error) {
+	data, err := os.ReadFile(r.file)
+	if os.IsNotExist(err) {
+		return []models.RegistryEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取共享登记表失败: %w", err)
+	}
+
+	var content struct {
+		Groups []models.RegistryEntry `json:"groups"`
+	}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("解析共享登记表失败: %w", err)
+	}
+
+	return content.Groups, nil
+}
+
+// FindByName 查找指定名称的登记项
+func (r *Registry) FindByName(name string) ([]models.RegistryEntry, error) {
+	entries, err := r.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []models.RegistryEntry
+	for _, entry := range entries {
+		if entry.Name == name {
+			matches = append(matches, entry)
+		}
+	}
+	return matches, nil
+}
+
+// Put 添加或更新登记项（按群会话ID匹配）
+func (r *Registry) Put(entry models.RegistryEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := r.Load()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, existing := range entries {
+		if existing.ChatID == entry.ChatID {
+			entries[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+
+	if r.record != nil {
+		r.record(fmt.Sprintf("登记群组 %s (%s)，来源 %s", entry.Name, entry.ChatID, entry.Source))
+		return nil
+	}
+	return r.save(entries)
+}
+
+// Rename 更新登记项的群名称，登记表中没有该群组时不做任何操作
+func (r *Registry) Rename(chatID, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := r.Load()
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if entry.ChatID == chatID {
+			entries[i].Name = name
+			if r.record != nil {
+				r.record(fmt.Sprintf("将群组 %s 的登记名称由 %s 改为 %s", chatID, entry.Name, name))
+				return nil
+			}
+			return r.save(entries)
+		}
+	}
+	return nil
+}
+
+// lock 创建锁文件（登记表路径加 .lock），在读取、修改、写回登记表期间阻止其他进程写入，
+// 返回释放锁的函数。演练模式下不写文件，也不加锁。
+//
+// 使用独占创建的锁文件而不是系统文件锁，在网络共享目录和不同操作系统上行为一致。
+// 持有锁的进程异常退出时锁文件会残留，超过 registryLockStale 的锁文件视为失效并删除。
+func (r *Registry) lock() (func(), error) {
+	if r.record != nil {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return nil, fmt.Errorf("创建共享登记表目录失败: %w", err)
+	}
+
+	lockFile := r.file + ".lock"
+	deadline := time.Now().Add(registryLockTimeout)
+	for {
+		file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			host, _ := os.Hostname()
+			fmt.Fprintf(file, "%s %d\n", host, os.Getpid())
+			file.Close()
+			return func() { os.Remove(lockFile) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("锁定共享登记表失败: %w", err)
+		}
+
+		if info, err := os.Stat(lockFile); err == nil && time.Since(info.ModTime()) > registryLockStale {
+			os.Remove(lockFile)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("共享登记表正在被其他人修改，请稍后重试；如确认无人使用，可删除锁文件 %s", lockFile)
+		}
+		time.Sleep(20*time.Millisecond + time.Duration(rand.Int63n(int64(30*time.Millisecond))))
+	}
+}
+
+// save 写入登记表，先写临时文件再重命名，避免其他人读到写了一半的文件
+func (r *Registry) save(entries []models.RegistryEntry) error {
+	if err := os.MkdirAll(filepath.Dir(r.file), 0755); err != nil {
+		return fmt.Errorf("创建共享登记表目录失败: %w", err)
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Groups    []models.RegistryEntry `json:"groups"`
+		UpdatedAt time.Time              `json:"updated_at"`
+	}{
+		Groups:    entries,
+		UpdatedAt: time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化共享登记表失败: %w", err)
+	}
+
+	tmpFile := fmt.Sprintf("%s.%d.tmp", r.file, os.Getpid())
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return fmt.Errorf("写入共享登记表失败: %w", err)
+	}
+	if err := os.Rename(tmpFile, r.file); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("写入共享登记表失败: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"ti-dding/internal/models"
+)
+
+func TestRegistryConcurrentWriters(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "shared", "registry.json")
+
+	// 每个登记表实例模拟一个独立的进程，只能依靠锁文件互斥
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			registry := NewRegistry(file)
+			errs <- registry.Put(models.RegistryEntry{ChatID: fmt.Sprintf("chat%02d", i), Name: fmt.Sprintf("群%02d", i)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	entries, err := NewRegistry(file).Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(entries) != writers {
+		t.Fatalf("registry has %d entries, want %d", len(entries), writers)
+	}
+	if _, err := os.Stat(file + ".lock"); !os.IsNotExist(err) {
+		t.Fatalf("lock file left behind: %v", err)
+	}
+}
+
+func TestRegistryPutReplacesAndRename(t *testing.T) {
+	registry := NewRegistry(filepath.Join(t.TempDir(), "registry.json"))
+	if err := registry.Put(models.RegistryEntry{ChatID: "chat1", Name: "旧名称", Source: models.RegistrySourceCreated}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Put(models.RegistryEntry{ChatID: "chat1", Name: "旧名称", Source: models.RegistrySourceAdopted}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Rename("chat1", "新名称"); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Rename("missing", "其他"); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := registry.FindByName("新名称")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Source != models.RegistrySourceAdopted {
+		t.Fatalf("FindByName = %+v", matches)
+	}
+	if old, _ := registry.FindByName("旧名称"); len(old) != 0 {
+		t.Fatalf("old name still registered: %+v", old)
+	}
+}
+
+func TestRegistryRemovesStaleLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registry.json")
+	lockFile := file + ".lock"
+	if err := os.WriteFile(lockFile, []byte("otherhost 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-registryLockStale - time.Minute)
+	if err := os.Chtimes(lockFile, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewRegistry(file).Put(models.RegistryEntry{ChatID: "chat1", Name: "群1"}); err != nil {
+		t.Fatalf("Put with stale lock: %v", err)
+	}
+}
+
+func TestRegistryDryRunDoesNotWrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "registry.json")
+	registry := NewRegistry(file)
+	var changes []string
+	registry.SetDryRun(func(change string) { changes = append(changes, change) })
+
+	if err := registry.Put(models.RegistryEntry{ChatID: "chat1", Name: "群1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("recorded %d changes, want 1", len(changes))
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote the registry: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"ti-dding/internal/models"
+)
+
+// ExportOwnerTransferReport 将群主转移结果写入CSV文件
+func ExportOwnerTransferReport(outputFile string, resp *models.OwnerTransferResponse) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建报告文件失败: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	headers := []string{"群组ID", "群名称", "原群主", "新群主", "结果", "说明"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("写入报告标题失败: %w", err)
+	}
+
+	for _, result := range resp.Results {
+		record := []string{result.GroupID, result.Name, resp.From, resp.To, result.Status, result.Message}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("写入报告数据失败: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("写入报告文件失败: %w", err)
+	}
+	return nil
+}
+
+// ExportOffboardReport 将员工离职处理结果写入CSV文件
+func ExportOffboardReport(outputFile string, resp *models.OffboardResponse) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建报告文件失败: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+
+	headers := []string{"群组ID", "群名称", "离职员工", "是否群主", "继任群主", "已执行的变更", "需要人工处理"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("写入报告标题失败: %w", err)
+	}
+
+	for _, result := range resp.Groups {
+		wasOwner, successor := "否", ""
+		if result.WasOwner {
+			wasOwner, successor = "是", resp.Successor
+		}
+		record := []string{
+			result.GroupID,
+			result.Name,
+			resp.UserID,
+			wasOwner,
+			successor,
+			strings.Join(result.Actions, "; "),
+			result.FollowUp,
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("写入报告数据失败: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("写入报告文件失败: %w", err)
+	}
+	return nil
+}
+
+// ExportBatchReport 将批量操作每一项的结果写入报告文件
+//
+// 扩展名为 .json 时写入 JSON 数组，否则写入CSV。
+func ExportBatchReport(outputFile string, results []models.BatchItemResult) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建报告文件失败: %w", err)
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(outputFile), ".json") {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return fmt.Errorf("写入报告文件失败: %w", err)
+		}
+		return nil
+	}
+
+	writer := csv.NewWriter(file)
+
+	headers := []string{"行号", "群名称", "群组ID", "结果", "错误码", "错误信息", "耗时(毫秒)"}
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("写入报告标题失败: %w", err)
+	}
+
+	for _, result := range results {
+		row, errcode := "", ""
+		if result.Row > 0 {
+			row = strconv.Itoa(result.Row)
+		}
+		if result.Errcode != 0 {
+			errcode = strconv.Itoa(result.Errcode)
+		}
+		record := []string{row, result.Name, result.GroupID, result.Status, errcode, result.Error, strconv.FormatInt(result.DurationMS, 10)}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("写入报告数据失败: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("写入报告文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/models"
+)
+
+var batchResults = []models.BatchItemResult{
+	{Row: 2, Name: "研发群", GroupID: "chat1", Status: models.ItemSucceeded, DurationMS: 120},
+	{Row: 3, Name: "客户群", Status: models.ItemFailed, Errcode: 60011, Error: "权限不足, 请联系管理员"},
+	{Name: "运维群", Status: models.ItemNotStarted, Error: "操作已中断，未处理"},
+}
+
+func TestExportBatchReportCSV(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.csv")
+	if err := ExportBatchReport(file, batchResults); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"行号", "群名称", "群组ID", "结果", "错误码", "错误信息", "耗时(毫秒)"},
+		{"2", "研发群", "chat1", models.ItemSucceeded, "", "", "120"},
+		{"3", "客户群", "", models.ItemFailed, "60011", "权限不足, 请联系管理员", "0"},
+		{"", "运维群", "", models.ItemNotStarted, "", "操作已中断，未处理", "0"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestExportBatchReportJSON(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.JSON")
+	if err := ExportBatchReport(file, batchResults); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []models.BatchItemResult
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("report is not a JSON array: %v", err)
+	}
+	if len(got) != len(batchResults) {
+		t.Fatalf("got %d results", len(got))
+	}
+	for i := range got {
+		if got[i] != batchResults[i] {
+			t.Errorf("result %d = %+v, want %+v", i, got[i], batchResults[i])
+		}
+	}
+}
@@ -1,18 +1,23 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 
 	"ti-dding/internal/models"
 )
 
 // Storage 数据存储接口
+//
+// 方法不接收 context：存储只读写本地文件，耗时很短；批量操作被取消后仍需要把
+// 已在钉钉创建的群组写入本地，不能因上下文取消而中断保存。
 type Storage interface {
 	SaveGroups(groups []models.Group) error
 	LoadGroups() ([]models.Group, error)
@@ -22,12 +27,21 @@ type Storage interface {
 	GetGroupByID(groupID string) (*models.Group, error)
 	GetGroupByName(name string) (*models.Group, error)
 	GroupExists(name string) bool
+	LoadGroupsFromCSV(csvFile string) ([]models.CSVGroupData, []string, error)
+	LoadGroupUpdatesFromCSV(csvFile string) ([]models.CSVGroupUpdateData, []string, error)
+	ExportGroupsToCSV(outputFile string) error
 }
 
+var _ Storage = (*FileStorage)(nil)
+
 // FileStorage 文件存储实现
+//
+// 读写群组数据文件的方法由互斥锁串行化，可以在多个协程中同时使用。
 type FileStorage struct {
 	dataDir    string
 	groupsFile string
+	encoding   string // 导入、导出CSV文件的编码，见 SetEncoding
+	mu         sync.Mutex
 }
 
 // NewFileStorage 创建新的文件存储实例
@@ -38,8 +52,29 @@ func NewFileStorage(dataDir string) *FileStorage {
 	}
 }
 
+// SetEncoding 设置导入、导出CSV文件的编码（见 ParseEncoding）
+//
+// 默认为 auto：导入时自动识别 UTF-8（含BOM）、UTF-16 和 GBK/GB18030，导出为 UTF-8。
+// Excel文件不受影响。
+func (fs *FileStorage) SetEncoding(name string) error {
+	encoding, err := ParseEncoding(name)
+	if err != nil {
+		return err
+	}
+	fs.encoding = encoding
+	return nil
+}
+
 // SaveGroups 保存群组列表到文件
 func (fs *FileStorage) SaveGroups(groups []models.Group) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.saveGroups(groups)
+}
+
+// saveGroups 保存群组列表到文件，调用方需持有 mu
+func (fs *FileStorage) saveGroups(groups []models.Group) error {
 	// 确保数据目录存在
 	if err := os.MkdirAll(fs.dataDir, 0755); err != nil {
 		return fmt.Errorf("创建数据目录失败: %w", err)
@@ -62,8 +97,13 @@ func (fs *FileStorage) SaveGroups(groups []models.Group) error {
 		return fmt.Errorf("序列化群组数据失败: %w", err)
 	}
 
-	// 写入文件
-	if err := os.WriteFile(fs.groupsFile, jsonData, 0644); err != nil {
+	// 先写临时文件再重命名，避免进程中断时留下写了一半的数据文件
+	tmpFile := fs.groupsFile + ".tmp"
+	if err := os.WriteFile(tmpFile, jsonData, 0644); err != nil {
+		return fmt.Errorf("写入群组数据文件失败: %w", err)
+	}
+	if err := os.Rename(tmpFile, fs.groupsFile); err != nil {
+		os.Remove(tmpFile)
 		return fmt.Errorf("写入群组数据文件失败: %w", err)
 	}
 
@@ -72,6 +112,14 @@ func (fs *FileStorage) SaveGroups(groups []models.Group) error {
 
 // LoadGroups 从文件加载群组列表
 func (fs *FileStorage) LoadGroups() ([]models.Group, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.loadGroups()
+}
+
+// loadGroups 从文件加载群组列表，调用方需持有 mu
+func (fs *FileStorage) loadGroups() ([]models.Group, error) {
 	// 检查文件是否存在
 	if _, err := os.Stat(fs.groupsFile); os.IsNotExist(err) {
 		// 文件不存在，返回空列表
@@ -98,28 +146,43 @@ func (fs *FileStorage) LoadGroups() ([]models.Group, error) {
 
 // AddGroup 添加新群组
 func (fs *FileStorage) AddGroup(group models.Group) error {
-	groups, err := fs.LoadGroups()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	groups, err := fs.loadGroups()
 	if err != nil {
 		return err
 	}
 
-	// 检查群组是否已存在
-	for _, existingGroup := range groups {
-		if existingGroup.ID == group.ID || existingGroup.Name == group.Name {
+	// 检查群组是否已存在（已删除的群组不参与名称检查，同ID的已删除记录被替换）
+	replaced := -1
+	for i, existingGroup := range groups {
+		if existingGroup.ID == group.ID && existingGroup.Status == "deleted" {
+			replaced = i
+			continue
+		}
+		if existingGroup.ID == group.ID || (existingGroup.Name == group.Name && existingGroup.Status != "deleted") {
 			return fmt.Errorf("群组已存在: ID=%s, Name=%s", group.ID, group.Name)
 		}
 	}
 
 	// 添加新群组
-	groups = append(groups, group)
+	if replaced >= 0 {
+		groups[replaced] = group
+	} else {
+		groups = append(groups, group)
+	}
 
 	// 保存到文件
-	return fs.SaveGroups(groups)
+	return fs.saveGroups(groups)
 }
 
 // UpdateGroup 更新群组信息
 func (fs *FileStorage) UpdateGroup(group models.Group) error {
-	groups, err := fs.LoadGroups()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	groups, err := fs.loadGroups()
 	if err != nil {
 		return err
 	}
@@ -128,7 +191,7 @@ func (fs *FileStorage) UpdateGroup(group models.Group) error {
 	for i, existingGroup := range groups {
 		if existingGroup.ID == group.ID {
 			groups[i] = group
-			return fs.SaveGroups(groups)
+			return fs.saveGroups(groups)
 		}
 	}
 
@@ -137,7 +200,10 @@ func (fs *FileStorage) UpdateGroup(group models.Group) error {
 
 // DeleteGroup 删除群组
 func (fs *FileStorage) DeleteGroup(groupID string) error {
-	groups, err := fs.LoadGroups()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	groups, err := fs.loadGroups()
 	if err != nil {
 		return err
 	}
@@ -148,7 +214,7 @@ func (fs *FileStorage) DeleteGroup(groupID string) error {
 			// 标记为已删除而不是物理删除
 			groups[i].Status = "deleted"
 			groups[i].UpdatedAt = time.Now()
-			return fs.SaveGroups(groups)
+			return fs.saveGroups(groups)
 		}
 	}
 
@@ -157,7 +223,10 @@ func (fs *FileStorage) DeleteGroup(groupID string) error {
 
 // GetGroupByID 根据ID获取群组
 func (fs *FileStorage) GetGroupByID(groupID string) (*models.Group, error) {
-	groups, err := fs.LoadGroups()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	groups, err := fs.loadGroups()
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +242,10 @@ func (fs *FileStorage) GetGroupByID(groupID string) (*models.Group, error) {
 
 // GetGroupByName 根据名称获取群组
 func (fs *FileStorage) GetGroupByName(name string) (*models.Group, error) {
-	groups, err := fs.LoadGroups()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	groups, err := fs.loadGroups()
 	if err != nil {
 		return nil, err
 	}
@@ -189,7 +261,10 @@ func (fs *FileStorage) GetGroupByName(name string) (*models.Group, error) {
 
 // GroupExists 检查群组是否存在
 func (fs *FileStorage) GroupExists(name string) bool {
-	groups, err := fs.LoadGroups()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	groups, err := fs.loadGroups()
 	if err != nil {
 		return false
 	}
@@ -203,63 +278,176 @@ func (fs *FileStorage) GroupExists(name string) bool {
 	return false
 }
 
-// LoadGroupsFromCSV 从CSV文件加载群组数据
-func (fs *FileStorage) LoadGroupsFromCSV(csvFile string) ([]models.CSVGroupData, error) {
-	file, err := os.Open(csvFile)
+// LoadGroupsFromCSV 从CSV文件或Excel文件（.xlsx）加载群组数据
+//
+// 按标题行匹配列（中文列名或英文别名，见 models.CSVGroupData），列的顺序不限，
+// 返回的警告中列出被忽略的列。这里只检查文件格式，各行内容由调用方检查。
+// Excel文件读取"群组"工作表（没有时为第一个工作表），如果有"成员"工作表，
+// 其中每行的成员会加入对应群组的成员列表。
+func (fs *FileStorage) LoadGroupsFromCSV(csvFile string) ([]models.CSVGroupData, []string, error) {
+	records, lines, memberRecords, err := readGroupTables(csvFile, fs.encoding)
 	if err != nil {
-		return nil, fmt.Errorf("打开CSV文件失败: %w", err)
+		return nil, nil, err
 	}
-	defer file.Close()
-
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // 允许变长记录
 
-	records, err := reader.ReadAll()
+	var groups []models.CSVGroupData
+	warnings, err := bindCSVRecords(records, lines, &groups)
 	if err != nil {
-		return nil, fmt.Errorf("读取CSV文件失败: %w", err)
+		return nil, nil, fmt.Errorf("%s文件格式错误：%w", fileKind(csvFile), err)
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV文件格式错误：至少需要标题行和一行数据")
+	if len(memberRecords) > 0 {
+		memberWarnings, err := mergeMemberRecords(groups, memberRecords)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, memberWarnings...)
 	}
 
-	var groups []models.CSVGroupData
+	return groups, warnings, nil
+}
 
-	// 跳过标题行，从第二行开始
-	for i, record := range records[1:] {
-		if len(record) < 4 {
-			return nil, fmt.Errorf("第%d行数据不完整，需要至少4个字段", i+2)
-		}
+// mergeMemberRecords 将成员工作表中的成员按群名称加入 groups 的成员列表
+func mergeMemberRecords(groups []models.CSVGroupData, records [][]string) ([]string, error) {
+	var members []models.CSVGroupMemberData
+	warnings, err := bindCSVRecords(records, nil, &members)
+	if err != nil {
+		return nil, fmt.Errorf("Excel文件格式错误：%s工作表%w", membersSheetName, err)
+	}
+	for i, warning := range warnings {
+		warnings[i] = membersSheetName + "工作表" + warning
+	}
 
-		// 处理群组类型字段（可选）
-		groupType := ""
-		if len(record) > 4 {
-			groupType = strings.TrimSpace(record[4])
-		}
+	byName := make(map[string]int, len(groups))
+	for i, group := range groups {
+		byName[group.Name] = i
+	}
 
-		group := models.CSVGroupData{
-			Name:        strings.TrimSpace(record[0]),
-			Description: strings.TrimSpace(record[1]),
-			OwnerID:     strings.TrimSpace(record[2]),
-			MemberIDs:   strings.TrimSpace(record[3]),
-			GroupType:   groupType,
+	for _, member := range members {
+		if member.GroupName == "" || member.UserID == "" {
+			return nil, fmt.Errorf("%s工作表第%d行群名称和成员用户ID不能为空", membersSheetName, member.Line)
+		}
+		index, ok := byName[member.GroupName]
+		if !ok {
+			return nil, fmt.Errorf("%s工作表第%d行的群组 %s 不在%s工作表中", membersSheetName, member.Line, member.GroupName, groupsSheetName)
 		}
+		if groups[index].MemberIDs != "" {
+			groups[index].MemberIDs += ","
+		}
+		groups[index].MemberIDs += member.UserID
+	}
+
+	return warnings, nil
+}
 
+// LoadGroupUpdatesFromCSV 从CSV文件或Excel文件（.xlsx）加载群组修改数据
+//
+// 按标题行匹配列（见 models.CSVGroupUpdateData）：群组（ID或当前群名称）、新群名称、
+// 群描述、新群主用户ID、新成员可查看历史消息、入群需要验证、可被搜索、@所有人权限、
+// 群管理权限、全员禁言。只有群组列是必需的，其余列可以省略或留空。
+func (fs *FileStorage) LoadGroupUpdatesFromCSV(csvFile string) ([]models.CSVGroupUpdateData, []string, error) {
+	records, lines, _, err := readGroupTables(csvFile, fs.encoding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var updates []models.CSVGroupUpdateData
+	warnings, err := bindCSVRecords(records, lines, &updates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s文件格式错误：%w", fileKind(csvFile), err)
+	}
+
+	for _, update := range updates {
 		// 验证必填字段
-		if group.Name == "" {
-			return nil, fmt.Errorf("第%d行群名称不能为空", i+2)
-		}
-		if group.OwnerID == "" {
-			return nil, fmt.Errorf("第%d行群主用户ID不能为空", i+2)
+		if update.Group == "" {
+			return nil, nil, fmt.Errorf("第%d行群组不能为空", update.Line)
 		}
+	}
 
-		groups = append(groups, group)
+	return updates, warnings, nil
+}
+
+// fileKind 返回错误信息中使用的文件类型名称
+func fileKind(file string) string {
+	if IsXLSX(file) {
+		return "Excel"
+	}
+	return "CSV"
+}
+
+// readGroupTables 读取群组表格文件，CSV文件只有群组记录；Excel文件返回"群组"工作表
+// （没有时为第一个工作表）和"成员"工作表（可以没有）的记录
+//
+// lines 为群组记录在文件中的行号，Excel 工作表的行号即记录的顺序，此时 lines 为 nil。
+func readGroupTables(file, encoding string) (groups [][]string, lines []int, members [][]string, err error) {
+	if !IsXLSX(file) {
+		groups, lines, err = readCSVRecords(file, encoding)
+		return groups, lines, nil, err
+	}
+
+	names, sheets, err := readXLSXSheets(file)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	groupsSheet := findSheet(names, groupsSheetName, "groups")
+	if groupsSheet == "" {
+		groupsSheet = names[0]
+	}
+	groups = sheets[groupsSheet]
+	if len(groups) < 2 {
+		return nil, nil, nil, fmt.Errorf("Excel文件格式错误：%s工作表至少需要标题行和一行数据", groupsSheet)
 	}
 
-	return groups, nil
+	if membersSheet := findSheet(names, membersSheetName, "members"); membersSheet != "" && membersSheet != groupsSheet {
+		members = sheets[membersSheet]
+	}
+	return groups, nil, members, nil
 }
 
-// ExportGroupsToCSV 导出群组数据到CSV文件
+// readCSVRecords 按指定编码读取CSV文件的全部记录，至少需要标题行和一行数据
+//
+// 同时返回每条记录开始的行号：空行被跳过，引号中的单元格可以跨越多行，
+// 记录的序号与行号不一定对应。
+func readCSVRecords(csvFile, encoding string) ([][]string, []int, error) {
+	data, err := os.ReadFile(csvFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开CSV文件失败: %w", err)
+	}
+	if encoding == "" {
+		encoding = EncodingAuto
+	}
+	data, err = decodeText(data, encoding)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取CSV文件失败: %w", err)
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1 // 允许变长记录
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取CSV文件失败: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	if len(records) < 2 {
+		return nil, nil, fmt.Errorf("CSV文件格式错误：至少需要标题行和一行数据")
+	}
+	return records, lines, nil
+}
+
+// ExportGroupsToCSV 导出群组数据到CSV文件，文件扩展名为 .xlsx 时导出为Excel文件，
+// 其中"群组"工作表为群组列表，"成员"工作表每行一个群成员
 func (fs *FileStorage) ExportGroupsToCSV(outputFile string) error {
 	groups, err := fs.LoadGroups()
 	if err != nil {
@@ -274,23 +462,8 @@ func (fs *FileStorage) ExportGroupsToCSV(outputFile string) error {
 		}
 	}
 
-	// 创建CSV文件
-	file, err := os.Create(outputFile)
-	if err != nil {
-		return fmt.Errorf("创建CSV文件失败: %w", err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// 写入标题行
 	headers := []string{"群组ID", "群名称", "群描述", "群主用户ID", "成员数量", "群组类型", "创建时间", "状态"}
-	if err := writer.Write(headers); err != nil {
-		return fmt.Errorf("写入CSV标题失败: %w", err)
-	}
-
-	// 写入数据行
+	records := make([][]string, 0, len(activeGroups))
 	for _, group := range activeGroups {
 		// 确定群组类型显示文本
 		groupTypeText := "内部群"
@@ -298,7 +471,7 @@ func (fs *FileStorage) ExportGroupsToCSV(outputFile string) error {
 			groupTypeText = "外部群"
 		}
 
-		record := []string{
+		records = append(records, []string{
 			group.ID,
 			group.Name,
 			group.Description,
@@ -307,11 +480,53 @@ func (fs *FileStorage) ExportGroupsToCSV(outputFile string) error {
 			groupTypeText,
 			group.CreatedAt.Format("2006-01-02 15:04:05"),
 			group.Status,
+		})
+	}
+
+	if IsXLSX(outputFile) {
+		memberRows := [][]string{{"群名称", "成员用户ID"}}
+		for _, group := range activeGroups {
+			for _, member := range group.Members {
+				memberRows = append(memberRows, []string{group.Name, member})
+			}
 		}
+		return WriteXLSX(outputFile,
+			Sheet{Name: groupsSheetName, Rows: append([][]string{headers}, records...)},
+			Sheet{Name: membersSheetName, Rows: memberRows},
+		)
+	}
+
+	// 创建CSV文件
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("创建CSV文件失败: %w", err)
+	}
+	defer file.Close()
+
+	output, err := encodingWriter(file, fs.encoding)
+	if err != nil {
+		return fmt.Errorf("写入CSV文件失败: %w", err)
+	}
+	writer := csv.NewWriter(output)
+
+	// 写入标题行
+	if err := writer.Write(headers); err != nil {
+		return fmt.Errorf("写入CSV标题失败: %w", err)
+	}
+
+	// 写入数据行
+	for _, record := range records {
 		if err := writer.Write(record); err != nil {
 			return fmt.Errorf("写入CSV数据失败: %w", err)
 		}
 	}
 
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("写入CSV数据失败: %w", err)
+	}
+	if err := output.Close(); err != nil {
+		return fmt.Errorf("写入CSV数据失败: %w", err)
+	}
 	return nil
 }
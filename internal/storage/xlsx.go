@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 群组表格中群组和成员工作表的名称，导入时也接受英文名称
+const (
+	groupsSheetName  = "群组"
+	membersSheetName = "成员"
+)
+
+// Sheet 导出到 Excel 文件的一个工作表，Rows 的第一行为标题行
+type Sheet struct {
+	Name string
+	Rows [][]string
+}
+
+// IsXLSX 是否按 Excel 格式读写该文件，依据文件扩展名判断
+func IsXLSX(file string) bool {
+	return strings.EqualFold(filepath.Ext(file), ".xlsx")
+}
+
+// WriteXLSX 将多个工作表写入 Excel 文件
+//
+// 所有单元格都写为文本，长数字形式的用户ID不会被 Excel 转成科学计数法。
+func WriteXLSX(file string, sheets ...Sheet) error {
+	workbook := excelize.NewFile()
+	defer workbook.Close()
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			if err := workbook.SetSheetName(workbook.GetSheetName(0), sheet.Name); err != nil {
+				return fmt.Errorf("创建工作表 %s 失败: %w", sheet.Name, err)
+			}
+		} else if _, err := workbook.NewSheet(sheet.Name); err != nil {
+			return fmt.Errorf("创建工作表 %s 失败: %w", sheet.Name, err)
+		}
+
+		for r, row := range sheet.Rows {
+			cell, err := excelize.CoordinatesToCellName(1, r+1)
+			if err != nil {
+				return err
+			}
+			values := make([]interface{}, len(row))
+			for c, value := range row {
+				values[c] = value
+			}
+			if err := workbook.SetSheetRow(sheet.Name, cell, &values); err != nil {
+				return fmt.Errorf("写入工作表 %s 失败: %w", sheet.Name, err)
+			}
+		}
+	}
+
+	output, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("创建Excel文件失败: %w", err)
+	}
+	defer output.Close()
+
+	if _, err := workbook.WriteTo(output); err != nil {
+		return fmt.Errorf("写入Excel文件失败: %w", err)
+	}
+	return output.Close()
+}
+
+// readXLSXSheets 读取 Excel 文件中的全部工作表，按工作表名称返回每个表的所有行
+//
+// 读取单元格的原始值而不是显示格式，避免数字形式的用户ID被格式化。
+func readXLSXSheets(file string) ([]string, map[string][][]string, error) {
+	workbook, err := excelize.OpenFile(file, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("打开Excel文件失败: %w", err)
+	}
+	defer workbook.Close()
+
+	names := workbook.GetSheetList()
+	sheets := make(map[string][][]string, len(names))
+	for _, name := range names {
+		rows, err := workbook.GetRows(name, excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取工作表 %s 失败: %w", name, err)
+		}
+		sheets[name] = rows
+	}
+	return names, sheets, nil
+}
+
+// findSheet 按名称查找工作表，名称比较方式与列名相同；找不到时返回空字符串
+func findSheet(names []string, candidates ...string) string {
+	for _, name := range names {
+		for _, candidate := range candidates {
+			if normalizeHeader(name) == normalizeHeader(candidate) {
+				return name
+			}
+		}
+	}
+	return ""
+}
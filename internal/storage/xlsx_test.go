@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ti-dding/internal/models"
+)
+
+func TestExportGroupsXLSXRoundTrip(t *testing.T) {
+	fs := NewFileStorage(t.TempDir())
+	internal := models.NewGroupWithType("研发群", "后端和前端", "10001", "internal", false)
+	internal.ID = "chat1"
+	// 长数字形式的用户ID导出后不能变成科学计数法
+	internal.Members = []string{"10001", "012345678901234567"}
+	external := models.NewGroupWithType("客户群", "", "u1", "external", true)
+	external.ID = "chat2"
+	external.Members = []string{"u1"}
+	for _, group := range []*models.Group{internal, external} {
+		if err := fs.AddGroup(*group); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "groups.xlsx")
+	if err := fs.ExportGroupsToCSV(file); err != nil {
+		t.Fatalf("ExportGroupsToCSV: %v", err)
+	}
+
+	// 导出的文件可以直接作为创建群组的输入：成员来自成员工作表，多余的列只给出警告
+	groups, warnings, err := fs.LoadGroupsFromCSV(file)
+	if err != nil {
+		t.Fatalf("LoadGroupsFromCSV: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "群组ID") {
+		t.Errorf("warnings = %v", warnings)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("groups = %+v", groups)
+	}
+	got := groups[0]
+	if got.Name != "研发群" || got.Description != "后端和前端" || got.OwnerID != "10001" ||
+		got.MemberIDs != "10001,012345678901234567" || got.GroupType != "内部群" {
+		t.Errorf("row 1 = %+v", got)
+	}
+	if got := groups[1]; got.Name != "客户群" || got.MemberIDs != "u1" || got.GroupType != "外部群" {
+		t.Errorf("row 2 = %+v", got)
+	}
+}
+
+func TestLoadGroupsXLSXSheets(t *testing.T) {
+	fs := NewFileStorage(t.TempDir())
+	dir := t.TempDir()
+
+	// 英文工作表名称，群组表里已有的成员和成员表合并
+	file := filepath.Join(dir, "english.xlsx")
+	if err := WriteXLSX(file,
+		Sheet{Name: "members", Rows: [][]string{{"group", "user_id"}, {"研发群", "u3"}, {"", ""}}},
+		Sheet{Name: "groups", Rows: [][]string{{"name", "owner_id", "members"}, {"研发群", "u1", "u1,u2"}}},
+	); err != nil {
+		t.Fatal(err)
+	}
+	groups, _, err := fs.LoadGroupsFromCSV(file)
+	if err != nil {
+		t.Fatalf("LoadGroupsFromCSV: %v", err)
+	}
+	if len(groups) != 1 || groups[0].MemberIDs != "u1,u2,u3" {
+		t.Errorf("groups = %+v", groups)
+	}
+
+	tests := []struct {
+		name   string
+		sheets []Sheet
+		errSub string
+	}{
+		{
+			"成员表中的群组不存在",
+			[]Sheet{
+				{Name: "群组", Rows: [][]string{{"群名称", "群主用户ID"}, {"研发群", "u1"}}},
+				{Name: "成员", Rows: [][]string{{"群名称", "成员用户ID"}, {"市场群", "u2"}}},
+			},
+			"不在群组工作表中",
+		},
+		{
+			"成员表缺少用户ID",
+			[]Sheet{
+				{Name: "群组", Rows: [][]string{{"群名称", "群主用户ID"}, {"研发群", "u1"}}},
+				{Name: "成员", Rows: [][]string{{"群名称", "成员用户ID"}, {"研发群", ""}}},
+			},
+			"不能为空",
+		},
+		{
+			"只有标题行",
+			[]Sheet{{Name: "Sheet1", Rows: [][]string{{"群名称", "群主用户ID"}}}},
+			"至少需要标题行和一行数据",
+		},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(dir, tt.name+".xlsx")
+		if err := WriteXLSX(file, tt.sheets...); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := fs.LoadGroupsFromCSV(file); err == nil || !strings.Contains(err.Error(), tt.errSub) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.errSub)
+		}
+	}
+}